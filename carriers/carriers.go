@@ -0,0 +1,49 @@
+// Package carriers talks to the shipping carriers that deliver orders: it
+// books shipments with them and fetches their tracking events.
+package carriers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+)
+
+var (
+	ErrUnknownCarrier        = errors.New("unknown carrier")
+	ErrUnknownTrackingNumber = errors.New("the carrier doesn't know this tracking number")
+)
+
+// Carrier is a shipping carrier.
+type Carrier interface {
+	Name() string
+	// Book registers a shipment of order with the carrier and returns its
+	// tracking number.
+	Book(ctx context.Context, order models.Order, shipment models.Shipment) (string, error)
+	// Cancel calls off a booked shipment that won't be handed over.
+	Cancel(ctx context.Context, trackingNumber string) error
+	// Track returns the tracking events of a shipment, oldest first.
+	Track(ctx context.Context, trackingNumber string) ([]models.TrackingEvent, error)
+}
+
+// Registry holds the carriers shipments can be booked with, by name.
+type Registry map[string]Carrier
+
+// NewRegistry returns a registry of carriers.
+func NewRegistry(carriers ...Carrier) Registry {
+	registry := make(Registry, len(carriers))
+	for _, carrier := range carriers {
+		registry[carrier.Name()] = carrier
+	}
+	return registry
+}
+
+// Get returns the carrier with the given name.
+func (r Registry) Get(name string) (Carrier, error) {
+	carrier, ok := r[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCarrier, name)
+	}
+	return carrier, nil
+}
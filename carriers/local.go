@@ -0,0 +1,79 @@
+package carriers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// localPrefix starts the tracking numbers of the local carrier.
+const localPrefix = "LOC"
+
+// localSteps are the statuses a local shipment goes through, one per step.
+var localSteps = []struct {
+	status      models.ShipmentStatus
+	description string
+}{
+	{models.ShipmentLabelCreated, "Shipping label created"},
+	{models.ShipmentInTransit, "Picked up by the courier"},
+	{models.ShipmentOutForDelivery, "Out for delivery"},
+	{models.ShipmentDelivered, "Delivered"},
+}
+
+// Local is a fake carrier for development and tests that needs no account.
+// Its shipments move one status further every Step after they are booked,
+// until they are delivered. Tracking numbers carry the booking time, so
+// tracking keeps working across restarts.
+type Local struct {
+	Step time.Duration
+	now  func() time.Time
+}
+
+// NewLocal returns the local carrier moving shipments on every step.
+func NewLocal(step time.Duration) *Local {
+	return &Local{Step: step, now: time.Now}
+}
+
+func (l *Local) Name() string {
+	return "local"
+}
+
+func (l *Local) Book(ctx context.Context, order models.Order, shipment models.Shipment) (string, error) {
+	return localPrefix + strings.ToUpper(primitive.NewObjectIDFromTimestamp(l.now()).Hex()), nil
+}
+
+// Cancel has nothing to call off: local shipments only exist as their
+// tracking numbers.
+func (l *Local) Cancel(ctx context.Context, trackingNumber string) error {
+	return nil
+}
+
+func (l *Local) Track(ctx context.Context, trackingNumber string) ([]models.TrackingEvent, error) {
+	id, err := primitive.ObjectIDFromHex(strings.ToLower(strings.TrimPrefix(trackingNumber, localPrefix)))
+	if !strings.HasPrefix(trackingNumber, localPrefix) || err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTrackingNumber, trackingNumber)
+	}
+
+	booked := id.Timestamp().UTC()
+	steps := 1
+	if l.Step > 0 {
+		steps += int(l.now().Sub(booked) / l.Step)
+	}
+	if steps > len(localSteps) {
+		steps = len(localSteps)
+	}
+
+	events := make([]models.TrackingEvent, steps)
+	for i := range events {
+		events[i] = models.TrackingEvent{
+			Status:      localSteps[i].status,
+			Description: localSteps[i].description,
+			Occurred_At: booked.Add(time.Duration(i) * l.Step),
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,76 @@
+// Command paymentevent sends a signed payment event to the payment webhook of
+// a local server, standing in for the payment provider during development.
+//
+//	go run ./cmd/paymentevent -order {order_id} -reference {payment_reference} -amount {amount}
+//
+// The event is signed with PAYMENT_WEBHOOK_SECRET unless -secret is given.
+// Pass the ID printed by an earlier run with -id to redeliver that event.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"github.com/ChandanJnv/ecommerce-cart-golang/payments"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func main() {
+	url := flag.String("url", "http://localhost:8000/payments/webhook", "webhook URL")
+	secret := flag.String("secret", os.Getenv("PAYMENT_WEBHOOK_SECRET"), "webhook signing secret")
+	eventID := flag.String("id", "", "event ID, random when empty")
+	eventType := flag.String("type", string(models.PaymentEventSucceeded), "payment.succeeded or payment.failed")
+	orderID := flag.String("order", "", "order ID")
+	reference := flag.String("reference", "", "payment reference of the order")
+	amount := flag.Int64("amount", 0, "amount paid")
+	flag.Parse()
+
+	if *secret == "" {
+		log.Fatal("set PAYMENT_WEBHOOK_SECRET or -secret")
+	}
+	order, err := primitive.ObjectIDFromHex(*orderID)
+	if err != nil {
+		log.Fatal("invalid order ID: ", err)
+	}
+	if *eventID == "" {
+		*eventID = "evt_" + primitive.NewObjectID().Hex()
+	}
+
+	event := models.PaymentEvent{
+		Event_ID:   *eventID,
+		Type:       models.PaymentEventType(*eventType),
+		Order_ID:   order,
+		Reference:  *reference,
+		Amount:     *amount,
+		Created_At: time.Now().UTC(),
+	}
+	body, signature, err := payments.SignEvent([]byte(*secret), event)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, *url, bytes.NewReader(body))
+	if err != nil {
+		log.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(payments.SignatureHeader, signature)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+	response, _ := io.ReadAll(resp.Body)
+
+	fmt.Println("event", event.Event_ID)
+	fmt.Println(resp.Status)
+	fmt.Println(string(response))
+}
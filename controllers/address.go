@@ -2,209 +2,270 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
 	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"github.com/ChandanJnv/ecommerce-cart-golang/postal"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-// localhost:8000/address/addaddress?id={user_id}
-//
-//	{
-//	    "house_name":"home address",
-//	    "street_name":"home street",
-//	    "city_name":"home city",
-//	    "pin_code":"654321"
-//	}
-func AddAddress() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		userID := c.Query("id")
-		if userID == "" {
-			c.Header("Content-Type", "application/json")
-			c.JSON(http.StatusNotFound, gin.H{"error": "user id is empty"})
-			c.Abort()
-			return
-		}
-		address, err := primitive.ObjectIDFromHex(userID)
-		if err != nil {
-			log.Println(err)
-			c.IndentedJSON(500, "Internal server error")
-			return
-		}
-		var addressess models.Address
-		addressess.Address_ID = primitive.NewObjectID()
-		if err := c.BindJSON(&addressess); err != nil {
-			c.IndentedJSON(http.StatusNotAcceptable, err.Error())
-		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-		defer cancel()
+// addressRequest is the body of the address book routes. Default flags left
+// out are false on new addresses, except the first address of a user, which
+// becomes the default for both, and keep their value on updates.
+type addressRequest struct {
+	Label            string  `json:"label"`
+	House            *string `json:"house_name"`
+	Street           *string `json:"street_name"`
+	City             *string `json:"city_name"`
+	Pincode          *string `json:"pin_code"`
+	State            *string `json:"state"`
+	Country          string  `json:"country"`
+	Default_Shipping *bool   `json:"default_shipping"`
+	Default_Billing  *bool   `json:"default_billing"`
+}
 
-		match_filter := bson.D{{Key: "$match", Value: bson.D{primitive.E{Key: "_id", Value: address}}}}
-		unwind := bson.D{{Key: "$unwind", Value: bson.D{primitive.E{Key: "path", Value: "$address"}}}}
-		group := bson.D{{Key: "$group", Value: bson.D{primitive.E{Key: "_id", Value: "$address_id"}, {Key: "count", Value: bson.D{primitive.E{Key: "$sum", Value: 1}}}}}}
+// apply returns address with the fields of the request.
+func (r addressRequest) apply(address models.Address) models.Address {
+	address.Label = r.Label
+	address.House = r.House
+	address.Street = r.Street
+	address.City = r.City
+	address.Pincode = r.Pincode
+	address.State = r.State
+	address.Country = r.Country
+	if r.Default_Shipping != nil {
+		address.Default_Shipping = *r.Default_Shipping
+	}
+	if r.Default_Billing != nil {
+		address.Default_Billing = *r.Default_Billing
+	}
+	return address
+}
 
-		pointCursor, err := UserCollection.Aggregate(ctx, mongo.Pipeline{match_filter, unwind, group})
-		if err != nil {
-			c.IndentedJSON(500, "Internal server error")
+// findAddress returns the address of user with the given ID.
+func findAddress(user models.User, addressID string) (models.Address, error) {
+	id, err := primitive.ObjectIDFromHex(addressID)
+	if err != nil {
+		return models.Address{}, database.ErrAddressNotFound
+	}
+	for _, address := range user.Address_Details {
+		if address.Address_ID == id {
+			return address, nil
 		}
+	}
+	return models.Address{}, database.ErrAddressNotFound
+}
 
-		var addressinfo []bson.M
+// shippingAddress returns the address of a user with the given ID, or when
+// addressID is empty the default shipping address, falling back to the first
+// one the user saved. Users without addresses get an empty one.
+func (app *Application) shippingAddress(ctx context.Context, userID, addressID string) (models.Address, error) {
+	user, err := app.users.FindUser(ctx, userID)
+	if err != nil {
+		return models.Address{}, err
+	}
+	if addressID != "" {
+		return findAddress(user, addressID)
+	}
+	for _, address := range user.Address_Details {
+		if address.Default_Shipping {
+			return address, nil
+		}
+	}
+	if len(user.Address_Details) == 0 {
+		return models.Address{}, nil
+	}
+	return user.Address_Details[0], nil
+}
 
-		if err := pointCursor.All(ctx, &addressinfo); err != nil {
-			panic(err)
+// billingAddress returns the address of a user with the given ID, or when
+// addressID is empty the default billing address, falling back to shipping.
+func (app *Application) billingAddress(ctx context.Context, userID, addressID string, shipping models.Address) (models.Address, error) {
+	user, err := app.users.FindUser(ctx, userID)
+	if err != nil {
+		return models.Address{}, err
+	}
+	if addressID != "" {
+		return findAddress(user, addressID)
+	}
+	for _, address := range user.Address_Details {
+		if address.Default_Billing {
+			return address, nil
 		}
+	}
+	return shipping, nil
+}
 
-		var size int32
+// addressError answers an address book request that failed with err, with
+// the fields at fault for addresses that aren't valid.
+func addressError(c *gin.Context, err error) {
+	var invalid postal.FieldErrors
+	switch {
+	case errors.As(err, &invalid):
+		c.IndentedJSON(http.StatusUnprocessableEntity, gin.H{"error": postal.ErrInvalidAddress.Error(), "fields": invalid})
+	case errors.Is(err, database.ErrUserIdIsNotValid):
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrAddressNotFound), errors.Is(err, database.ErrUserNotFound):
+		c.IndentedJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		log.Println(err)
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
 
-		for _, address_no := range addressinfo {
-			count := address_no["count"]
-			size = count.(int32)
-		}
-		if size < 2 {
-			filter := bson.D{primitive.E{Key: "_id", Value: address}}
-			update := bson.D{{Key: "$push", Value: bson.D{primitive.E{Key: "address", Value: addressess}}}}
-			_, err := UserCollection.UpdateOne(ctx, filter, update)
-			if err != nil {
-				log.Println(err)
-			}
-		} else {
-			c.IndentedJSON(400, "Not Allowed")
+// localhost:8000/addresses
+//
+// Lists the address book of the signed-in user.
+func (app *Application) ListAddresses() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		user, err := app.users.FindUser(ctx, c.GetString("uid"))
+		if err != nil {
+			addressError(c, err)
 			return
 		}
-		c.IndentedJSON(200, "Address added successfully")
-
+		addresses := user.Address_Details
+		if addresses == nil {
+			addresses = []models.Address{}
+		}
+		c.IndentedJSON(http.StatusOK, addresses)
 	}
 }
 
-// localhost:8000/address/edithomeaddress?id={user_id}
+// localhost:8000/addresses
 //
 //	{
+//	    "label":"home",
 //	    "house_name":"home address",
 //	    "street_name":"home street",
 //	    "city_name":"home city",
-//	    "pin_code":"654321"
+//	    "pin_code":"654321",
+//	    "state":"Karnataka",
+//	    "default_shipping":true
 //	}
-func EditHomeAddress() gin.HandlerFunc {
+func (app *Application) CreateAddress() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID := c.Query("id")
-		if userID == "" {
-			c.Header("Content-Type", "application/json")
-			c.JSON(http.StatusNotFound, gin.H{"error": "Invalid"})
-			c.Abort()
+		var request addressRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		usert_id, err := primitive.ObjectIDFromHex(userID)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		userID := c.GetString("uid")
+		user, err := app.users.FindUser(ctx, userID)
 		if err != nil {
-			log.Println(err)
-			c.IndentedJSON(500, "Internal server error")
+			addressError(c, err)
+			return
 		}
 
-		var editaddress models.Address
-		if c.BindJSON(&editaddress); err != nil {
-			log.Println(err)
-			c.IndentedJSON(500, err)
+		address := models.Address{Address_ID: primitive.NewObjectID()}
+		if len(user.Address_Details) == 0 {
+			address.Default_Shipping = true
+			address.Default_Billing = true
+		}
+		address, err = app.config.Addresses.Normalize(request.apply(address))
+		if err == nil {
+			err = app.users.AddAddress(ctx, userID, address)
+		}
+		if err != nil {
+			addressError(c, err)
 			return
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-		defer cancel()
+		c.IndentedJSON(http.StatusCreated, address)
+	}
+}
 
-		filter := bson.D{{Key: "_id", Value: usert_id}}
-		update := bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "address.0.house_name", Value: editaddress.House}, {Key: "address.0.street_name", Value: editaddress.Street}, {Key: "address.0.city_name", Value: editaddress.City}, {Key: "address.0.pin_code", Value: editaddress.Pincode}}}}
+// localhost:8000/addresses/{address_id}
+func (app *Application) GetAddress() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
 
-		if _, err := UserCollection.UpdateOne(ctx, filter, update); err != nil {
-			log.Println(err)
-			c.IndentedJSON(500, "something went wrong")
+		user, err := app.users.FindUser(ctx, c.GetString("uid"))
+		var address models.Address
+		if err == nil {
+			address, err = findAddress(user, c.Param("id"))
+		}
+		if err != nil {
+			addressError(c, err)
 			return
 		}
-		ctx.Done()
-
-		c.IndentedJSON(200, "successfully updated the home address")
+		c.IndentedJSON(http.StatusOK, address)
 	}
 }
 
-// localhost:8000/address/editworkaddress?id={user_id}
+// localhost:8000/addresses/{address_id}
 //
 //	{
-//		"house_name": "work address",
-//		"street_name": "work street",
-//		"city_name": "work city",
-//		"pin_code": "123456"
-//	  }
-func EditWorkAddress() gin.HandlerFunc {
+//	    "label":"office",
+//	    "house_name":"work address",
+//	    "street_name":"work street",
+//	    "city_name":"work city",
+//	    "pin_code":"123456",
+//	    "default_billing":true
+//	}
+//
+// Replaces the address; default flags left out keep their value.
+func (app *Application) UpdateAddress() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID := c.Query("id")
-		if userID == "" {
-			c.Header("Content-Type", "application/json")
-			c.JSON(http.StatusNotFound, gin.H{"error": "Invalid"})
-			c.Abort()
+		var request addressRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		usert_id, err := primitive.ObjectIDFromHex(userID)
-		if err != nil {
-			log.Println(err)
-			c.IndentedJSON(500, "Internal server error")
-		}
-
-		var editaddress models.Address
-		if c.BindJSON(&editaddress); err != nil {
-			log.Println(err)
-			c.IndentedJSON(500, err)
-			return
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		filter := bson.D{{Key: "_id", Value: usert_id}}
-		update := bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "address.1.house_name", Value: editaddress.House}, {Key: "address.1.street_name", Value: editaddress.Street}, {Key: "address.1.city_name", Value: editaddress.City}, {Key: "address.1.pin_code", Value: editaddress.Pincode}}}}
-		if _, err := UserCollection.UpdateOne(ctx, filter, update); err != nil {
-			log.Println(err)
-			c.IndentedJSON(500, "something went wrong")
+		userID := c.GetString("uid")
+		user, err := app.users.FindUser(ctx, userID)
+		var address models.Address
+		if err == nil {
+			address, err = findAddress(user, c.Param("id"))
+		}
+		if err == nil {
+			address, err = app.config.Addresses.Normalize(request.apply(address))
+		}
+		if err == nil {
+			err = app.users.UpdateAddress(ctx, userID, address)
+		}
+		if err != nil {
+			addressError(c, err)
 			return
 		}
-		ctx.Done()
-
-		c.IndentedJSON(200, "Successfully updated address")
-
+		c.IndentedJSON(http.StatusOK, address)
 	}
 }
 
-// localhost:8000/address/deleteaddress?id={user_id}
-func DeleteAddress() gin.HandlerFunc {
+// localhost:8000/addresses/{address_id}
+//
+// Deleting a default address leaves the first remaining address to be used
+// in its place.
+func (app *Application) DeleteAddress() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID := c.Query("id")
-		if userID == "" {
-			c.Header("Content-Type", "application/json")
-			c.JSON(http.StatusNotFound, gin.H{"Eroor": "Invalid Search Index"})
-			c.Abort()
-			return
-		}
-
-		addresses := make([]models.Address, 0)
-		usert_id, err := primitive.ObjectIDFromHex(userID)
+		addressID, err := primitive.ObjectIDFromHex(c.Param("id"))
 		if err != nil {
-			c.IndentedJSON(500, "Internal Server Error: "+err.Error())
+			c.IndentedJSON(http.StatusNotFound, gin.H{"error": database.ErrAddressNotFound.Error()})
+			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		filter := bson.D{primitive.E{Key: "_id", Value: usert_id}}
-		update := bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "address", Value: addresses}}}}
-		_, err = UserCollection.UpdateOne(ctx, filter, update)
-		if err != nil {
-			c.IndentedJSON(404, "wrong command: "+err.Error())
+		if err := app.users.DeleteAddress(ctx, c.GetString("uid"), addressID); err != nil {
+			addressError(c, err)
 			return
 		}
-
-		ctx.Done()
-		c.IndentedJSON(http.StatusOK, "Successfully Deleted")
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "Successfully deleted the address"})
 	}
 }
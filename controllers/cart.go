@@ -9,25 +9,58 @@ import (
 
 	"github.com/ChandanJnv/ecommerce-cart-golang/database"
 	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"github.com/ChandanJnv/ecommerce-cart-golang/pricing"
+	generate "github.com/ChandanJnv/ecommerce-cart-golang/tokens"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type Application struct {
-	prodCollection *mongo.Collection
-	userCollection *mongo.Collection
+	products   database.ProductStore
+	users      database.UserStore
+	carts      database.CartStore
+	guestCarts database.GuestCartStore
+	orders     database.OrderStore
+	returns    database.ReturnStore
+	shipments  database.ShipmentStore
+	events     database.PaymentEventStore
+	checkout   database.CheckoutStore
+	inventory  database.InventoryStore
+	coupons    database.CouponStore
+	promotions database.PromotionStore
+	sessions   database.SessionStore
+	// revocations is the token revocation list the authentication
+	// middleware checks.
+	revocations *generate.RevocationList
+	config      Config
 }
 
-func NewApplication(prodCollection, userCollection *mongo.Collection) *Application {
+func NewApplication(stores database.Stores, config Config) *Application {
 	return &Application{
-		prodCollection: prodCollection,
-		userCollection: userCollection,
+		products:   stores.Products,
+		users:      stores.Users,
+		carts:      stores.Carts,
+		guestCarts: stores.GuestCarts,
+		orders:     stores.Orders,
+		returns:    stores.Returns,
+		shipments:  stores.Shipments,
+		events:     stores.PaymentEvents,
+		checkout:   stores.Checkout,
+		inventory:  stores.Inventory,
+		coupons:    stores.Coupons,
+		promotions: stores.Promotions,
+		sessions:   stores.Sessions,
+
+		revocations: generate.NewRevocationList(stores.Revocations),
+		config:      config,
 	}
 }
 
-// localhost:8000/addtocart?id={product_id}&userID={user_id}
+// localhost:8000/addtocart?id={product_id}&variant={sku}&userID={user_id}&quantity={quantity}
+//
+// quantity is optional and defaults to 1; adding a product that is already in
+// the cart increases its quantity. variant picks the variant of products that
+// have them.
 func (app *Application) AddToCart() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		productQueryID := c.Query("id")
@@ -51,12 +84,17 @@ func (app *Application) AddToCart() gin.HandlerFunc {
 			return
 		}
 
+		quantity, ok := quantityQuery(c, 1)
+		if !ok {
+			return
+		}
+
 		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		if err := database.AddProductToCart(ctx, app.prodCollection, app.userCollection, productID, userQueryID); err != nil {
+		if err := app.carts.AddProductToCart(ctx, productID, c.Query("variant"), userQueryID, quantity); err != nil {
 			log.Println(err)
-			c.IndentedJSON(http.StatusInternalServerError, err)
+			c.IndentedJSON(cartErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 		c.IndentedJSON(http.StatusOK, "Successfully added to the cart")
@@ -90,7 +128,7 @@ func (app *Application) RemoveItem() gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		if err := database.RemoveCartIterm(ctx, app.prodCollection, app.userCollection, productID, userQueryID); err != nil {
+		if err := app.carts.RemoveCartItem(ctx, productID, c.Query("variant"), userQueryID); err != nil {
 			c.IndentedJSON(http.StatusInternalServerError, err)
 			return
 		}
@@ -98,7 +136,12 @@ func (app *Application) RemoveItem() gin.HandlerFunc {
 	}
 }
 
-// localhost:8000/cart?id={user_id}
+// localhost:8000/cart?id={user_id}&address_id={address_id}&currency={currency}&shipping_method={method}
+//
+// address_id picks the address the cart ships to, which decides its tax; it
+// defaults to the first saved address. currency prices the cart in another
+// currency than the base one, and shipping_method picks the shipping to add,
+// the first available method by default.
 func (app *Application) GetItemFromCart() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user_id := c.Query("id")
@@ -108,62 +151,233 @@ func (app *Application) GetItemFromCart() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
-
-		userT_id, _ := primitive.ObjectIDFromHex(user_id)
+		currency, ok := app.resolveCurrency(c, c.Query("currency"))
+		if !ok {
+			return
+		}
 
 		var ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
-		var filledCart models.User
-		if err := UserCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: userT_id}}).Decode(&filledCart); err != nil {
+		cart, err := app.carts.GetCart(ctx, user_id)
+		if err != nil {
 			log.Println(err)
 			c.IndentedJSON(500, "not found")
 			return
 		}
-		filterMatch := bson.D{{Key: "$match", Value: bson.D{primitive.E{Key: "_id", Value: userT_id}}}}
-		unwind := bson.D{{Key: "$unwind", Value: bson.D{primitive.E{Key: "path", Value: "$usercart"}}}}
-		grouping := bson.D{{Key: "$group", Value: bson.D{primitive.E{Key: "_id", Value: "$_id"}, {Key: "total", Value: bson.D{primitive.E{Key: "$sum", Value: "$usercart.price"}}}}}}
+		code, err := app.carts.GetCartCoupon(ctx, user_id)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(500, "not found")
+			return
+		}
+		address, err := app.shippingAddress(ctx, user_id, c.Query("address_id"))
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(cartErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
 
-		pointCursor, err := UserCollection.Aggregate(ctx, mongo.Pipeline{filterMatch, unwind, grouping})
+		// A coupon that stopped applying, e.g. after items were removed,
+		// stays on the cart so it applies again once the cart qualifies.
+		response := cartResponse{Coupon: code}
+		response.Quote, err = app.quoteCart(ctx, user_id, cart, code, address, currency)
 		if err != nil {
+			if !isCouponRejection(err) && !errors.Is(err, database.ErrCouponUsedUp) && !errors.Is(err, database.ErrCouponNotFound) {
+				log.Println(err)
+				c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			response.Coupon_Error = err.Error()
+		}
+		if err := app.shipCart(ctx, &response, address, models.ShippingMethod(c.Query("shipping_method"))); err != nil {
 			log.Println(err)
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, response)
+	}
+}
+
+// localhost:8000/cart/quantity?id={product_id}&userID={user_id}&quantity={quantity}
+//
+// Sets the quantity of a product already in the cart; 0 removes it.
+func (app *Application) SetCartQuantity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productID, userID, ok := cartItemQuery(c)
+		if !ok {
+			return
 		}
-		var listing []bson.M
+		quantity, ok := quantityQuery(c, -1)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
 
-		if err := pointCursor.All(ctx, &listing); err != nil {
+		if err := app.carts.SetCartItemQuantity(ctx, productID, c.Query("variant"), userID, quantity); err != nil {
 			log.Println(err)
-			c.AbortWithStatus(http.StatusInternalServerError)
+			c.IndentedJSON(cartErrorStatus(err), gin.H{"error": err.Error()})
+			return
 		}
+		c.IndentedJSON(http.StatusOK, "Successfully updated the quantity")
+	}
+}
 
-		for _, json := range listing {
-			c.IndentedJSON(200, json["total"])
-			c.IndentedJSON(200, filledCart.UserCart)
+// localhost:8000/cart/increment?id={product_id}&userID={user_id}&quantity={quantity}
+func (app *Application) IncrementCartItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productID, userID, ok := cartItemQuery(c)
+		if !ok {
+			return
 		}
+		quantity, ok := quantityQuery(c, 1)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := app.carts.AddProductToCart(ctx, productID, c.Query("variant"), userID, quantity); err != nil {
+			log.Println(err)
+			c.IndentedJSON(cartErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, "Successfully updated the quantity")
+	}
+}
+
+// localhost:8000/cart/decrement?id={product_id}&userID={user_id}&quantity={quantity}
+func (app *Application) DecrementCartItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productID, userID, ok := cartItemQuery(c)
+		if !ok {
+			return
+		}
+		quantity, ok := quantityQuery(c, 1)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := app.carts.DecrementCartItem(ctx, productID, c.Query("variant"), userID, quantity); err != nil {
+			log.Println(err)
+			c.IndentedJSON(cartErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, "Successfully updated the quantity")
 	}
 }
 
 // localhost:8000/cartcheckout?userID={user_id}
+//
+//	{
+//	    "payment_method": "card",
+//	    "address_id": "66d4330450820c57cfb26559",
+//	    "billing_address_id": "66d4330450820c57cfb2655a",
+//	    "currency": "USD",
+//	    "shipping_method": "express",
+//	    "accept_changes": "9f86d081884c7d65"
+//	}
+//
+// The cart is checked against the current prices and stock first. When it
+// changed since the items were added, nothing is ordered and the changes are
+// returned with a token; checking out again with the token as accept_changes
+// orders the cart as it is now.
 func (app *Application) BuyFromCart() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userQueryID := c.Query("userID")
 		if userQueryID == "" {
-			log.Panicln("user id is empty")
+			log.Println("user id is empty")
 			_ = c.AbortWithError(http.StatusBadRequest, errors.New("user id is empty"))
 			return
 		}
 
-		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		request, ok := bindCheckoutRequest(c)
+		if !ok {
+			return
+		}
+		currency, ok := app.resolveCurrency(c, request.Currency)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 15*time.Second)
 		defer cancel()
 
-		if err := database.BuyItemFromCart(ctx, app.userCollection, userQueryID); err != nil {
-			c.IndentedJSON(http.StatusInternalServerError, err)
+		cart, err := app.carts.GetCart(ctx, userQueryID)
+		if err == nil && len(cart) == 0 {
+			err = database.ErrCartIsEmpty
+		}
+		var changes []cartChange
+		if err == nil {
+			cart, changes, err = app.revalidateCart(ctx, cart, currency)
+		}
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(orderErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
-		c.IndentedJSON(http.StatusOK, "Successfully placed the order")
+		if len(changes) > 0 {
+			token := changesToken(changes)
+			if request.Accept_Changes != token {
+				c.IndentedJSON(http.StatusConflict, cartChangedResponse{
+					Error:         "the cart changed since the items were added",
+					Currency:      currency,
+					Changes:       changes,
+					Changes_Token: token,
+				})
+				return
+			}
+		}
+		if len(cart) == 0 {
+			err = database.ErrCartIsEmpty
+		}
+
+		var code string
+		if err == nil {
+			code, err = app.carts.GetCartCoupon(ctx, userQueryID)
+		}
+		var address, billing models.Address
+		if err == nil {
+			address, billing, err = app.checkoutAddresses(ctx, userQueryID, request)
+		}
+		var quote pricing.Quote
+		if err == nil {
+			quote, err = app.quoteCart(ctx, userQueryID, cart, code, address, currency)
+		}
+		if err == nil {
+			err = app.shipQuote(ctx, &quote, address, request.Shipping_Method)
+		}
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(orderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		order, err := app.placeOrder(ctx, newOrder(userQueryID, quote, code, address, billing), request.Payment_Method, true)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(orderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "Successfully placed the order", "order": order})
 	}
 }
 
-// localhost:8000/instantbuy?id={product_id}&userID={user_id}
+// localhost:8000/instantbuy?id={product_id}&variant={sku}&userID={user_id}
+//
+//	{
+//	    "payment_method": "card",
+//	    "coupon_code": "WELCOME10",
+//	    "address_id": "66d4330450820c57cfb26559",
+//	    "billing_address_id": "66d4330450820c57cfb2655a",
+//	    "currency": "USD"
+//	}
 func (app *Application) InstantBuy() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		productQueryID := c.Query("id")
@@ -187,13 +401,52 @@ func (app *Application) InstantBuy() gin.HandlerFunc {
 			return
 		}
 
-		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		request, ok := bindCheckoutRequest(c)
+		if !ok {
+			return
+		}
+		currency, ok := app.resolveCurrency(c, request.Currency)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 15*time.Second)
 		defer cancel()
 
-		if err := database.InstantBuyer(ctx, app.prodCollection, app.userCollection, productID, userQueryID); err != nil {
-			c.IndentedJSON(http.StatusInternalServerError, err)
+		address, billing, err := app.checkoutAddresses(ctx, userQueryID, request)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(orderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		product, err := app.products.FindProduct(ctx, productID)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(orderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		line, err := database.NewCartLine(product, c.Query("variant"), 1)
+		if err != nil {
+			c.IndentedJSON(orderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		code := normalizeCouponCode(request.Coupon_Code)
+		quote, err := app.quoteCart(ctx, userQueryID, []models.ProductUser{line}, code, address, currency)
+		if err == nil {
+			err = app.shipQuote(ctx, &quote, address, request.Shipping_Method)
+		}
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(orderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		order, err := app.placeOrder(ctx, newOrder(userQueryID, quote, code, address, billing), request.Payment_Method, false)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(orderErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
-		c.IndentedJSON(http.StatusOK, "Successfully placed the order")
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "Successfully placed the order", "order": order})
 	}
 }
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// cartChangeType says how a cart line differs from the product today.
+type cartChangeType string
+
+const (
+	// changePrice means the product's price changed since it was added.
+	changePrice cartChangeType = "price_changed"
+	// changeQuantity means fewer units are in stock than are in the cart;
+	// the order takes what's left.
+	changeQuantity cartChangeType = "quantity_reduced"
+	// changeUnavailable means the product, or its variant, is gone or sold
+	// out; the order leaves it out.
+	changeUnavailable cartChangeType = "unavailable"
+)
+
+// cartChange is a difference between a cart line and the product it was
+// added from. Prices are in the currency of the checkout.
+type cartChange struct {
+	Type         cartChangeType     `json:"type"`
+	Product_ID   primitive.ObjectID `json:"product_id"`
+	Product_Name *string            `json:"product_name,omitempty"`
+	Variant      string             `json:"variant,omitempty"`
+	Old_Price    *int64             `json:"old_price,omitempty"`
+	New_Price    *int64             `json:"new_price,omitempty"`
+	Quantity     int                `json:"quantity"`
+	Available    *int64             `json:"available,omitempty"`
+}
+
+// cartChangedResponse answers a checkout of a cart that changed since its
+// items were added. Checking out again with Changes_Token as accept_changes
+// places the order with the changes.
+type cartChangedResponse struct {
+	Error         string       `json:"error"`
+	Currency      string       `json:"currency"`
+	Changes       []cartChange `json:"changes"`
+	Changes_Token string       `json:"changes_token"`
+}
+
+// revalidateCart checks the lines of a cart against the products as they are
+// now, returning the lines to order, at current prices and within the stock
+// left, and the changes from the cart. currency is the one prices are compared
+// in.
+func (app *Application) revalidateCart(ctx context.Context, cart []models.ProductUser, currency string) ([]models.ProductUser, []cartChange, error) {
+	converter := app.config.Currency
+	var lines []models.ProductUser
+	var changes []cartChange
+
+	for _, old := range cart {
+		change := cartChange{
+			Product_ID:   old.Product_ID,
+			Product_Name: old.Product_Name,
+			Variant:      old.Variant,
+			Quantity:     old.Quantity,
+		}
+
+		product, err := app.products.FindProduct(ctx, old.Product_ID)
+		if errors.Is(err, database.ErrCantFindProduct) {
+			change.Type = changeUnavailable
+			changes = append(changes, change)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		available, err := database.AvailableStock(product, old.Variant)
+		if errors.Is(err, database.ErrVariantRequired) || errors.Is(err, database.ErrVariantNotFound) || (err == nil && available <= 0) {
+			change.Type = changeUnavailable
+			changes = append(changes, change)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		quantity := old.Quantity
+		if int64(quantity) > available {
+			quantity = int(available)
+		}
+		line, err := database.NewCartLine(product, old.Variant, quantity)
+		if err != nil {
+			return nil, nil, err
+		}
+		lines = append(lines, line)
+
+		oldPrice, err := converter.PriceOf(old.Price, old.Prices, currency)
+		if err != nil {
+			return nil, nil, err
+		}
+		newPrice, err := converter.PriceOf(line.Price, line.Prices, currency)
+		if err != nil {
+			return nil, nil, err
+		}
+		if oldPrice != newPrice {
+			priceChange := change
+			priceChange.Type = changePrice
+			priceChange.Old_Price, priceChange.New_Price = &oldPrice, &newPrice
+			changes = append(changes, priceChange)
+		}
+		if quantity < old.Quantity {
+			change.Type = changeQuantity
+			change.Available = &available
+			changes = append(changes, change)
+		}
+	}
+	return lines, changes, nil
+}
+
+// changesToken fingerprints changes, so that a checkout only goes ahead with
+// the changes the client was shown.
+func changesToken(changes []cartChange) string {
+	data, _ := json.Marshal(changes)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"github.com/ChandanJnv/ecommerce-cart-golang/pricing"
+	"github.com/gin-gonic/gin"
+)
+
+// checkoutRequest is the optional body of the checkout routes. Coupon_Code
+// is only read by instant buys; cart checkouts use the coupon of the cart.
+// Address_ID picks the shipping address, the default shipping one by default,
+// and Billing_Address_ID the billing address, the default billing one or else
+// the shipping address. Currency is what the order is priced and paid in, the
+// base currency by default.
+// Shipping_Method picks how the order ships, the first method available by
+// default. Accept_Changes acknowledges the changes to the cart a checkout
+// answered with, see cartChangedResponse.
+type checkoutRequest struct {
+	Payment_Method     models.PaymentMethod  `json:"payment_method"`
+	Coupon_Code        string                `json:"coupon_code"`
+	Address_ID         string                `json:"address_id"`
+	Billing_Address_ID string                `json:"billing_address_id"`
+	Currency           string                `json:"currency"`
+	Shipping_Method    models.ShippingMethod `json:"shipping_method"`
+	Accept_Changes     string                `json:"accept_changes"`
+}
+
+// bindCheckoutRequest reads the checkout body. Clients that don't send one,
+// or don't pick a payment method, pay cash on delivery.
+func bindCheckoutRequest(c *gin.Context) (checkoutRequest, bool) {
+	var request checkoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return request, false
+		}
+	}
+	if request.Payment_Method == "" {
+		request.Payment_Method = models.PaymentCOD
+	}
+	if !request.Payment_Method.Valid() {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "unknown payment method"})
+		return request, false
+	}
+	if request.Shipping_Method != "" && !request.Shipping_Method.Valid() {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": errUnknownShippingMethod.Error()})
+		return request, false
+	}
+	return request, true
+}
+
+// checkoutAddresses returns the shipping and billing addresses the checkout
+// request picks.
+func (app *Application) checkoutAddresses(ctx context.Context, userID string, request checkoutRequest) (models.Address, models.Address, error) {
+	shipping, err := app.shippingAddress(ctx, userID, request.Address_ID)
+	if err != nil {
+		return shipping, models.Address{}, err
+	}
+	billing, err := app.billingAddress(ctx, userID, request.Billing_Address_ID, shipping)
+	return shipping, billing, err
+}
+
+// newOrder returns an order for the lines of quote, with its promotions,
+// coupon, taxes and shipping, in its currency, shipped to address and billed
+// to billing.
+func newOrder(userID string, quote pricing.Quote, coupon string, address, billing models.Address) models.Order {
+	order := database.NewOrder(userID, quote.Items)
+	order.Coupon_Code = coupon
+	order.Discounts = quote.Discounts
+	order.Tax_Lines = quote.Tax_Lines
+	order.Currency = quote.Currency
+	order.Exchange_Rate = quote.Exchange_Rate
+	if quote.Shipping != nil {
+		shipping := *quote.Shipping
+		total := *order.Price + shipping.Price
+		order.Shipping = &shipping
+		order.Price = &total
+	}
+	if !address.Address_ID.IsZero() {
+		order.Shipping_Address = &address
+	}
+	if !billing.Address_ID.IsZero() {
+		order.Billing_Address = &billing
+	}
+	return order
+}
+
+// placeOrder takes payment for a new order and stores it. The payment is
+// authorized first; reserving the stock, storing the order and emptying the
+// cart when clearCart is set then happen as one unit of work, and the
+// authorization is voided when that fails, so a checkout that can't be
+// fulfilled or paid leaves nothing behind. Authorized payments are captured
+// next, which marks the order paid and turns the hold into a sale. Cash on
+// delivery sells right away; payments that complete later through the
+// webhook keep the hold until they do or it expires.
+func (app *Application) placeOrder(ctx context.Context, order models.Order, method models.PaymentMethod, clearCart bool) (models.Order, error) {
+	payments := app.config.Payments
+
+	payment, err := payments.Authorize(ctx, order, method)
+	if err != nil {
+		return order, err
+	}
+	order.Payment_method = payment
+
+	now := time.Now().UTC()
+	reservation := models.Reservation{
+		Order_ID:   order.Order_ID,
+		User_ID:    order.User_ID,
+		Items:      order.Order_Cart,
+		Status:     models.ReservationHeld,
+		Expires_At: now.Add(app.config.ReservationTTL),
+		Created_At: now,
+	}
+	if payment.COD {
+		reservation.Status = models.ReservationConverted
+	}
+
+	if err := app.checkout.PlaceOrder(ctx, order, reservation, clearCart); err != nil {
+		if _, voidErr := payments.Void(ctx, payment); voidErr != nil {
+			log.Println("failed to void payment", payment.Reference, voidErr)
+		}
+		return order, err
+	}
+
+	if payment.Status != models.PaymentAuthorized {
+		return order, nil
+	}
+
+	// The order exists from here on; a failed capture leaves it placed with
+	// an authorized payment to be captured before the hold expires.
+	captured, err := payments.Capture(ctx, payment)
+	if err != nil {
+		log.Println("failed to capture payment", payment.Reference, err)
+		return order, nil
+	}
+	paid, err := app.markOrderPaid(ctx, order.Order_ID, captured, "payment captured")
+	if err != nil {
+		log.Println(err)
+		return order, nil
+	}
+	return paid, nil
+}
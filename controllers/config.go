@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/carriers"
+	"github.com/ChandanJnv/ecommerce-cart-golang/currency"
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/payments"
+	"github.com/ChandanJnv/ecommerce-cart-golang/postal"
+	"github.com/ChandanJnv/ecommerce-cart-golang/shipping"
+	"github.com/ChandanJnv/ecommerce-cart-golang/tax"
+)
+
+// Config holds the application settings that come from the environment.
+type Config struct {
+	// CartMergeStrategy resolves products found in both the guest cart and
+	// the user's cart at login. Set with CART_MERGE_STRATEGY.
+	CartMergeStrategy database.MergeStrategy
+
+	// Payments takes payment at checkout and refunds cancelled and returned
+	// orders. Set with PAYMENT_PROVIDER ("mock" or empty for the manual
+	// cash-on-delivery provider) and MOCK_PAYMENT_BEHAVIOR.
+	Payments payments.Provider
+
+	// WebhookSecret signs the events sent to the payment webhook. The
+	// webhook is closed when PAYMENT_WEBHOOK_SECRET isn't set.
+	WebhookSecret []byte
+
+	// ReservationTTL is how long checkout holds the stock of an order while
+	// waiting for its payment. Set with RESERVATION_TTL, e.g. "15m".
+	ReservationTTL time.Duration
+
+	// ReservationSweepInterval is how often expired holds are released. Set
+	// with RESERVATION_SWEEP_INTERVAL, e.g. "1m".
+	ReservationSweepInterval time.Duration
+
+	// Tax works out the tax of carts and orders from the tax table file
+	// named by TAX_TABLE; without one no tax is charged.
+	Tax tax.Calculator
+
+	// Shipping lists the shipping methods of a cart with their prices, from
+	// the rate table file named by SHIPPING_RATES; without one standard
+	// shipping is free everywhere.
+	Shipping shipping.Rater
+
+	// Carriers book shipments and track them. The local fake carrier moves
+	// its shipments one status further every LOCAL_CARRIER_STEP, e.g. "1h".
+	Carriers carriers.Registry
+
+	// Currency converts prices from the base currency to the others
+	// customers can shop in, with the rates in the file named by
+	// CURRENCY_RATES. Without one, only BASE_CURRENCY (INR by default) is
+	// supported.
+	Currency *currency.Converter
+
+	// Addresses validates and normalizes the addresses users save. Postal
+	// codes listed in the dataset file named by POSTAL_DATA must match the
+	// city and state of the address; others are only checked against the
+	// postal code format of their country.
+	Addresses *postal.Validator
+
+	// IdempotencyWindow is how long the response to a request sent with an
+	// Idempotency-Key is replayed. Set with IDEMPOTENCY_WINDOW, e.g. "24h".
+	IdempotencyWindow time.Duration
+
+	// RevocationSyncInterval is how often the token revocation list cached
+	// in process picks up the tokens revoked by other instances. Set with
+	// REVOCATION_SYNC_INTERVAL, e.g. "30s".
+	RevocationSyncInterval time.Duration
+}
+
+// ConfigFromEnv reads the application settings from environment variables.
+func ConfigFromEnv() (Config, error) {
+	var config Config
+
+	strategy, err := database.ParseMergeStrategy(os.Getenv("CART_MERGE_STRATEGY"))
+	if err != nil {
+		return config, err
+	}
+	config.CartMergeStrategy = strategy
+
+	provider, err := payments.NewProvider(os.Getenv("PAYMENT_PROVIDER"), os.Getenv("MOCK_PAYMENT_BEHAVIOR"))
+	if err != nil {
+		return config, err
+	}
+	config.Payments = provider
+	config.WebhookSecret = []byte(os.Getenv("PAYMENT_WEBHOOK_SECRET"))
+
+	if config.Tax, err = tax.NewCalculator(os.Getenv("TAX_TABLE")); err != nil {
+		return config, err
+	}
+
+	if config.Shipping, err = shipping.NewRater(os.Getenv("SHIPPING_RATES")); err != nil {
+		return config, err
+	}
+	if config.Currency, err = currency.NewConverter(os.Getenv("CURRENCY_RATES"), os.Getenv("BASE_CURRENCY")); err != nil {
+		return config, err
+	}
+	if config.Addresses, err = postal.NewValidator(os.Getenv("POSTAL_DATA")); err != nil {
+		return config, err
+	}
+
+	if config.ReservationTTL, err = durationFromEnv("RESERVATION_TTL", 15*time.Minute); err != nil {
+		return config, err
+	}
+	if config.ReservationSweepInterval, err = durationFromEnv("RESERVATION_SWEEP_INTERVAL", time.Minute); err != nil {
+		return config, err
+	}
+	if config.IdempotencyWindow, err = durationFromEnv("IDEMPOTENCY_WINDOW", 24*time.Hour); err != nil {
+		return config, err
+	}
+	if config.RevocationSyncInterval, err = durationFromEnv("REVOCATION_SYNC_INTERVAL", 30*time.Second); err != nil {
+		return config, err
+	}
+	step, err := durationFromEnv("LOCAL_CARRIER_STEP", time.Hour)
+	if err != nil {
+		return config, err
+	}
+	config.Carriers = carriers.NewRegistry(carriers.NewLocal(step))
+
+	return config, nil
+}
+
+// durationFromEnv parses a positive duration from an environment variable.
+func durationFromEnv(name string, defaultValue time.Duration) (time.Duration, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return defaultValue, nil
+	}
+	duration, err := time.ParseDuration(raw)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration such as 15m, got %q", name, raw)
+	}
+	return duration, nil
+}
@@ -7,22 +7,15 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/ChandanJnv/ecommerce-cart-golang/database"
 	"github.com/ChandanJnv/ecommerce-cart-golang/models"
 	generate "github.com/ChandanJnv/ecommerce-cart-golang/tokens"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var (
-	UserCollection    *mongo.Collection = database.UserDatabase(database.Client, "Users")
-	ProductCollection *mongo.Collection = database.UserDatabase(database.Client, "Products")
-	Validate                            = validator.New()
-)
+var Validate = validator.New()
 
 func HashPassword(password string) string {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
@@ -52,7 +45,7 @@ func VerifyPassword(userPassword string, givenPassword string) (bool, string) {
 //	    "email": "alpha@beta.com",
 //	    "phone": "9876543210"
 //	}
-func SignUp() gin.HandlerFunc {
+func (app *Application) SignUp() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
@@ -71,24 +64,24 @@ func SignUp() gin.HandlerFunc {
 			return
 		}
 
-		count, err := UserCollection.CountDocuments(ctx, bson.M{"email": user.Email})
+		exists, err := app.users.EmailExists(ctx, *user.Email)
 		if err != nil {
-			log.Panic(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		if count > 0 {
+		if exists {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "user already registerd"})
 			return
 		}
 
-		count, err = UserCollection.CountDocuments(ctx, bson.M{"phone": user.Phone})
+		exists, err = app.users.PhoneExists(ctx, *user.Phone)
 		if err != nil {
-			log.Panic(err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		if count > 0 {
+		if exists {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "this phone no. is already used"})
 			return
 		}
@@ -100,16 +93,14 @@ func SignUp() gin.HandlerFunc {
 		user.Updated_At, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		user.ID = primitive.NewObjectID()
 		user.User_ID = user.ID.Hex()
-		token, refreshToken, _ := generate.TokenGenerator(*user.Email, *user.First_Name, *user.Last_Name, user.User_ID)
+		token, refreshToken, _ := generate.TokenGenerator(*user.Email, *user.First_Name, *user.Last_Name, user.User_ID, "", "")
 		user.Token = &token
 		user.Refresh_Token = &refreshToken
 		user.UserCart = make([]models.ProductUser, 0)
 		user.Address_Details = make([]models.Address, 0)
-		user.Order_Status = make([]models.Order, 0)
 
-		_, insetErr := UserCollection.InsertOne(ctx, user)
-		if insetErr != nil {
-			log.Println(insetErr)
+		if err := app.users.CreateUser(ctx, user); err != nil {
+			log.Println(err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "the user did not get created"})
 			return
 		}
@@ -120,11 +111,14 @@ func SignUp() gin.HandlerFunc {
 
 // localhost:8000/users/login
 //
+// A guest cart sent in the cart_token cookie or header is merged into the
+// user's cart.
+//
 //	{
 //	    "email":"alpha@beta.com",
 //	    "password":"alpha@123"
 //	}
-func Login() gin.HandlerFunc {
+func (app *Application) Login() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
@@ -134,8 +128,12 @@ func Login() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err})
 			return
 		}
-		var foundUser models.User
-		if err := UserCollection.FindOne(ctx, bson.M{"email": user.Email}).Decode(&foundUser); err != nil {
+		if user.Email == nil || user.Password == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "email and password are required"})
+			return
+		}
+		foundUser, err := app.users.FindUserByEmail(ctx, *user.Email)
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "login or password incorrect"})
 			return
 		}
@@ -147,16 +145,16 @@ func Login() gin.HandlerFunc {
 			return
 		}
 
-		token, refreshToken, err := generate.TokenGenerator(*foundUser.Email, *foundUser.First_Name, *foundUser.Last_Name, foundUser.User_ID)
+		token, refreshToken, err := app.startSession(ctx, foundUser)
 		if err != nil {
 			fmt.Println("failed to generate token.", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		generate.UpdateAllTokens(token, refreshToken, foundUser.User_ID)
+		app.mergeGuestCart(ctx, c, foundUser.User_ID)
 
-		c.JSON(http.StatusFound, fmt.Sprintf("token: %s", token))
+		c.JSON(http.StatusFound, gin.H{"token": token, "refresh_token": refreshToken})
 	}
 
 }
@@ -165,11 +163,12 @@ func Login() gin.HandlerFunc {
 //
 //	{
 //	    "product_name": "laptop",
-//	    "price": 200,
+//	    "price": 20000,
+//	    "prices": [{"amount": 299, "currency": "USD"}],
 //	    "Rating": 4,
 //	    "Image": "/img/path/dotjpg"
 //	}
-func ProductViewerAdmin() gin.HandlerFunc {
+func (app *Application) ProductViewerAdmin() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
@@ -181,8 +180,17 @@ func ProductViewerAdmin() gin.HandlerFunc {
 			return
 		}
 
+		if err := validateProduct(products); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateProductPrices(app.config.Currency, products); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		products.Product_ID = primitive.NewObjectID()
-		if _, err := ProductCollection.InsertOne(ctx, products); err != nil {
+		if err := app.products.AddProduct(ctx, products); err != nil {
 			log.Println(err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "not inserted"})
 			return
@@ -192,42 +200,45 @@ func ProductViewerAdmin() gin.HandlerFunc {
 	}
 }
 
-// localhost:8000/users/productview
-func SearchProduct() gin.HandlerFunc {
+// localhost:8000/users/productview?currency={currency}
+//
+// currency prices the products in another currency than the base one.
+func (app *Application) SearchProduct() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var productList []models.Product
+		code, ok := app.resolveCurrency(c, c.Query("currency"))
+		if !ok {
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		cursor, err := ProductCollection.Find(ctx, bson.D{})
+		productList, err := app.products.ListProducts(ctx)
 		if err != nil {
-			c.IndentedJSON(http.StatusInternalServerError, "something wend wrong: "+err.Error())
-			return
-		}
-		defer cursor.Close(ctx)
-
-		if err := cursor.All(ctx, &productList); err != nil {
 			log.Println(err)
-			c.AbortWithStatus(http.StatusInternalServerError)
+			c.IndentedJSON(http.StatusInternalServerError, "something wend wrong: "+err.Error())
 			return
 		}
 
-		if err := cursor.Err(); err != nil {
+		views, err := app.viewProducts(productList, code)
+		if err != nil {
 			log.Println(err)
-			c.IndentedJSON(400, "invalid: "+err.Error())
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-
-		c.IndentedJSON(http.StatusOK, productList)
+		c.IndentedJSON(http.StatusOK, views)
 
 	}
 }
 
-// localhost:8000/users/search?name=laptop
-func SearchProductByQuery() gin.HandlerFunc {
+// localhost:8000/users/search?name=laptop&currency={currency}
+func (app *Application) SearchProductByQuery() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var searchProducts []models.Product
 		queryParam := c.Query("name")
+		code, ok := app.resolveCurrency(c, c.Query("currency"))
+		if !ok {
+			return
+		}
 
 		if queryParam == "" {
 			log.Println("query is empty")
@@ -240,26 +251,19 @@ func SearchProductByQuery() gin.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		searchQueryDB, err := ProductCollection.Find(ctx, bson.M{"product_name": bson.M{"$regex": queryParam}})
+		searchProducts, err := app.products.SearchProducts(ctx, queryParam)
 		if err != nil {
 			log.Println(err)
 			c.IndentedJSON(404, "something wend wrong: "+err.Error())
 			return
 		}
-		defer searchQueryDB.Close(ctx)
 
-		if err := searchQueryDB.All(ctx, &searchProducts); err != nil {
-			log.Println(err)
-			c.IndentedJSON(400, "invalid: "+err.Error())
-			return
-		}
-
-		if err := searchQueryDB.Err(); err != nil {
+		views, err := app.viewProducts(searchProducts, code)
+		if err != nil {
 			log.Println(err)
-			c.IndentedJSON(400, "invalid request: "+err.Error())
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-
-		c.IndentedJSON(200, searchProducts)
+		c.IndentedJSON(200, views)
 	}
 }
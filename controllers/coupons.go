@@ -0,0 +1,283 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"github.com/ChandanJnv/ecommerce-cart-golang/pricing"
+	"github.com/gin-gonic/gin"
+)
+
+type couponRequest struct {
+	Code string `json:"code"`
+}
+
+// normalizeCouponCode makes coupon codes case-insensitive.
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// quoteCart prices the lines of a cart shipped to address with the
+// promotions it qualifies for, the coupon code applied, if any, and tax.
+// When the coupon can't be used the quote without it is returned along with
+// the reason. The quote is in the given currency, which must be supported.
+func (app *Application) quoteCart(ctx context.Context, userID string, lines []models.ProductUser, code string, address models.Address, currency string) (pricing.Quote, error) {
+	converter := app.config.Currency
+	localized, err := converter.Localize(lines, currency)
+	if err != nil {
+		return pricing.Quote{}, err
+	}
+	quote := pricing.NewQuote(localized, currency, converter.Exchange(currency))
+	quote.Exchange_Rate = converter.Rate(currency)
+	now := time.Now().UTC()
+
+	promotions, err := app.promotions.ListPromotions(ctx, true)
+	if err != nil {
+		return quote, err
+	}
+	quote.ApplyPromotions(promotions, now)
+	couponErr := app.applyCoupon(ctx, &quote, userID, code, now)
+
+	taxes, err := app.config.Tax.Tax(ctx, address, quote.Items)
+	if err == nil {
+		err = quote.ApplyTax(taxes)
+	}
+	if err != nil {
+		return quote, err
+	}
+	return quote, couponErr
+}
+
+// applyCoupon takes the discount of the coupon with the given code, if any,
+// off quote.
+func (app *Application) applyCoupon(ctx context.Context, quote *pricing.Quote, userID, code string, now time.Time) error {
+	if code == "" {
+		return nil
+	}
+	coupon, err := app.coupons.FindCoupon(ctx, code)
+	if err != nil {
+		return err
+	}
+	if !coupon.UsesLeft(userID) {
+		return database.ErrCouponUsedUp
+	}
+	return quote.ApplyCoupon(coupon, now)
+}
+
+// localhost:8000/cart/coupon?userID={user_id}&address_id={address_id}&currency={currency}
+//
+//	{
+//	    "code": "WELCOME10"
+//	}
+//
+// Applies a coupon to the cart, replacing the one applied before, and returns
+// the cart with the discount.
+func (app *Application) ApplyCartCoupon() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userQueryID := c.Query("userID")
+		if userQueryID == "" {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "user id is empty"})
+			return
+		}
+
+		var request couponRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		code := normalizeCouponCode(request.Code)
+		if code == "" {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "coupon code is empty"})
+			return
+		}
+		currency, ok := app.resolveCurrency(c, c.Query("currency"))
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		cart, err := app.carts.GetCart(ctx, userQueryID)
+		if err == nil && len(cart) == 0 {
+			err = database.ErrCartIsEmpty
+		}
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(couponErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		address, err := app.shippingAddress(ctx, userQueryID, c.Query("address_id"))
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(couponErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		quote, err := app.quoteCart(ctx, userQueryID, cart, code, address, currency)
+		if err == nil {
+			err = app.carts.SetCartCoupon(ctx, userQueryID, code)
+		}
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(couponErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		response := cartResponse{Quote: quote, Coupon: code}
+		if err := app.shipCart(ctx, &response, address, ""); err != nil {
+			log.Println(err)
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, response)
+	}
+}
+
+// localhost:8000/cart/coupon?userID={user_id}
+func (app *Application) RemoveCartCoupon() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userQueryID := c.Query("userID")
+		if userQueryID == "" {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "user id is empty"})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := app.carts.SetCartCoupon(ctx, userQueryID, ""); err != nil {
+			log.Println(err)
+			c.IndentedJSON(couponErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, "Successfully removed the coupon")
+	}
+}
+
+// localhost:8000/admin/coupons
+//
+//	{
+//	    "code": "WELCOME10",
+//	    "type": "percentage",
+//	    "value": 10,
+//	    "min_cart_value": 500,
+//	    "max_uses_per_user": 1
+//	}
+func (app *Application) AdminCreateCoupon() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var coupon models.Coupon
+		if err := c.ShouldBindJSON(&coupon); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		coupon.Code = normalizeCouponCode(coupon.Code)
+		if err := pricing.ValidateCoupon(coupon); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		coupon.Uses = 0
+		coupon.User_Uses = nil
+		coupon.Created_At = time.Now().UTC()
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := app.coupons.CreateCoupon(ctx, coupon); err != nil {
+			log.Println(err)
+			c.IndentedJSON(couponErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusCreated, coupon)
+	}
+}
+
+// localhost:8000/admin/coupons/{code}
+//
+// Replaces the definition of a coupon; its usage counts are kept.
+func (app *Application) AdminUpdateCoupon() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var coupon models.Coupon
+		if err := c.ShouldBindJSON(&coupon); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		coupon.Code = normalizeCouponCode(c.Param("code"))
+		if err := pricing.ValidateCoupon(coupon); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		updated, err := app.coupons.UpdateCoupon(ctx, coupon)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(couponErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, updated)
+	}
+}
+
+// localhost:8000/admin/coupons
+func (app *Application) AdminListCoupons() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		coupons, err := app.coupons.ListCoupons(ctx)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, coupons)
+	}
+}
+
+// localhost:8000/admin/coupons/{code}
+func (app *Application) AdminGetCoupon() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		coupon, err := app.coupons.FindCoupon(ctx, normalizeCouponCode(c.Param("code")))
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(couponErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, coupon)
+	}
+}
+
+// isCouponRejection reports whether err says a coupon can't be used on a
+// cart, as opposed to a failure to look it up.
+func isCouponRejection(err error) bool {
+	return errors.Is(err, pricing.ErrCouponDisabled) || errors.Is(err, pricing.ErrCouponNotStarted) ||
+		errors.Is(err, pricing.ErrCouponExpired) || errors.Is(err, pricing.ErrCouponMinimumNotMet) ||
+		errors.Is(err, pricing.ErrCouponNotApplicable)
+}
+
+func couponErrorStatus(err error) int {
+	switch {
+	case isCouponRejection(err):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, database.ErrUserIdIsNotValid), errors.Is(err, database.ErrCartIsEmpty):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrCouponExists), errors.Is(err, database.ErrCouponUsedUp):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrCouponNotFound), errors.Is(err, database.ErrUserNotFound),
+		errors.Is(err, database.ErrAddressNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/currency"
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"github.com/gin-gonic/gin"
+)
+
+// productView is a product with its price in the currency the customer asked
+// for. Variant_Prices holds the price of every variant by SKU.
+type productView struct {
+	models.Product
+	Currency       string           `json:"currency"`
+	Display_Price  int64            `json:"display_price"`
+	Variant_Prices map[string]int64 `json:"variant_prices,omitempty"`
+}
+
+// resolveCurrency returns the currency named by code, the base currency when
+// it's empty, aborting the request when it isn't supported.
+func (app *Application) resolveCurrency(c *gin.Context, code string) (string, bool) {
+	resolved, err := app.config.Currency.Resolve(code)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return resolved, true
+}
+
+// viewProducts prices products in code.
+func (app *Application) viewProducts(products []models.Product, code string) ([]productView, error) {
+	converter := app.config.Currency
+	views := make([]productView, len(products))
+	for i, product := range products {
+		price, err := converter.PriceOf(product.Price, product.Prices, code)
+		if err != nil {
+			return nil, err
+		}
+		view := productView{Product: product, Currency: code, Display_Price: price}
+		for _, variant := range product.Variants {
+			variantPrice := price
+			if variant.Price != nil {
+				if variantPrice, err = converter.PriceOf(variant.Price, variant.Prices, code); err != nil {
+					return nil, err
+				}
+			}
+			if view.Variant_Prices == nil {
+				view.Variant_Prices = make(map[string]int64, len(product.Variants))
+			}
+			view.Variant_Prices[variant.SKU] = variantPrice
+		}
+		views[i] = view
+	}
+	return views, nil
+}
+
+// validateProductPrices checks the fixed prices of a new product and its
+// variants.
+func validateProductPrices(converter *currency.Converter, product models.Product) error {
+	if err := validatePrices(converter, product.Prices); err != nil {
+		return err
+	}
+	for _, variant := range product.Variants {
+		if variant.Price == nil && len(variant.Prices) > 0 {
+			return fmt.Errorf("variant %s has prices but no price of its own", variant.SKU)
+		}
+		if err := validatePrices(converter, variant.Prices); err != nil {
+			return fmt.Errorf("variant %s: %w", variant.SKU, err)
+		}
+	}
+	return nil
+}
+
+// validatePrices checks fixed prices: one per supported currency other than
+// the base one, none negative.
+func validatePrices(converter *currency.Converter, prices []models.Money) error {
+	seen := make(map[string]bool, len(prices))
+	for _, price := range prices {
+		switch {
+		case !currency.ValidCode(price.Currency):
+			return fmt.Errorf("price currency %q must be a 3-letter ISO 4217 code", price.Currency)
+		case price.Currency == converter.Base():
+			return fmt.Errorf("the %s price is set with price, not prices", price.Currency)
+		case !converter.Supported(price.Currency):
+			return fmt.Errorf("%w: %s", currency.ErrUnsupportedCurrency, price.Currency)
+		case seen[price.Currency]:
+			return fmt.Errorf("%s is priced twice", price.Currency)
+		case price.Amount < 0:
+			return fmt.Errorf("the %s price can't be negative", price.Currency)
+		}
+		seen[price.Currency] = true
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"github.com/ChandanJnv/ecommerce-cart-golang/pricing"
+	"github.com/gin-gonic/gin"
+)
+
+// guestCartCookie names both the cookie and the header carrying the token of
+// an anonymous shopper's cart.
+const guestCartCookie = "cart_token"
+
+// GuestCartToken returns the cart token sent by the client, preferring the
+// header over the cookie.
+func GuestCartToken(c *gin.Context) string {
+	if token := c.Request.Header.Get(guestCartCookie); token != "" {
+		return token
+	}
+	token, _ := c.Cookie(guestCartCookie)
+	return token
+}
+
+func newGuestCartToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// localhost:8000/guest/addtocart?id={product_id}&variant={sku}&quantity={quantity}
+//
+// Starts a guest cart when no cart_token is sent; the new token is returned
+// in the response and set as a cookie.
+func (app *Application) GuestAddToCart() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productID, ok := productQuery(c)
+		if !ok {
+			return
+		}
+		quantity, ok := quantityQuery(c, 1)
+		if !ok {
+			return
+		}
+
+		cartToken := GuestCartToken(c)
+		if cartToken == "" {
+			token, err := newGuestCartToken()
+			if err != nil {
+				log.Println(err)
+				c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": "failed to create the cart"})
+				return
+			}
+			cartToken = token
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := app.guestCarts.AddProductToGuestCart(ctx, productID, c.Query("variant"), cartToken, quantity); err != nil {
+			log.Println(err)
+			c.IndentedJSON(cartErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.SetCookie(guestCartCookie, cartToken, int((30 * 24 * time.Hour).Seconds()), "/", "", false, true)
+		c.IndentedJSON(http.StatusOK, gin.H{"message": "Successfully added to the cart", "cart_token": cartToken})
+	}
+}
+
+// localhost:8000/guest/cart?currency={currency}
+func (app *Application) GuestGetCart() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currency, ok := app.resolveCurrency(c, c.Query("currency"))
+		if !ok {
+			return
+		}
+		cartToken := GuestCartToken(c)
+		if cartToken == "" {
+			c.IndentedJSON(http.StatusOK, cartResponse{Quote: pricing.NewQuote(nil, currency, nil)})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		cart, err := app.guestCarts.GetGuestCart(ctx, cartToken)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		// Guests haven't said where the cart ships to yet; tax and
+		// shipping are estimated for the catch-all zones.
+		quote, err := app.quoteCart(ctx, "", cart, "", models.Address{}, currency)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		response := cartResponse{Quote: quote}
+		if err := app.shipCart(ctx, &response, models.Address{}, ""); err != nil {
+			log.Println(err)
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, response)
+	}
+}
+
+// localhost:8000/guest/cart/quantity?id={product_id}&quantity={quantity}
+func (app *Application) GuestSetCartQuantity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productID, ok := productQuery(c)
+		if !ok {
+			return
+		}
+		quantity, ok := quantityQuery(c, -1)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := app.guestCarts.SetGuestCartItemQuantity(ctx, productID, c.Query("variant"), GuestCartToken(c), quantity); err != nil {
+			log.Println(err)
+			c.IndentedJSON(cartErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, "Successfully updated the quantity")
+	}
+}
+
+// localhost:8000/guest/cart/decrement?id={product_id}&quantity={quantity}
+func (app *Application) GuestDecrementCartItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productID, ok := productQuery(c)
+		if !ok {
+			return
+		}
+		quantity, ok := quantityQuery(c, 1)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := app.guestCarts.DecrementGuestCartItem(ctx, productID, c.Query("variant"), GuestCartToken(c), quantity); err != nil {
+			log.Println(err)
+			c.IndentedJSON(cartErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, "Successfully updated the quantity")
+	}
+}
+
+// localhost:8000/guest/removeitem?id={product_id}
+func (app *Application) GuestRemoveItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productID, ok := productQuery(c)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := app.guestCarts.RemoveGuestCartItem(ctx, productID, c.Query("variant"), GuestCartToken(c)); err != nil {
+			log.Println(err)
+			c.IndentedJSON(cartErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, "Successfully removed item from the cart")
+	}
+}
+
+// mergeGuestCart folds the guest cart sent with a login request into the
+// user's cart. Failures are logged so they never block the login itself.
+func (app *Application) mergeGuestCart(ctx context.Context, c *gin.Context, userID string) {
+	cartToken := GuestCartToken(c)
+	if cartToken == "" {
+		return
+	}
+
+	if err := app.guestCarts.MergeGuestCart(ctx, cartToken, userID, app.config.CartMergeStrategy); err != nil {
+		log.Println("failed to merge the guest cart:", err)
+		return
+	}
+	c.SetCookie(guestCartCookie, "", -1, "/", "", false, true)
+}
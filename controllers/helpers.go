@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/pricing"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// cartResponse is a cart priced with its promotions, coupon and shipping.
+// Coupon_Error says why the coupon applied to the cart no longer takes
+// anything off, if it doesn't, and Shipping_Error why the cart can't ship.
+type cartResponse struct {
+	pricing.Quote
+	Coupon         string `json:"coupon,omitempty"`
+	Coupon_Error   string `json:"coupon_error,omitempty"`
+	Shipping_Error string `json:"shipping_error,omitempty"`
+}
+
+// cartItemQuery reads the id and userID query parameters shared by the cart
+// routes, aborting the request when either is missing or malformed.
+func cartItemQuery(c *gin.Context) (primitive.ObjectID, string, bool) {
+	productID, ok := productQuery(c)
+	if !ok {
+		return primitive.NilObjectID, "", false
+	}
+
+	userQueryID := c.Query("userID")
+	if userQueryID == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "user id is empty"})
+		return primitive.NilObjectID, "", false
+	}
+
+	return productID, userQueryID, true
+}
+
+// productQuery reads the product id query parameter.
+func productQuery(c *gin.Context) (primitive.ObjectID, bool) {
+	productQueryID := c.Query("id")
+	if productQueryID == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "product id is empty"})
+		return primitive.NilObjectID, false
+	}
+
+	productID, err := primitive.ObjectIDFromHex(productQueryID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "product id is not valid"})
+		return primitive.NilObjectID, false
+	}
+
+	return productID, true
+}
+
+// quantityQuery reads the quantity query parameter. A negative defaultValue
+// makes the parameter required.
+func quantityQuery(c *gin.Context, defaultValue int) (int, bool) {
+	raw := c.Query("quantity")
+	if raw == "" {
+		if defaultValue < 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "quantity is empty"})
+			return 0, false
+		}
+		return defaultValue, true
+	}
+
+	quantity, err := strconv.Atoi(raw)
+	if err != nil || quantity < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": database.ErrInvalidQuantity.Error()})
+		return 0, false
+	}
+	return quantity, true
+}
+
+func cartErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrInvalidQuantity), errors.Is(err, database.ErrUserIdIsNotValid),
+		errors.Is(err, database.ErrGuestCartTokenIsNotValid), errors.Is(err, database.ErrVariantRequired),
+		errors.Is(err, database.ErrVariantNotFound):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrInsufficientStock):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrCartItemNotFound), errors.Is(err, database.ErrUserNotFound),
+		errors.Is(err, database.ErrAddressNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
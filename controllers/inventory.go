@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var errInvalidStockChange = errors.New("send either delta or stock")
+
+type stockResponse struct {
+	Product_ID  primitive.ObjectID       `json:"product_id"`
+	Stock       int64                    `json:"stock"`
+	Variants    []models.Variant         `json:"variants,omitempty"`
+	Adjustments []models.StockAdjustment `json:"adjustments"`
+}
+
+type stockChangeRequest struct {
+	Variant string `json:"variant"`
+	// Delta adds to the stock, or takes from it when negative.
+	Delta *int64 `json:"delta"`
+	// Stock overwrites the stock count, e.g. after counting it.
+	Stock *int64 `json:"stock"`
+	Note  string `json:"note"`
+}
+
+// validateProduct checks the stock counts, weight and variants of a new
+// product.
+func validateProduct(product models.Product) error {
+	if product.Stock < 0 {
+		return database.ErrNegativeStock
+	}
+	if product.Weight < 0 {
+		return errors.New("weight can't be negative")
+	}
+	seen := make(map[string]bool, len(product.Variants))
+	for _, variant := range product.Variants {
+		if variant.SKU == "" || seen[variant.SKU] {
+			return errors.New("every variant needs a unique sku")
+		}
+		if variant.Stock < 0 {
+			return database.ErrNegativeStock
+		}
+		seen[variant.SKU] = true
+	}
+	return nil
+}
+
+// productIDParam reads the product id path parameter.
+func productIDParam(c *gin.Context) (primitive.ObjectID, bool) {
+	productID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "product id is not valid"})
+		return primitive.NilObjectID, false
+	}
+	return productID, true
+}
+
+// localhost:8000/admin/products/{product_id}/stock
+//
+// Returns the stock of a product and its variants with the audit trail of
+// every change, newest first.
+func (app *Application) AdminGetStock() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productID, ok := productIDParam(c)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		product, err := app.products.FindProduct(ctx, productID)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(stockErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		adjustments, err := app.inventory.ListStockAdjustments(ctx, productID)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(stockErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, stockResponse{
+			Product_ID:  product.Product_ID,
+			Stock:       product.Stock,
+			Variants:    product.Variants,
+			Adjustments: adjustments,
+		})
+	}
+}
+
+// localhost:8000/admin/products/{product_id}/stock
+//
+//	{
+//	    "variant": "tshirt-red-m",
+//	    "delta": 25,
+//	    "note": "delivery from supplier"
+//	}
+func (app *Application) AdminAdjustStock() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productID, ok := productIDParam(c)
+		if !ok {
+			return
+		}
+
+		var request stockChangeRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if (request.Delta == nil) == (request.Stock == nil) {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": errInvalidStockChange.Error()})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		key := models.ItemKey{Product_ID: productID, Variant: request.Variant}
+		var adjustment models.StockAdjustment
+		var err error
+		if request.Delta != nil {
+			adjustment, err = app.inventory.AdjustStock(ctx, key, *request.Delta, request.Note)
+		} else {
+			adjustment, err = app.inventory.SetStock(ctx, key, *request.Stock, request.Note)
+		}
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(stockErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, adjustment)
+	}
+}
+
+type priceChangeRequest struct {
+	Variant string         `json:"variant"`
+	Price   *int64         `json:"price"`
+	Prices  []models.Money `json:"prices"`
+}
+
+// localhost:8000/admin/products/{product_id}/price
+//
+//	{
+//	    "variant": "tshirt-red-l",
+//	    "price": 27500,
+//	    "prices": [{"amount": 349, "currency": "USD"}]
+//	}
+//
+// Sets the price of a product, or of one of its variants. Carts that hold it
+// are asked to accept the new price at checkout.
+func (app *Application) AdminUpdatePrice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productID, ok := productIDParam(c)
+		if !ok {
+			return
+		}
+
+		var request priceChangeRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if request.Price == nil || *request.Price < 0 {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "price must be zero or more"})
+			return
+		}
+		if err := validatePrices(app.config.Currency, request.Prices); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		key := models.ItemKey{Product_ID: productID, Variant: request.Variant}
+		product, err := app.products.UpdateProductPrice(ctx, key, *request.Price, request.Prices)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(stockErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, product)
+	}
+}
+
+func stockErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrVariantRequired), errors.Is(err, database.ErrVariantNotFound):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrNegativeStock):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrCantFindProduct):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,372 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"github.com/ChandanJnv/ecommerce-cart-golang/payments"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var errReturnNeedsRequest = errors.New("orders are returned through their return requests, see /orders/{order_id}/returns")
+
+const (
+	defaultOrdersPageSize = 20
+	maxOrdersPageSize     = 100
+)
+
+type ordersPage struct {
+	Orders []models.Order `json:"orders"`
+	Page   int            `json:"page"`
+	Limit  int            `json:"limit"`
+	Total  int64          `json:"total"`
+}
+
+// localhost:8000/orders?page={page}&limit={limit}
+//
+// Lists the orders of the logged in user, newest first.
+func (app *Application) ListOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		page, err := positiveQuery(c, "page", 1)
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		limit, err := positiveQuery(c, "limit", defaultOrdersPageSize)
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if limit > maxOrdersPageSize {
+			limit = maxOrdersPageSize
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		orders, total, err := app.orders.ListOrders(ctx, c.GetString("uid"), page, limit)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(orderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, ordersPage{Orders: orders, Page: page, Limit: limit, Total: total})
+	}
+}
+
+// localhost:8000/orders/{order_id}
+func (app *Application) GetOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, ok := orderIDParam(c)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		order, err := app.orders.FindOrder(ctx, c.GetString("uid"), orderID)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(orderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, order)
+	}
+}
+
+// localhost:8000/orders/{order_id}/cancel
+//
+//	{
+//	    "reason": "ordered by mistake"
+//	}
+//
+// Customers can cancel their orders until they are shipped.
+func (app *Application) CancelOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, ok := orderIDParam(c)
+		if !ok {
+			return
+		}
+
+		var body struct {
+			Reason string `json:"reason"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&body); err != nil {
+				c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if body.Reason == "" {
+			body.Reason = "cancelled by customer"
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Looking the order up first makes sure it belongs to the user.
+		if _, err := app.orders.FindOrder(ctx, c.GetString("uid"), orderID); err != nil {
+			log.Println(err)
+			c.IndentedJSON(orderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		order, err := app.cancelOrder(ctx, orderID, body.Reason)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(orderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, order)
+	}
+}
+
+// cancelOrder cancels an order and refunds it when it had already been paid.
+// A payment still authorized or pending is voided instead, so it can't be
+// captured once the order is gone.
+func (app *Application) cancelOrder(ctx context.Context, orderID primitive.ObjectID, reason string) (models.Order, error) {
+	order, err := app.orders.UpdateOrderStatus(ctx, orderID, models.OrderCancelled, reason)
+	if err != nil {
+		return order, err
+	}
+	app.releaseStock(ctx, order)
+	if order.Coupon_Code != "" {
+		if err := app.coupons.ReleaseCoupon(ctx, order.Coupon_Code, order.User_ID); err != nil {
+			log.Println("failed to release coupon", order.Coupon_Code, "of order", order.Order_ID.Hex(), err)
+		}
+	}
+
+	if voidable(order.Payment_method) {
+		voided, err := app.voidPayment(ctx, order.Payment_method)
+		if err != nil {
+			// The payment stays as it is; should it still go through, the
+			// payment webhook pays it back.
+			log.Println("failed to void payment", order.Payment_method.Reference, "of order", orderID.Hex(), err)
+			return order, nil
+		}
+		return app.orders.UpdatePayment(ctx, orderID, voided)
+	}
+	if previousStatus(order) != models.OrderPaid || orderPaidAmount(order) <= 0 {
+		return order, nil
+	}
+
+	return app.orders.AddRefund(ctx, orderID, app.refund(ctx, order, orderPaidAmount(order), reason))
+}
+
+// releaseStock puts the items of an order that won't be fulfilled back into
+// stock. The order has already changed by then, so failures are only logged.
+func (app *Application) releaseStock(ctx context.Context, order models.Order) {
+	err := app.inventory.ReleaseReservation(ctx, order.Order_ID)
+	if errors.Is(err, database.ErrReservationNotFound) {
+		// Orders placed before reservations took their stock directly.
+		err = app.inventory.ReleaseStock(ctx, order.Order_ID, order.Order_Cart)
+	}
+	if err != nil {
+		log.Println("failed to release the stock of order", order.Order_ID.Hex(), err)
+	}
+}
+
+// refund pays amount of an order back through the payment provider. A
+// failed refund is still returned, marked as failed, so it gets recorded and
+// can be followed up by hand.
+func (app *Application) refund(ctx context.Context, order models.Order, amount int64, reason string) models.Refund {
+	refund, err := app.config.Payments.Refund(ctx, order, amount, reason)
+	if err != nil {
+		log.Println("refund failed for order", order.Order_ID.Hex(), err)
+		refund = models.Refund{
+			Refund_ID:  primitive.NewObjectID(),
+			Amount:     amount,
+			Reason:     reason,
+			Status:     models.RefundFailed,
+			Created_At: time.Now().UTC(),
+		}
+	}
+	return refund
+}
+
+// voidable reports whether a payment can still be voided rather than
+// refunded.
+func voidable(payment models.Payment) bool {
+	return payment.Status == models.PaymentAuthorized || payment.Status == models.PaymentPending
+}
+
+// voidPayment cancels a payment that was authorized but not captured through
+// the payment provider.
+func (app *Application) voidPayment(ctx context.Context, payment models.Payment) (models.Payment, error) {
+	voided, err := app.config.Payments.Void(ctx, payment)
+	if err != nil {
+		return payment, err
+	}
+	voided.Status = models.PaymentVoided
+	return voided, nil
+}
+
+// orderPaidAmount returns what the customer paid for an order.
+func orderPaidAmount(order models.Order) int64 {
+	if order.Price == nil {
+		return 0
+	}
+	return *order.Price
+}
+
+// previousStatus returns the status an order had before its current one.
+func previousStatus(order models.Order) models.OrderStatus {
+	if len(order.Status_History) < 2 {
+		return ""
+	}
+	return order.Status_History[len(order.Status_History)-2].Status
+}
+
+// localhost:8000/admin/orders/{order_id}
+func (app *Application) AdminGetOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, ok := orderIDParam(c)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		order, err := app.orders.FindOrderByID(ctx, orderID)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(orderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, order)
+	}
+}
+
+// localhost:8000/admin/orders/{order_id}/status
+//
+//	{
+//	    "status": "shipped",
+//	    "note": "handed over to the courier"
+//	}
+//
+// Statuses that settle the payment or the stock of the order go through the
+// same steps as checkout: paid sells the reserved stock, failed and
+// cancelled put it back. Returns have their own workflow.
+func (app *Application) UpdateOrderStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, ok := orderIDParam(c)
+		if !ok {
+			return
+		}
+
+		var body struct {
+			Status models.OrderStatus `json:"status" binding:"required"`
+			Note   string             `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		order, err := app.setOrderStatus(ctx, orderID, body.Status, body.Note)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(orderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, order)
+	}
+}
+
+// setOrderStatus moves an order to status on behalf of an admin.
+func (app *Application) setOrderStatus(ctx context.Context, orderID primitive.ObjectID, status models.OrderStatus, note string) (models.Order, error) {
+	switch status {
+	case models.OrderCancelled:
+		return app.cancelOrder(ctx, orderID, note)
+	case models.OrderReturned:
+		return models.Order{}, errReturnNeedsRequest
+	}
+
+	order, err := app.orders.FindOrderByID(ctx, orderID)
+	if err != nil {
+		return order, err
+	}
+	if !order.Status.CanTransitionTo(status) {
+		return order, fmt.Errorf("%w: an order that is %s cannot become %s", database.ErrInvalidOrderTransition, order.Status, status)
+	}
+
+	payment := order.Payment_method
+	switch status {
+	case models.OrderPaid:
+		payment.Status = models.PaymentCaptured
+		return app.markOrderPaid(ctx, orderID, payment, note)
+	case models.OrderFailed:
+		if voidable(payment) {
+			if payment, err = app.voidPayment(ctx, payment); err != nil {
+				return order, err
+			}
+		}
+		return app.checkout.MarkOrderFailed(ctx, orderID, payment, note)
+	case models.OrderShipped:
+		if !order.Shippable() {
+			return order, database.ErrOrderNotShippable
+		}
+	}
+	return app.orders.UpdateOrderStatus(ctx, orderID, status, note)
+}
+
+// orderIDParam reads the :id path parameter of the order routes.
+func orderIDParam(c *gin.Context) (primitive.ObjectID, bool) {
+	orderID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "order id is not valid"})
+		return primitive.NilObjectID, false
+	}
+	return orderID, true
+}
+
+// positiveQuery reads a positive integer query parameter.
+func positiveQuery(c *gin.Context, name string, defaultValue int) (int, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value <= 0 {
+		return 0, errors.New(name + " must be a positive number")
+	}
+	return value, nil
+}
+
+func orderErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrUserIdIsNotValid), errors.Is(err, database.ErrCartIsEmpty),
+		errors.Is(err, database.ErrInvalidOrderStatus), errors.Is(err, payments.ErrMethodNotSupported),
+		errors.Is(err, database.ErrVariantRequired), errors.Is(err, database.ErrVariantNotFound):
+		return http.StatusBadRequest
+	case errors.Is(err, payments.ErrDeclined):
+		return http.StatusPaymentRequired
+	case errors.Is(err, payments.ErrTimeout):
+		return http.StatusGatewayTimeout
+	case isCouponRejection(err), isShippingRejection(err):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, database.ErrInvalidOrderTransition), errors.Is(err, database.ErrInsufficientStock),
+		errors.Is(err, database.ErrCouponUsedUp), errors.Is(err, database.ErrOrderNotShippable),
+		errors.Is(err, errReturnNeedsRequest):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrOrderNotFound), errors.Is(err, database.ErrUserNotFound),
+		errors.Is(err, database.ErrCantFindProduct), errors.Is(err, database.ErrCouponNotFound),
+		errors.Is(err, database.ErrAddressNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
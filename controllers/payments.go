@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"github.com/ChandanJnv/ecommerce-cart-golang/payments"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxWebhookBody caps the size of a webhook request.
+const maxWebhookBody = 1 << 20
+
+var errPaymentMismatch = errors.New("the event does not match the payment of the order")
+
+// localhost:8000/payments/webhook
+//
+// Receives payment events signed with PAYMENT_WEBHOOK_SECRET. Every event is
+// handled once; redeliveries of an event ID are acknowledged without effect.
+func (app *Application) PaymentWebhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(app.config.WebhookSecret) == 0 {
+			c.IndentedJSON(http.StatusForbidden, gin.H{"error": "the payment webhook is not configured"})
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "cannot read the request body"})
+			return
+		}
+		signature := c.GetHeader(payments.SignatureHeader)
+		if err := payments.VerifySignature(app.config.WebhookSecret, body, signature, time.Now()); err != nil {
+			c.IndentedJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		var event models.PaymentEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if event.Event_ID == "" || event.Order_ID.IsZero() || !event.Type.Valid() {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "the event needs an id, an order_id and a known type"})
+			return
+		}
+		event.Received_At = time.Now().UTC()
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := app.events.RecordPaymentEvent(ctx, event); err != nil {
+			if errors.Is(err, database.ErrDuplicatePaymentEvent) {
+				c.IndentedJSON(http.StatusOK, gin.H{"message": "event already processed"})
+				return
+			}
+			log.Println(err)
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		message, err := app.applyPaymentEvent(ctx, event)
+		if err != nil {
+			// Forget the event so the provider's redelivery is handled again.
+			if forgetErr := app.events.ForgetPaymentEvent(ctx, event.Event_ID); forgetErr != nil {
+				log.Println(forgetErr)
+			}
+			log.Println(err)
+			c.IndentedJSON(paymentEventErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, gin.H{"message": message})
+	}
+}
+
+// applyPaymentEvent settles the pending payment of an order: it becomes
+// captured and the order paid, or declined and the order failed. Money taken
+// for an order that was cancelled or failed in the meantime is paid back.
+// Other events for orders that are no longer waiting on their payment are
+// ignored.
+func (app *Application) applyPaymentEvent(ctx context.Context, event models.PaymentEvent) (string, error) {
+	order, err := app.orders.FindOrderByID(ctx, event.Order_ID)
+	if err != nil {
+		return "", err
+	}
+
+	payment := order.Payment_method
+	if event.Reference != payment.Reference {
+		return "", fmt.Errorf("%w: unknown payment reference", errPaymentMismatch)
+	}
+	if event.Type == models.PaymentEventSucceeded && payment.Status != models.PaymentCaptured &&
+		(order.Status == models.OrderCancelled || order.Status == models.OrderFailed) {
+		return app.refundLatePayment(ctx, order, event)
+	}
+	if order.Status != models.OrderPlaced || payment.Status != models.PaymentPending {
+		log.Printf("ignoring payment event %s for order %s that is %s", event.Event_ID, order.Order_ID.Hex(), order.Status)
+		return fmt.Sprintf("ignored, the order is %s", order.Status), nil
+	}
+
+	if event.Type == models.PaymentEventFailed {
+		payment.Status = models.PaymentDeclined
+		if _, err := app.checkout.MarkOrderFailed(ctx, order.Order_ID, payment, "payment failed"); err != nil {
+			return "", err
+		}
+		return "order is " + string(models.OrderFailed), nil
+	}
+
+	if event.Amount != payment.Amount {
+		return "", fmt.Errorf("%w: the amount differs", errPaymentMismatch)
+	}
+	payment.Status = models.PaymentCaptured
+	paid, err := app.markOrderPaid(ctx, order.Order_ID, payment, "payment confirmed")
+	if errors.Is(err, database.ErrInsufficientStock) {
+		return fmt.Sprintf("refunded, the order is %s", paid.Status), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return "order is " + string(models.OrderPaid), nil
+}
+
+// markOrderPaid marks an order paid with its captured payment. When the
+// stock of the order ran out before the payment arrived, the order is failed
+// instead and the payment paid back.
+func (app *Application) markOrderPaid(ctx context.Context, orderID primitive.ObjectID, payment models.Payment, note string) (models.Order, error) {
+	order, err := app.checkout.MarkOrderPaid(ctx, orderID, payment, note)
+	if !errors.Is(err, database.ErrInsufficientStock) {
+		return order, err
+	}
+
+	log.Println("order", orderID.Hex(), "failed, its stock ran out before the payment arrived:", err)
+	refunded, refundErr := app.orders.AddRefund(ctx, orderID, app.refund(ctx, order, payment.Amount, "out of stock"))
+	if refundErr != nil {
+		log.Println("failed to record the refund of order", orderID.Hex(), refundErr)
+		return order, err
+	}
+	return refunded, err
+}
+
+// refundLatePayment records a payment that went through after its order was
+// cancelled or failed, and refunds it.
+func (app *Application) refundLatePayment(ctx context.Context, order models.Order, event models.PaymentEvent) (string, error) {
+	log.Printf("payment event %s captured %d for order %s that is %s, refunding it", event.Event_ID, event.Amount, order.Order_ID.Hex(), order.Status)
+
+	payment := order.Payment_method
+	payment.Status = models.PaymentCaptured
+	payment.Amount = event.Amount
+	order, err := app.orders.UpdatePayment(ctx, order.Order_ID, payment)
+	if err != nil {
+		return "", err
+	}
+	reason := fmt.Sprintf("payment received after the order was %s", order.Status)
+	if _, err := app.orders.AddRefund(ctx, order.Order_ID, app.refund(ctx, order, event.Amount, reason)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("refunded, the order is %s", order.Status), nil
+}
+
+func paymentEventErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrOrderNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errPaymentMismatch):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrInvalidOrderTransition):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
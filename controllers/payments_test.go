@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"github.com/ChandanJnv/ecommerce-cart-golang/payments"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var webhookSecret = []byte("whsec")
+
+// failingCheckout is a checkout store whose MarkOrderPaid fails while
+// failures is above zero, counting it down.
+type failingCheckout struct {
+	database.CheckoutStore
+	failures int
+}
+
+func (s *failingCheckout) MarkOrderPaid(ctx context.Context, orderID primitive.ObjectID, payment models.Payment, note string) (models.Order, error) {
+	if s.failures > 0 {
+		s.failures--
+		return models.Order{}, errors.New("injected failure")
+	}
+	return s.CheckoutStore.MarkOrderPaid(ctx, orderID, payment, note)
+}
+
+// newWebhookTest returns an application taking webhook events, a router
+// with the webhook and an order of 400 waiting on the card payment with the
+// reference "pay_1".
+func newWebhookTest(t *testing.T) (*Application, *gin.Engine, models.Order) {
+	t.Helper()
+	app, stores, user := newTestApp(t)
+	app.config.WebhookSecret = webhookSecret
+	router := gin.New()
+	router.POST("/payments/webhook", app.PaymentWebhook())
+
+	ctx := context.Background()
+	name, price := "laptop", int64(200)
+	product := models.Product{Product_ID: primitive.NewObjectID(), Product_Name: &name, Price: &price, Stock: 3}
+	if err := stores.Products.AddProduct(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	total := 2 * price
+	order := models.Order{
+		Order_ID:       primitive.NewObjectID(),
+		User_ID:        user.User_ID,
+		Order_Cart:     []models.ProductUser{models.NewProductUser(product, 2)},
+		Ordered_At:     time.Now().UTC(),
+		Price:          &total,
+		Status:         models.OrderPlaced,
+		Status_History: []models.StatusChange{{Status: models.OrderPlaced, Changed_At: time.Now().UTC()}},
+		Payment_method: models.Payment{Method: models.PaymentCard, Reference: "pay_1", Status: models.PaymentPending, Amount: total},
+	}
+	reservation := models.Reservation{
+		Order_ID:   order.Order_ID,
+		User_ID:    order.User_ID,
+		Items:      order.Order_Cart,
+		Status:     models.ReservationHeld,
+		Expires_At: time.Now().UTC().Add(time.Minute),
+		Created_At: time.Now().UTC(),
+	}
+	if err := stores.Checkout.PlaceOrder(ctx, order, reservation, false); err != nil {
+		t.Fatal(err)
+	}
+	return app, router, order
+}
+
+// paidEvent returns the event of the payment of order going through.
+func paidEvent(order models.Order) models.PaymentEvent {
+	return models.PaymentEvent{
+		Event_ID:   "evt_" + primitive.NewObjectID().Hex(),
+		Type:       models.PaymentEventSucceeded,
+		Order_ID:   order.Order_ID,
+		Reference:  order.Payment_method.Reference,
+		Amount:     order.Payment_method.Amount,
+		Created_At: time.Now().UTC(),
+	}
+}
+
+// deliver posts event to the webhook with the signature header, which
+// payments.Sign makes when it's empty.
+func deliver(t *testing.T, router http.Handler, event models.PaymentEvent, signature string) (int, map[string]string) {
+	t.Helper()
+	body, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signature == "" {
+		signature = payments.Sign(webhookSecret, body, time.Now())
+	}
+	req := httptest.NewRequest(http.MethodPost, "/payments/webhook", bytes.NewReader(body))
+	req.Header.Set(payments.SignatureHeader, signature)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var response map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, response
+}
+
+// expectOrder checks the status of order and of its payment.
+func expectOrder(t *testing.T, app *Application, order models.Order, status models.OrderStatus, payment models.PaymentStatus) {
+	t.Helper()
+	stored, err := app.orders.FindOrderByID(context.Background(), order.Order_ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != status || stored.Payment_method.Status != payment {
+		t.Errorf("order is %s with the payment %s, want %s and %s", stored.Status, stored.Payment_method.Status, status, payment)
+	}
+}
+
+func TestPaymentWebhookRejectsBadSignatures(t *testing.T) {
+	app, router, order := newWebhookTest(t)
+	event := paidEvent(order)
+	body, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signatures := map[string]string{
+		"other secret": payments.Sign([]byte("other"), body, time.Now()),
+		"stale":        payments.Sign(webhookSecret, body, time.Now().Add(-payments.SignatureTolerance-time.Minute)),
+		"garbled":      "t=1,v1=zz",
+	}
+	for name, signature := range signatures {
+		t.Run(name, func(t *testing.T) {
+			if code, response := deliver(t, router, event, signature); code != http.StatusUnauthorized {
+				t.Errorf("got %d %v, want 401", code, response)
+			}
+		})
+	}
+	expectOrder(t, app, order, models.OrderPlaced, models.PaymentPending)
+
+	// The refused deliveries weren't recorded: the event still goes through
+	// once properly signed.
+	if code, response := deliver(t, router, event, ""); code != http.StatusOK {
+		t.Fatalf("got %d %v, want 200", code, response)
+	}
+	expectOrder(t, app, order, models.OrderPaid, models.PaymentCaptured)
+}
+
+func TestPaymentWebhookIgnoresRedeliveries(t *testing.T) {
+	app, router, order := newWebhookTest(t)
+	event := paidEvent(order)
+
+	if code, response := deliver(t, router, event, ""); code != http.StatusOK || response["message"] != "order is paid" {
+		t.Fatalf("got %d %v, want the order paid", code, response)
+	}
+	paid, err := app.orders.FindOrderByID(context.Background(), order.Order_ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The provider sends the event again, e.g. when the acknowledgement was
+	// lost: it's acknowledged without being applied a second time.
+	if code, response := deliver(t, router, event, ""); code != http.StatusOK || response["message"] != "event already processed" {
+		t.Fatalf("redelivery: got %d %v, want it acknowledged", code, response)
+	}
+	stored, err := app.orders.FindOrderByID(context.Background(), order.Order_ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Status_History) != len(paid.Status_History) || len(stored.Refunds) != 0 {
+		t.Errorf("the redelivery changed the order: %d status changes and %d refunds", len(stored.Status_History), len(stored.Refunds))
+	}
+}
+
+func TestPaymentWebhookRetriesFailedEvents(t *testing.T) {
+	app, router, order := newWebhookTest(t)
+	app.checkout = &failingCheckout{CheckoutStore: app.checkout, failures: 1}
+	event := paidEvent(order)
+
+	if code, response := deliver(t, router, event, ""); code != http.StatusInternalServerError {
+		t.Fatalf("got %d %v, want 500", code, response)
+	}
+	expectOrder(t, app, order, models.OrderPlaced, models.PaymentPending)
+
+	// The failed event was forgotten, so the provider's redelivery is
+	// handled rather than acknowledged.
+	if code, response := deliver(t, router, event, ""); code != http.StatusOK || response["message"] != "order is paid" {
+		t.Fatalf("redelivery: got %d %v, want the order paid", code, response)
+	}
+	expectOrder(t, app, order, models.OrderPaid, models.PaymentCaptured)
+}
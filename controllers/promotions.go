@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"github.com/ChandanJnv/ecommerce-cart-golang/pricing"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// promotionIDParam reads the promotion id path parameter.
+func promotionIDParam(c *gin.Context) (primitive.ObjectID, bool) {
+	promotionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "promotion id is not valid"})
+		return primitive.NilObjectID, false
+	}
+	return promotionID, true
+}
+
+// bindPromotion reads and validates a promotion definition.
+func bindPromotion(c *gin.Context) (models.Promotion, bool) {
+	var promotion models.Promotion
+	if err := c.ShouldBindJSON(&promotion); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return promotion, false
+	}
+	if err := pricing.ValidatePromotion(promotion); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return promotion, false
+	}
+	return promotion, true
+}
+
+// localhost:8000/admin/promotions
+//
+//	{
+//	    "name": "10% off orders over 5000",
+//	    "type": "percentage",
+//	    "value": 10,
+//	    "min_cart_value": 5000,
+//	    "priority": 10
+//	}
+func (app *Application) AdminCreatePromotion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		promotion, ok := bindPromotion(c)
+		if !ok {
+			return
+		}
+		promotion.Promotion_ID = primitive.NewObjectID()
+		promotion.Created_At = time.Now().UTC()
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := app.promotions.CreatePromotion(ctx, promotion); err != nil {
+			log.Println(err)
+			c.IndentedJSON(promotionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusCreated, promotion)
+	}
+}
+
+// localhost:8000/admin/promotions/{promotion_id}
+func (app *Application) AdminUpdatePromotion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		promotionID, ok := promotionIDParam(c)
+		if !ok {
+			return
+		}
+		promotion, ok := bindPromotion(c)
+		if !ok {
+			return
+		}
+		promotion.Promotion_ID = promotionID
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		updated, err := app.promotions.UpdatePromotion(ctx, promotion)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(promotionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, updated)
+	}
+}
+
+// localhost:8000/admin/promotions/{promotion_id}
+func (app *Application) AdminDeletePromotion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		promotionID, ok := promotionIDParam(c)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := app.promotions.DeletePromotion(ctx, promotionID); err != nil {
+			log.Println(err)
+			c.IndentedJSON(promotionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, "Successfully deleted the promotion")
+	}
+}
+
+// localhost:8000/admin/promotions
+//
+// Lists every promotion, disabled ones included, in the order they apply.
+func (app *Application) AdminListPromotions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		promotions, err := app.promotions.ListPromotions(ctx, false)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, promotions)
+	}
+}
+
+// localhost:8000/admin/promotions/{promotion_id}
+func (app *Application) AdminGetPromotion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		promotionID, ok := promotionIDParam(c)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		promotion, err := app.promotions.FindPromotion(ctx, promotionID)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(promotionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, promotion)
+	}
+}
+
+func promotionErrorStatus(err error) int {
+	if errors.Is(err, database.ErrPromotionNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RunReservationSweeper releases expired stock holds every
+// ReservationSweepInterval until ctx is done.
+func (app *Application) RunReservationSweeper(ctx context.Context) {
+	ticker := time.NewTicker(app.config.ReservationSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.sweepReservations(ctx)
+		}
+	}
+}
+
+// sweepReservations fails the orders whose holds expired, as their payment
+// didn't arrive in time, and releases the holds after. Failing the order
+// first means a payment arriving meanwhile either finds the order failed or
+// its stock still held, never a paid order without stock.
+func (app *Application) sweepReservations(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	expired, err := app.inventory.ListExpiredReservations(ctx, now)
+	if err != nil {
+		log.Println("failed to list expired stock reservations:", err)
+	}
+	for _, reservation := range expired {
+		if err := app.failUnpaidOrder(ctx, reservation.Order_ID); err != nil {
+			log.Println("failed to fail unpaid order", reservation.Order_ID.Hex(), err)
+		}
+	}
+	// Failed orders released their holds already; what is left belongs to
+	// orders that were never stored or that couldn't be failed.
+	if _, err := app.inventory.ExpireReservations(ctx, now); err != nil {
+		log.Println("failed to release expired stock reservations:", err)
+	}
+}
+
+// failUnpaidOrder gives up on the payment of an order that is still waiting
+// for it: an authorization is voided and the order becomes failed.
+func (app *Application) failUnpaidOrder(ctx context.Context, orderID primitive.ObjectID) error {
+	order, err := app.orders.FindOrderByID(ctx, orderID)
+	if errors.Is(err, database.ErrOrderNotFound) {
+		// Checkout failed before the order was stored.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if order.Status != models.OrderPlaced {
+		return nil
+	}
+
+	payment := order.Payment_method
+	if voidable(payment) {
+		voided, err := app.voidPayment(ctx, payment)
+		if err != nil {
+			log.Println("failed to void payment", payment.Reference, err)
+			voided = payment
+			voided.Status = models.PaymentVoided
+		}
+		payment = voided
+	}
+	// Failing the order releases its hold too.
+	_, err = app.checkout.MarkOrderFailed(ctx, orderID, payment, "payment not received in time")
+	return err
+}
@@ -0,0 +1,335 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	errOrderNotReturnable = errors.New("only delivered orders can be returned")
+	errNoReturnItems      = errors.New("a return needs at least one item")
+	errInvalidReturnItem  = errors.New("the item is not part of the order or was already returned")
+	errInvalidReturnCode  = errors.New("unknown return reason")
+)
+
+type returnItemRequest struct {
+	Product_ID primitive.ObjectID `json:"product_id"`
+	Variant    string             `json:"variant"`
+	Quantity   int                `json:"quantity"`
+}
+
+// localhost:8000/orders/{order_id}/returns
+//
+//	{
+//	    "items": [{"product_id": "66d4330450820c57cfb26558", "quantity": 1}],
+//	    "reason": "damaged",
+//	    "comment": "screen is cracked"
+//	}
+func (app *Application) RequestReturn() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, ok := orderIDParam(c)
+		if !ok {
+			return
+		}
+
+		var body struct {
+			Items   []returnItemRequest `json:"items"`
+			Reason  models.ReturnReason `json:"reason"`
+			Comment string              `json:"comment"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !body.Reason.Valid() {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": errInvalidReturnCode.Error()})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		userID := c.GetString("uid")
+		order, err := app.orders.FindOrder(ctx, userID, orderID)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(orderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		if order.Status != models.OrderDelivered {
+			c.IndentedJSON(http.StatusConflict, gin.H{"error": errOrderNotReturnable.Error()})
+			return
+		}
+
+		previous, err := app.returns.ListOrderReturns(ctx, orderID)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		items, err := returnItems(order, previous, body.Items)
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		now := time.Now().UTC()
+		ret := models.ReturnRequest{
+			Return_ID:      primitive.NewObjectID(),
+			Order_ID:       orderID,
+			User_ID:        userID,
+			Items:          items,
+			Reason:         body.Reason,
+			Comment:        body.Comment,
+			Status:         models.ReturnRequested,
+			Status_History: []models.ReturnStatusChange{{Status: models.ReturnRequested, Changed_At: now}},
+			Created_At:     now,
+		}
+		if err := app.returns.CreateReturn(ctx, ret); err != nil {
+			log.Println(err)
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusCreated, ret)
+	}
+}
+
+// returnItems checks the requested items against the order lines and the
+// quantities already covered by earlier, non-rejected returns, and prices
+// them at the unit price the customer paid.
+func returnItems(order models.Order, previous []models.ReturnRequest, requested []returnItemRequest) ([]models.ReturnItem, error) {
+	if len(requested) == 0 {
+		return nil, errNoReturnItems
+	}
+
+	returned := returnedQuantities(previous, false)
+	items := make([]models.ReturnItem, 0, len(requested))
+	for _, req := range requested {
+		key := models.ItemKey{Product_ID: req.Product_ID, Variant: req.Variant}
+		i := orderLineIndex(order, key)
+		if i < 0 || req.Quantity <= 0 {
+			return nil, errInvalidReturnItem
+		}
+		line := order.Order_Cart[i]
+
+		returned[key] += req.Quantity
+		if returned[key] > line.Quantity {
+			return nil, errInvalidReturnItem
+		}
+
+		// Discounts are spread over the units of a line.
+		unitPrice := line.PaidTotal() / int64(line.Quantity)
+		items = append(items, models.ReturnItem{Product_ID: req.Product_ID, Variant: req.Variant, Quantity: req.Quantity, Unit_Price: unitPrice})
+	}
+	return items, nil
+}
+
+// returnedQuantities sums the quantities per order line of the returns that
+// weren't rejected, or only of the received ones when receivedOnly is set.
+func returnedQuantities(returns []models.ReturnRequest, receivedOnly bool) map[models.ItemKey]int {
+	quantities := make(map[models.ItemKey]int)
+	for _, ret := range returns {
+		if ret.Status == models.ReturnRejected || (receivedOnly && ret.Status != models.ReturnReceived) {
+			continue
+		}
+		for _, item := range ret.Items {
+			quantities[item.Key()] += item.Quantity
+		}
+	}
+	return quantities
+}
+
+func orderLineIndex(order models.Order, key models.ItemKey) int {
+	for i, line := range order.Order_Cart {
+		if line.Key() == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// localhost:8000/returns
+func (app *Application) ListReturns() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		returns, err := app.returns.ListReturns(ctx, c.GetString("uid"), "")
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, returns)
+	}
+}
+
+// localhost:8000/returns/{return_id}
+func (app *Application) GetReturn() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		returnID, ok := returnIDParam(c)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		ret, err := app.returns.FindReturn(ctx, returnID)
+		if err == nil && ret.User_ID != c.GetString("uid") {
+			err = database.ErrReturnNotFound
+		}
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(returnErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, ret)
+	}
+}
+
+// localhost:8000/admin/returns?status={status}
+func (app *Application) AdminListReturns() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		returns, err := app.returns.ListReturns(ctx, "", models.ReturnStatus(c.Query("status")))
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, returns)
+	}
+}
+
+// localhost:8000/admin/returns/{return_id}/approve
+func (app *Application) ApproveReturn() gin.HandlerFunc {
+	return app.changeReturnStatus(func(ctx context.Context, returnID primitive.ObjectID, note string) (models.ReturnRequest, error) {
+		return app.returns.UpdateReturnStatus(ctx, returnID, models.ReturnApproved, note)
+	})
+}
+
+// localhost:8000/admin/returns/{return_id}/reject
+func (app *Application) RejectReturn() gin.HandlerFunc {
+	return app.changeReturnStatus(func(ctx context.Context, returnID primitive.ObjectID, note string) (models.ReturnRequest, error) {
+		return app.returns.UpdateReturnStatus(ctx, returnID, models.ReturnRejected, note)
+	})
+}
+
+// localhost:8000/admin/returns/{return_id}/receive
+//
+// Marks the items of an approved return as received back and refunds them.
+func (app *Application) ReceiveReturn() gin.HandlerFunc {
+	return app.changeReturnStatus(app.receiveReturn)
+}
+
+// changeReturnStatus builds the admin handlers that move a return along.
+// They all take an optional note:
+//
+//	{
+//	    "note": "parcel arrived intact"
+//	}
+func (app *Application) changeReturnStatus(change func(ctx context.Context, returnID primitive.ObjectID, note string) (models.ReturnRequest, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		returnID, ok := returnIDParam(c)
+		if !ok {
+			return
+		}
+
+		var body struct {
+			Note string `json:"note"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&body); err != nil {
+				c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		ret, err := change(ctx, returnID, body.Note)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(returnErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, ret)
+	}
+}
+
+// receiveReturn marks a return as received and refunds the returned items,
+// never refunding more than is left of what was paid for the order. Once
+// every item of the order has come back the order becomes returned.
+func (app *Application) receiveReturn(ctx context.Context, returnID primitive.ObjectID, note string) (models.ReturnRequest, error) {
+	ret, err := app.returns.UpdateReturnStatus(ctx, returnID, models.ReturnReceived, note)
+	if err != nil {
+		return ret, err
+	}
+
+	order, err := app.orders.FindOrderByID(ctx, ret.Order_ID)
+	if err != nil {
+		return ret, err
+	}
+
+	amount := ret.ItemsTotal()
+	if remaining := orderPaidAmount(order) - order.Refunded; amount > remaining {
+		amount = remaining
+	}
+	if amount > 0 {
+		refund := app.refund(ctx, order, amount, "return "+ret.Return_ID.Hex())
+		if _, err := app.orders.AddRefund(ctx, order.Order_ID, refund); err != nil {
+			return ret, err
+		}
+		if ret, err = app.returns.SetReturnRefund(ctx, returnID, refund); err != nil {
+			return ret, err
+		}
+	}
+
+	returns, err := app.returns.ListOrderReturns(ctx, order.Order_ID)
+	if err != nil {
+		return ret, err
+	}
+	received := returnedQuantities(returns, true)
+	for _, line := range order.Order_Cart {
+		if received[line.Key()] < line.Quantity {
+			return ret, nil
+		}
+	}
+	if _, err := app.orders.UpdateOrderStatus(ctx, order.Order_ID, models.OrderReturned, "all items returned"); err != nil {
+		log.Println(err)
+	}
+	return ret, nil
+}
+
+func returnIDParam(c *gin.Context) (primitive.ObjectID, bool) {
+	returnID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "return id is not valid"})
+		return primitive.NilObjectID, false
+	}
+	return returnID, true
+}
+
+func returnErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrReturnNotFound), errors.Is(err, database.ErrOrderNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrInvalidReturnTransition):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	generate "github.com/ChandanJnv/ecommerce-cart-golang/tokens"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	errInvalidRefreshToken = errors.New("the refresh token is invalid")
+	errTokenNotRevocable   = errors.New("this token can't be revoked on its own, log out of all sessions instead")
+)
+
+// Revocations returns the token revocation list, for the authentication
+// middleware to check.
+func (app *Application) Revocations() *generate.RevocationList {
+	return app.revocations
+}
+
+// revokeSessions puts the sessions, revoked in the session store already,
+// on the revocation list, so the access tokens handed out for them stop
+// working too.
+func (app *Application) revokeSessions(ctx context.Context, reason string, sessions ...models.Session) error {
+	now := time.Now().UTC()
+	revocations := make([]models.Revocation, len(sessions))
+	for i, session := range sessions {
+		revocations[i] = models.Revocation{
+			Revocation_ID: session.Session_ID.Hex(),
+			Kind:          models.RevokedSession,
+			User_ID:       session.User_ID,
+			Reason:        reason,
+			Revoked_At:    now,
+			// Access tokens of the session were issued by now at the
+			// latest.
+			Expires_At: now.Add(generate.AccessTokenTTL),
+		}
+	}
+	return app.revocations.Revoke(ctx, revocations...)
+}
+
+// startSession starts a login session for user and returns its first access
+// and refresh tokens.
+func (app *Application) startSession(ctx context.Context, user models.User) (string, string, error) {
+	now := time.Now().UTC()
+	session := models.Session{
+		Session_ID: primitive.NewObjectID(),
+		User_ID:    user.User_ID,
+		Refresh_ID: generate.NewTokenID(),
+		Created_At: now,
+		Expires_At: now.Add(generate.RefreshTokenTTL),
+	}
+	token, refreshToken, err := generate.TokenGenerator(*user.Email, *user.First_Name, *user.Last_Name, user.User_ID, session.Session_ID.Hex(), session.Refresh_ID)
+	if err != nil {
+		return "", "", err
+	}
+	if err := app.sessions.CreateSession(ctx, session); err != nil {
+		return "", "", err
+	}
+	if err := generate.UpdateAllTokens(ctx, app.users, token, refreshToken, user.User_ID); err != nil {
+		return "", "", err
+	}
+	return token, refreshToken, nil
+}
+
+// localhost:8000/users/refresh
+//
+//	{
+//	    "refresh_token": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."
+//	}
+//
+// Trades a refresh token for a new access token and a new refresh token,
+// which replaces it: each refresh token works once. Presenting one that was
+// already traded means it was copied, so the whole session is revoked and
+// the user has to log in again.
+func (app *Application) RefreshToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Refresh_Token string `json:"refresh_token"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil || request.Refresh_Token == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+			return
+		}
+
+		claims, msg := generate.ValidateRefreshToken(request.Refresh_Token)
+		if msg != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+			return
+		}
+		sessionID, err := primitive.ObjectIDFromHex(claims.Session)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidRefreshToken.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		session, err := app.sessions.FindSession(ctx, sessionID)
+		var user models.User
+		if err == nil && session.User_ID != claims.Uid {
+			err = errInvalidRefreshToken
+		}
+		if err == nil {
+			user, err = app.users.FindUser(ctx, session.User_ID)
+		}
+		if err != nil {
+			log.Println(err)
+			c.JSON(refreshErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		refreshID := generate.NewTokenID()
+		token, refreshToken, err := generate.TokenGenerator(*user.Email, *user.First_Name, *user.Last_Name, user.User_ID, claims.Session, refreshID)
+		if err == nil {
+			err = app.sessions.RotateSession(ctx, sessionID, claims.Id, refreshID, time.Now().UTC().Add(generate.RefreshTokenTTL))
+		}
+		if errors.Is(err, database.ErrRefreshTokenReused) {
+			log.Println("refresh token reused, revoking session", claims.Session)
+			const reason = "refresh token reused"
+			revokeErr := app.sessions.RevokeSession(ctx, sessionID, reason)
+			if revokeErr == nil {
+				revokeErr = app.revokeSessions(ctx, reason, session)
+			}
+			if revokeErr != nil {
+				log.Println(revokeErr)
+			}
+		}
+		if err == nil {
+			err = generate.UpdateAllTokens(ctx, app.users, token, refreshToken, user.User_ID)
+		}
+		if err != nil {
+			log.Println(err)
+			c.JSON(refreshErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"token": token, "refresh_token": refreshToken})
+	}
+}
+
+// localhost:8000/users/logout
+//
+// Logs out of the session of the token: the token, the others handed out for
+// the session and its refresh token stop working at once.
+func (app *Application) Logout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		userID := c.GetString("uid")
+		const reason = "logout"
+		var err error
+		if sessionID, idErr := primitive.ObjectIDFromHex(c.GetString("session")); idErr == nil {
+			err = app.sessions.RevokeSession(ctx, sessionID, reason)
+			if err == nil {
+				err = app.revokeSessions(ctx, reason, models.Session{Session_ID: sessionID, User_ID: userID})
+			}
+		} else if tokenID := c.GetString("token_id"); tokenID != "" {
+			// Tokens without a session can only be revoked themselves.
+			err = app.revocations.Revoke(ctx, models.Revocation{
+				Revocation_ID: tokenID,
+				Kind:          models.RevokedToken,
+				User_ID:       userID,
+				Reason:        reason,
+				Revoked_At:    time.Now().UTC(),
+				Expires_At:    time.Unix(c.GetInt64("expires_at"), 0).UTC(),
+			})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errTokenNotRevocable.Error()})
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Successfully logged out"})
+	}
+}
+
+// localhost:8000/users/logout-all
+//
+// Logs out of every session of the user, on every device. Tokens handed out
+// before sessions existed aren't covered and run until they expire.
+func (app *Application) LogoutAll() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		const reason = "logout of all sessions"
+		sessions, err := app.sessions.RevokeUserSessions(ctx, c.GetString("uid"), reason)
+		if err == nil {
+			err = app.revokeSessions(ctx, reason, sessions...)
+		}
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Successfully logged out of all sessions", "sessions": len(sessions)})
+	}
+}
+
+func refreshErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errInvalidRefreshToken), errors.Is(err, database.ErrSessionNotFound),
+		errors.Is(err, database.ErrSessionRevoked), errors.Is(err, database.ErrRefreshTokenReused),
+		errors.Is(err, database.ErrUserNotFound):
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/middleware"
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	generate "github.com/ChandanJnv/ecommerce-cart-golang/tokens"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+	generate.SECRET_KEY = "test secret"
+}
+
+// newTestApp returns an application on memory stores with one user.
+func newTestApp(t *testing.T) (*Application, database.Stores, models.User) {
+	t.Helper()
+	stores := database.NewMemoryStores()
+	app := NewApplication(stores, Config{})
+
+	email, first, last := "a@b.com", "alpha", "beta"
+	id := primitive.NewObjectID()
+	user := models.User{ID: id, User_ID: id.Hex(), Email: &email, First_Name: &first, Last_Name: &last}
+	if err := stores.Users.CreateUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return app, stores, user
+}
+
+// serve sends a request with a JSON body, and the headers given as name and
+// value pairs, to router and returns the response.
+func serve(router http.Handler, method, path string, body any, headers ...string) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// refresh trades refreshToken at the refresh endpoint.
+func refresh(t *testing.T, router http.Handler, refreshToken string) (int, map[string]string) {
+	t.Helper()
+	rec := serve(router, http.MethodPost, "/users/refresh", gin.H{"refresh_token": refreshToken})
+	var response map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, response
+}
+
+func TestRefreshTokenRotates(t *testing.T) {
+	app, stores, user := newTestApp(t)
+	router := gin.New()
+	router.POST("/users/refresh", app.RefreshToken())
+
+	_, refreshToken, err := app.startSession(context.Background(), user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, response := refresh(t, router, refreshToken)
+	if code != http.StatusOK || response["token"] == "" || response["refresh_token"] == "" {
+		t.Fatalf("got %d %v, want new tokens", code, response)
+	}
+
+	claims, msg := generate.ValidateRefreshToken(response["refresh_token"])
+	if msg != "" {
+		t.Fatal(msg)
+	}
+	sessionID, _ := primitive.ObjectIDFromHex(claims.Session)
+	session, err := stores.Sessions.FindSession(context.Background(), sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Refresh_ID != claims.Id {
+		t.Errorf("the session's refresh ID is %q, want the new token's %q", session.Refresh_ID, claims.Id)
+	}
+}
+
+func TestRefreshTokenReplayRevokesSession(t *testing.T) {
+	app, stores, user := newTestApp(t)
+	router := gin.New()
+	router.POST("/users/refresh", app.RefreshToken())
+
+	token, refreshToken, err := app.startSession(context.Background(), user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, rotated := refresh(t, router, refreshToken)
+	if code != http.StatusOK {
+		t.Fatalf("first refresh: got %d %v", code, rotated)
+	}
+
+	// The old refresh token was copied: presenting it again ends the
+	// session.
+	if code, response := refresh(t, router, refreshToken); code != http.StatusUnauthorized || response["error"] != database.ErrRefreshTokenReused.Error() {
+		t.Fatalf("replay: got %d %v, want 401 reused", code, response)
+	}
+
+	claims, _ := generate.ValidateToken(token)
+	sessionID, _ := primitive.ObjectIDFromHex(claims.Session)
+	session, err := stores.Sessions.FindSession(context.Background(), sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Revoked_At == nil || session.Revoke_Reason != "refresh token reused" {
+		t.Errorf("got session revoked at %v for %q, want it revoked for the reuse", session.Revoked_At, session.Revoke_Reason)
+	}
+	// Neither the legitimate refresh token nor the session's access tokens
+	// work any more.
+	if code, response := refresh(t, router, rotated["refresh_token"]); code != http.StatusUnauthorized || response["error"] != database.ErrSessionRevoked.Error() {
+		t.Errorf("refreshing the rotated token: got %d %v, want 401 revoked", code, response)
+	}
+	for _, access := range []string{token, rotated["token"]} {
+		claims, _ := generate.ValidateToken(access)
+		if !app.Revocations().IsRevoked(claims) {
+			t.Error("an access token of the revoked session is not on the revocation list")
+		}
+	}
+}
+
+func TestRefreshTokenRejectsAccessTokens(t *testing.T) {
+	app, _, user := newTestApp(t)
+	router := gin.New()
+	router.POST("/users/refresh", app.RefreshToken())
+
+	token, _, err := app.startSession(context.Background(), user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, response := refresh(t, router, token); code != http.StatusUnauthorized {
+		t.Errorf("got %d %v, want 401", code, response)
+	}
+}
+
+// newSessionRouter returns a router with the refresh endpoint and, behind
+// the authentication middleware, the logout ones and a page showing the
+// session of the token.
+func newSessionRouter(app *Application) *gin.Engine {
+	router := gin.New()
+	router.POST("/users/refresh", app.RefreshToken())
+	router.Use(middleware.Authentication(app.Revocations()))
+	router.POST("/users/logout", app.Logout())
+	router.POST("/users/logout-all", app.LogoutAll())
+	router.GET("/cart", func(c *gin.Context) { c.String(http.StatusOK, c.GetString("session")) })
+	return router
+}
+
+func TestLogout(t *testing.T) {
+	app, _, user := newTestApp(t)
+	router := newSessionRouter(app)
+	ctx := context.Background()
+
+	token, refreshToken, err := app.startSession(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherToken, otherRefreshToken, err := app.startSession(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := serve(router, http.MethodPost, "/users/logout", nil, "token", token); rec.Code != http.StatusOK {
+		t.Fatalf("logout: got %d %s", rec.Code, rec.Body.String())
+	}
+	// The session's tokens stop working at once.
+	if rec := serve(router, http.MethodGet, "/cart", nil, "token", token); rec.Code != http.StatusUnauthorized {
+		t.Errorf("access token after logout: got %d, want 401", rec.Code)
+	}
+	if code, response := refresh(t, router, refreshToken); code != http.StatusUnauthorized {
+		t.Errorf("refresh token after logout: got %d %v, want 401", code, response)
+	}
+	// The user's other session goes on.
+	if rec := serve(router, http.MethodGet, "/cart", nil, "token", otherToken); rec.Code != http.StatusOK {
+		t.Errorf("other session's access token: got %d, want 200", rec.Code)
+	}
+	if code, response := refresh(t, router, otherRefreshToken); code != http.StatusOK {
+		t.Errorf("other session's refresh token: got %d %v, want 200", code, response)
+	}
+}
+
+func TestLogoutWithoutSession(t *testing.T) {
+	app, _, user := newTestApp(t)
+	router := newSessionRouter(app)
+
+	// Tokens issued before sessions existed are revoked on their own.
+	token, _, err := generate.TokenGenerator(*user.Email, *user.First_Name, *user.Last_Name, user.User_ID, "", generate.NewTokenID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := serve(router, http.MethodPost, "/users/logout", nil, "token", token); rec.Code != http.StatusOK {
+		t.Fatalf("logout: got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(router, http.MethodGet, "/cart", nil, "token", token); rec.Code != http.StatusUnauthorized {
+		t.Errorf("access token after logout: got %d, want 401", rec.Code)
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	app, stores, user := newTestApp(t)
+	router := newSessionRouter(app)
+	ctx := context.Background()
+
+	tokens := make([][2]string, 3)
+	for i := range tokens {
+		token, refreshToken, err := app.startSession(ctx, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[i] = [2]string{token, refreshToken}
+	}
+	// Another user's session is left alone.
+	email, first, last := "c@d.com", "gamma", "delta"
+	id := primitive.NewObjectID()
+	other := models.User{ID: id, User_ID: id.Hex(), Email: &email, First_Name: &first, Last_Name: &last}
+	if err := stores.Users.CreateUser(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	otherToken, _, err := app.startSession(ctx, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(router, http.MethodPost, "/users/logout-all", nil, "token", tokens[0][0])
+	var response struct {
+		Sessions int `json:"sessions"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &response)
+	if rec.Code != http.StatusOK || response.Sessions != len(tokens) {
+		t.Fatalf("logout-all: got %d %s, want 200 with %d sessions", rec.Code, rec.Body.String(), len(tokens))
+	}
+	for i, pair := range tokens {
+		if rec := serve(router, http.MethodGet, "/cart", nil, "token", pair[0]); rec.Code != http.StatusUnauthorized {
+			t.Errorf("access token of session %d: got %d, want 401", i, rec.Code)
+		}
+		if code, response := refresh(t, router, pair[1]); code != http.StatusUnauthorized {
+			t.Errorf("refresh token of session %d: got %d %v, want 401", i, code, response)
+		}
+	}
+	if rec := serve(router, http.MethodGet, "/cart", nil, "token", otherToken); rec.Code != http.StatusOK {
+		t.Errorf("the other user's token: got %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,395 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/carriers"
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// defaultCarrier books the shipments created without a carrier.
+const defaultCarrier = "local"
+
+var (
+	errNothingToShip         = errors.New("every item of the order has already shipped")
+	errInvalidShipmentItem   = errors.New("the item is not part of the order or was already shipped")
+	errInvalidShipmentStatus = errors.New("unknown shipment status")
+)
+
+type shipmentItemRequest struct {
+	Product_ID primitive.ObjectID `json:"product_id"`
+	Variant    string             `json:"variant"`
+	Quantity   int                `json:"quantity"`
+}
+
+// trackingResponse is the fulfillment of an order: its shipments with their
+// timelines, and the items that haven't shipped yet.
+type trackingResponse struct {
+	Order_ID  primitive.ObjectID    `json:"order_id"`
+	Status    models.OrderStatus    `json:"status"`
+	Shipments []models.Shipment     `json:"shipments"`
+	Unshipped []models.ShipmentItem `json:"unshipped,omitempty"`
+}
+
+// localhost:8000/admin/orders/{order_id}/shipments
+//
+//	{
+//	    "carrier": "local",
+//	    "items": [{"product_id": "66d4330450820c57cfb26558", "quantity": 1}]
+//	}
+//
+// Ships items of an order, or every item not shipped yet when items is
+// empty. The carrier books the shipment and hands out its tracking number,
+// unless one is given for a parcel booked outside the application; the
+// booking is cancelled when the store refuses the shipment. The first
+// shipment marks the order shipped.
+func (app *Application) AdminCreateShipment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, ok := orderIDParam(c)
+		if !ok {
+			return
+		}
+
+		var body struct {
+			Carrier         string                `json:"carrier"`
+			Tracking_Number string                `json:"tracking_number"`
+			Items           []shipmentItemRequest `json:"items"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if body.Carrier == "" {
+			body.Carrier = defaultCarrier
+		}
+		carrier, err := app.config.Carriers.Get(body.Carrier)
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		order, err := app.orders.FindOrderByID(ctx, orderID)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(orderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		if !order.Shippable() {
+			c.IndentedJSON(http.StatusConflict, gin.H{"error": database.ErrOrderNotShippable.Error()})
+			return
+		}
+
+		previous, err := app.shipments.ListOrderShipments(ctx, orderID)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		items, err := shipmentItems(order, previous, body.Items)
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		now := time.Now().UTC()
+		shipment := models.Shipment{
+			Shipment_ID:     primitive.NewObjectID(),
+			Order_ID:        orderID,
+			User_ID:         order.User_ID,
+			Carrier:         carrier.Name(),
+			Tracking_Number: body.Tracking_Number,
+			Items:           items,
+			Status:          models.ShipmentLabelCreated,
+			Created_At:      now,
+		}
+		booked := shipment.Tracking_Number == ""
+		if booked {
+			if shipment.Tracking_Number, err = carrier.Book(ctx, order, shipment); err != nil {
+				log.Println(err)
+				c.IndentedJSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		shipment.Events, err = carrier.Track(ctx, shipment.Tracking_Number)
+		if err != nil || len(shipment.Events) == 0 {
+			shipment.Events = []models.TrackingEvent{{Status: models.ShipmentLabelCreated, Description: "Shipment created", Occurred_At: now}}
+		}
+		shipment.Status = shipment.Events[len(shipment.Events)-1].Status
+
+		// The store checks the order and the items again as it stores the
+		// shipment and marks the order shipped, in case the order changed or
+		// another shipment of it was created meanwhile.
+		if err := app.shipments.CreateShipment(ctx, shipment); err != nil {
+			log.Println(err)
+			if booked {
+				if err := carrier.Cancel(ctx, shipment.Tracking_Number); err != nil {
+					log.Println("failed to cancel shipment", shipment.Tracking_Number, "with", carrier.Name(), err)
+				}
+			}
+			status := http.StatusInternalServerError
+			if errors.Is(err, database.ErrShipmentExceedsOrder) || errors.Is(err, database.ErrShipmentConflict) ||
+				errors.Is(err, database.ErrOrderNotShippable) {
+				status = http.StatusConflict
+			}
+			c.IndentedJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		app.completeDelivery(ctx, orderID)
+		c.IndentedJSON(http.StatusCreated, shipment)
+	}
+}
+
+// shipmentItems checks the requested items against the order lines and the
+// quantities already shipped. Without items, every item left to ship is
+// returned.
+func shipmentItems(order models.Order, previous []models.Shipment, requested []shipmentItemRequest) ([]models.ShipmentItem, error) {
+	shipped := shippedQuantities(previous)
+	if len(requested) == 0 {
+		items := unshippedItems(order, shipped)
+		if len(items) == 0 {
+			return nil, errNothingToShip
+		}
+		return items, nil
+	}
+
+	items := make([]models.ShipmentItem, 0, len(requested))
+	for _, req := range requested {
+		key := models.ItemKey{Product_ID: req.Product_ID, Variant: req.Variant}
+		i := orderLineIndex(order, key)
+		if i < 0 || req.Quantity <= 0 {
+			return nil, errInvalidShipmentItem
+		}
+		shipped[key] += req.Quantity
+		if shipped[key] > order.Order_Cart[i].Quantity {
+			return nil, errInvalidShipmentItem
+		}
+		items = append(items, models.ShipmentItem{Product_ID: req.Product_ID, Variant: req.Variant, Quantity: req.Quantity})
+	}
+	return items, nil
+}
+
+// shippedQuantities sums the quantities per order line of shipments.
+func shippedQuantities(shipments []models.Shipment) map[models.ItemKey]int {
+	quantities := make(map[models.ItemKey]int)
+	for _, shipment := range shipments {
+		for _, item := range shipment.Items {
+			quantities[item.Key()] += item.Quantity
+		}
+	}
+	return quantities
+}
+
+// unshippedItems returns what is left to ship of each order line.
+func unshippedItems(order models.Order, shipped map[models.ItemKey]int) []models.ShipmentItem {
+	var items []models.ShipmentItem
+	for _, line := range order.Order_Cart {
+		if left := line.Quantity - shipped[line.Key()]; left > 0 {
+			items = append(items, models.ShipmentItem{Product_ID: line.Product_ID, Variant: line.Variant, Quantity: left})
+		}
+	}
+	return items
+}
+
+// trackShipment adds the events the carrier reported since the shipment was
+// last tracked. Carrier failures are logged and leave the timeline as it is.
+func (app *Application) trackShipment(ctx context.Context, shipment models.Shipment) models.Shipment {
+	if shipment.Status == models.ShipmentDelivered {
+		return shipment
+	}
+	carrier, err := app.config.Carriers.Get(shipment.Carrier)
+	if err != nil {
+		log.Println(err)
+		return shipment
+	}
+	events, err := carrier.Track(ctx, shipment.Tracking_Number)
+	if err != nil {
+		if !errors.Is(err, carriers.ErrUnknownTrackingNumber) {
+			log.Println("failed to track shipment", shipment.Tracking_Number, err)
+		}
+		return shipment
+	}
+
+	var added []models.TrackingEvent
+	for _, event := range events {
+		if !shipment.HasEvent(event) {
+			added = append(added, event)
+		}
+	}
+	if len(added) == 0 {
+		return shipment
+	}
+	sort.SliceStable(added, func(i, j int) bool { return added[i].Occurred_At.Before(added[j].Occurred_At) })
+	tracked, err := app.shipments.AddShipmentEvents(ctx, shipment.Shipment_ID, added)
+	if err != nil {
+		log.Println(err)
+		return shipment
+	}
+	if tracked.Status == models.ShipmentDelivered {
+		app.completeDelivery(ctx, tracked.Order_ID)
+	}
+	return tracked
+}
+
+// trackOrder tracks every shipment of an order.
+func (app *Application) trackOrder(ctx context.Context, order models.Order) (trackingResponse, error) {
+	shipments, err := app.shipments.ListOrderShipments(ctx, order.Order_ID)
+	if err != nil {
+		return trackingResponse{}, err
+	}
+	for i, shipment := range shipments {
+		shipments[i] = app.trackShipment(ctx, shipment)
+	}
+	// Delivering the last shipment may have changed the order.
+	if current, err := app.orders.FindOrderByID(ctx, order.Order_ID); err == nil {
+		order = current
+	}
+	return trackingResponse{
+		Order_ID:  order.Order_ID,
+		Status:    order.Status,
+		Shipments: shipments,
+		Unshipped: unshippedItems(order, shippedQuantities(shipments)),
+	}, nil
+}
+
+// completeDelivery marks a shipped order delivered once all its items have
+// shipped and every shipment was delivered.
+func (app *Application) completeDelivery(ctx context.Context, orderID primitive.ObjectID) {
+	order, err := app.orders.FindOrderByID(ctx, orderID)
+	if err != nil || order.Status != models.OrderShipped {
+		return
+	}
+	shipments, err := app.shipments.ListOrderShipments(ctx, orderID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	for _, shipment := range shipments {
+		if shipment.Status != models.ShipmentDelivered {
+			return
+		}
+	}
+	if len(unshippedItems(order, shippedQuantities(shipments))) > 0 {
+		return
+	}
+	if _, err := app.orders.UpdateOrderStatus(ctx, orderID, models.OrderDelivered, "all shipments delivered"); err != nil {
+		log.Println(err)
+	}
+}
+
+// localhost:8000/orders/{order_id}/tracking
+//
+// Returns the shipments of an order with their tracking timelines.
+func (app *Application) TrackOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, ok := orderIDParam(c)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		order, err := app.orders.FindOrder(ctx, c.GetString("uid"), orderID)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(orderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		tracking, err := app.trackOrder(ctx, order)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, tracking)
+	}
+}
+
+// localhost:8000/admin/orders/{order_id}/shipments
+func (app *Application) AdminListShipments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orderID, ok := orderIDParam(c)
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		order, err := app.orders.FindOrderByID(ctx, orderID)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(orderErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		tracking, err := app.trackOrder(ctx, order)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, tracking)
+	}
+}
+
+// localhost:8000/admin/shipments/{shipment_id}/events
+//
+//	{
+//	    "status": "exception",
+//	    "description": "Nobody home, delivery rescheduled",
+//	    "location": "Bengaluru hub"
+//	}
+//
+// Records a tracking event the carrier doesn't report, such as for parcels
+// delivered by hand. occurred_at defaults to now.
+func (app *Application) AdminAddShipmentEvent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		shipmentID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "shipment id is not valid"})
+			return
+		}
+
+		var event models.TrackingEvent
+		if err := c.ShouldBindJSON(&event); err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !event.Status.Valid() {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": errInvalidShipmentStatus.Error()})
+			return
+		}
+		if event.Occurred_At.IsZero() {
+			event.Occurred_At = time.Now().UTC()
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		shipment, err := app.shipments.AddShipmentEvents(ctx, shipmentID, []models.TrackingEvent{event})
+		if err != nil {
+			log.Println(err)
+			status := http.StatusInternalServerError
+			if errors.Is(err, database.ErrShipmentNotFound) {
+				status = http.StatusNotFound
+			}
+			c.IndentedJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if shipment.Status == models.ShipmentDelivered {
+			app.completeDelivery(ctx, shipment.Order_ID)
+		}
+		c.IndentedJSON(http.StatusOK, shipment)
+	}
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"github.com/ChandanJnv/ecommerce-cart-golang/pricing"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errNoShippingMethod      = errors.New("no shipping method delivers this cart to the address")
+	errShippingNotAvailable  = errors.New("this shipping method doesn't deliver this cart to the address")
+	errUnknownShippingMethod = errors.New("unknown shipping method")
+)
+
+// shippingOptionsResponse lists the ways a cart can ship, priced in Currency.
+type shippingOptionsResponse struct {
+	Currency string                  `json:"currency"`
+	Options  []models.ShippingOption `json:"options"`
+}
+
+// shipQuote adds shipping with method to quote, or with the first method
+// available for the cart when method is empty.
+func (app *Application) shipQuote(ctx context.Context, quote *pricing.Quote, address models.Address, method models.ShippingMethod) error {
+	if method != "" && !method.Valid() {
+		return errUnknownShippingMethod
+	}
+	options, err := app.config.Shipping.Options(ctx, address, quote.Items)
+	if err != nil {
+		return err
+	}
+	if len(options) == 0 {
+		return errNoShippingMethod
+	}
+	if method == "" {
+		quote.ApplyShipping(options[0])
+		return nil
+	}
+	for _, option := range options {
+		if option.Method == method {
+			quote.ApplyShipping(option)
+			return nil
+		}
+	}
+	return errShippingNotAvailable
+}
+
+// shipCart adds shipping with method to the quote of a cart, recording why
+// the cart can't ship that way rather than failing.
+func (app *Application) shipCart(ctx context.Context, response *cartResponse, address models.Address, method models.ShippingMethod) error {
+	err := app.shipQuote(ctx, &response.Quote, address, method)
+	if isShippingRejection(err) {
+		response.Shipping_Error = err.Error()
+		return nil
+	}
+	return err
+}
+
+func isShippingRejection(err error) bool {
+	return errors.Is(err, errNoShippingMethod) || errors.Is(err, errShippingNotAvailable) || errors.Is(err, errUnknownShippingMethod)
+}
+
+// localhost:8000/cart/shipping-options?userID={user_id}&address_id={address_id}&currency={currency}
+//
+// Lists the shipping methods that deliver the cart to the address, with what
+// each costs for the cart. Shipping discounts of the cart are taken off.
+func (app *Application) CartShippingOptions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userQueryID := c.Query("userID")
+		if userQueryID == "" {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "user id is empty"})
+			return
+		}
+		currency, ok := app.resolveCurrency(c, c.Query("currency"))
+		if !ok {
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		cart, err := app.carts.GetCart(ctx, userQueryID)
+		var code string
+		if err == nil {
+			code, err = app.carts.GetCartCoupon(ctx, userQueryID)
+		}
+		var address models.Address
+		if err == nil {
+			address, err = app.shippingAddress(ctx, userQueryID, c.Query("address_id"))
+		}
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(cartErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		// A coupon that doesn't apply just doesn't waive shipping.
+		quote, err := app.quoteCart(ctx, userQueryID, cart, code, address, currency)
+		if err != nil && !isCouponRejection(err) && !errors.Is(err, database.ErrCouponUsedUp) && !errors.Is(err, database.ErrCouponNotFound) {
+			log.Println(err)
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		options, err := app.config.Shipping.Options(ctx, address, quote.Items)
+		if err != nil {
+			log.Println(err)
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		response := shippingOptionsResponse{Currency: currency, Options: make([]models.ShippingOption, len(options))}
+		for i, option := range options {
+			response.Options[i] = quote.PriceShipping(option)
+		}
+		c.IndentedJSON(http.StatusOK, response)
+	}
+}
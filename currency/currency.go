@@ -0,0 +1,222 @@
+// Package currency converts prices from the base currency of the catalogue to
+// the other currencies customers can shop in, at rates from a local table.
+package currency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+)
+
+var ErrUnsupportedCurrency = errors.New("currency isn't supported")
+
+// Currency is a currency and how many minor units make up one of it, 2 for
+// cents and 0 for yen. Rate is how many of it one unit of the base currency
+// buys, as a decimal such as "0.012"; the base currency has none.
+type Currency struct {
+	Code        string `json:"code"`
+	Minor_Units int    `json:"minor_units"`
+	Rate        string `json:"rate,omitempty"`
+}
+
+// Table is the base currency prices are stored in and the currencies they
+// can be converted to, loaded from a JSON file.
+type Table struct {
+	Base       Currency   `json:"base"`
+	Currencies []Currency `json:"currencies"`
+}
+
+// Converter converts amounts in minor units of the base currency to minor
+// units of the other currencies of its table.
+type Converter struct {
+	base    Currency
+	rates   map[string]string
+	factors map[string]*big.Rat
+}
+
+// NewConverter returns the converter for the rate table at path or, when path
+// is empty, one that only knows the base currency named by base, INR by
+// default, with 2 minor units.
+func NewConverter(path, base string) (*Converter, error) {
+	if path == "" {
+		if base == "" {
+			base = "INR"
+		}
+		return newConverter(Table{Base: Currency{Code: strings.ToUpper(base), Minor_Units: 2}})
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading currency rates: %w", err)
+	}
+	var table Table
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("parsing currency rates %s: %w", path, err)
+	}
+	converter, err := newConverter(table)
+	if err != nil {
+		return nil, fmt.Errorf("currency rates %s: %w", path, err)
+	}
+	return converter, nil
+}
+
+func newConverter(table Table) (*Converter, error) {
+	if !ValidCode(table.Base.Code) {
+		return nil, fmt.Errorf("base currency %q must be a 3-letter ISO 4217 code", table.Base.Code)
+	}
+	if table.Base.Minor_Units < 0 {
+		return nil, errors.New("minor_units can't be negative")
+	}
+
+	converter := &Converter{
+		base:    table.Base,
+		rates:   map[string]string{table.Base.Code: "1"},
+		factors: map[string]*big.Rat{table.Base.Code: big.NewRat(1, 1)},
+	}
+	for _, c := range table.Currencies {
+		if !ValidCode(c.Code) {
+			return nil, fmt.Errorf("currency %q must be a 3-letter ISO 4217 code", c.Code)
+		}
+		if _, ok := converter.factors[c.Code]; ok {
+			return nil, fmt.Errorf("currency %s is listed twice", c.Code)
+		}
+		if c.Minor_Units < 0 {
+			return nil, fmt.Errorf("currency %s: minor_units can't be negative", c.Code)
+		}
+		rate, ok := new(big.Rat).SetString(c.Rate)
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("currency %s: rate must be a positive decimal, got %q", c.Code, c.Rate)
+		}
+
+		// A minor unit of the base currency is worth rate major units of
+		// c scaled by the difference in minor units.
+		factor := new(big.Rat).Set(rate)
+		scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(c.Minor_Units-table.Base.Minor_Units))), nil))
+		if c.Minor_Units >= table.Base.Minor_Units {
+			factor.Mul(factor, scale)
+		} else {
+			factor.Quo(factor, scale)
+		}
+		converter.rates[c.Code] = c.Rate
+		converter.factors[c.Code] = factor
+	}
+	return converter, nil
+}
+
+// ValidCode reports whether code looks like an ISO 4217 code: three
+// upper-case letters.
+func ValidCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Base returns the code of the base currency.
+func (c *Converter) Base() string {
+	return c.base.Code
+}
+
+// Resolve returns the currency named by code, which may be lower-case, or the
+// base currency when code is empty, failing with ErrUnsupportedCurrency for
+// currencies not in the table.
+func (c *Converter) Resolve(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return c.base.Code, nil
+	}
+	if _, ok := c.factors[code]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedCurrency, code)
+	}
+	return code, nil
+}
+
+// Supported reports whether prices can be shown in code.
+func (c *Converter) Supported(code string) bool {
+	_, ok := c.factors[code]
+	return ok
+}
+
+// Rate returns the units of code one unit of the base currency buys, as
+// configured.
+func (c *Converter) Rate(code string) string {
+	return c.rates[code]
+}
+
+// Convert converts amount in minor units of the base currency to minor units
+// of code, rounding half away from zero.
+func (c *Converter) Convert(amount int64, code string) (int64, error) {
+	factor, ok := c.factors[code]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedCurrency, code)
+	}
+	return round(new(big.Rat).Mul(new(big.Rat).SetInt64(amount), factor)), nil
+}
+
+// Exchange returns the conversion to code as a function, for amounts such as
+// discount values that are defined in the base currency. code must be
+// supported.
+func (c *Converter) Exchange(code string) func(int64) int64 {
+	return func(amount int64) int64 {
+		converted, _ := c.Convert(amount, code)
+		return converted
+	}
+}
+
+// Localize returns lines priced in code: at the fixed price a line has in
+// code if it has one, and converted from its base price otherwise.
+func (c *Converter) Localize(lines []models.ProductUser, code string) ([]models.ProductUser, error) {
+	localized := make([]models.ProductUser, len(lines))
+	for i, line := range lines {
+		price, err := c.PriceOf(line.Price, line.Prices, code)
+		if err != nil {
+			return nil, err
+		}
+		line.Price = &price
+		line.Prices = nil
+		localized[i] = line
+	}
+	return localized, nil
+}
+
+// PriceOf returns the price in code of something that costs base in the base
+// currency and has the fixed prices given.
+func (c *Converter) PriceOf(base *int64, prices []models.Money, code string) (int64, error) {
+	if price, ok := models.PriceIn(prices, code); ok {
+		return price.Amount, nil
+	}
+	if base == nil {
+		return 0, nil
+	}
+	return c.Convert(*base, code)
+}
+
+// round rounds r to the nearest integer, halves away from zero.
+func round(r *big.Rat) int64 {
+	num := new(big.Int).Abs(r.Num())
+	den := r.Denom()
+	// (2*num + den) / (2*den) is num/den + 1/2 rounded down.
+	num.Mul(num, big.NewInt(2)).Add(num, den)
+	q := num.Quo(num, new(big.Int).Mul(den, big.NewInt(2)))
+	if r.Sign() < 0 {
+		q.Neg(q)
+	}
+	return q.Int64()
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -3,7 +3,6 @@ package database
 import (
 	"context"
 	"errors"
-	"fmt"
 	"log"
 	"time"
 
@@ -22,155 +21,242 @@ var (
 	ErrCantGetItem        = errors.New("was unable to get the item form the cart")
 	ErrCantBuyCartItem    = errors.New("cannot update the purchase")
 	ErrCantFindProduct    = errors.New("cannot find the product")
+	ErrCantInsertProduct  = errors.New("cannot insert the product")
+	ErrCantUpdateProduct  = errors.New("cannot update the product")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrAddressNotFound    = errors.New("address not found")
+	ErrCantCreateUser     = errors.New("the user did not get created")
+	ErrInvalidQuantity    = errors.New("quantity must be a positive number")
+	ErrCartItemNotFound   = errors.New("this item is not in the cart")
+	ErrCartIsEmpty        = errors.New("the cart is empty")
 )
 
-// localhost:8000/addtocart?id={product_id}&userID={user_id}
-func AddProductToCart(ctx context.Context, prodCollection, userCollection *mongo.Collection, productID primitive.ObjectID, userID string) error {
-	searchFromDB, err := prodCollection.Find(ctx, bson.M{"_id": productID})
+// localhost:8000/addtocart?id={product_id}&variant={sku}&userID={user_id}&quantity={quantity}
+func AddProductToCart(ctx context.Context, prodCollection, userCollection *mongo.Collection, productID primitive.ObjectID, variant, userID string, quantity int) error {
+	id, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		log.Println(err)
-		return errCantFindProduct
-	}
-
-	var productCart []models.ProductUser
-	if err := searchFromDB.All(ctx, &productCart); err != nil {
-		log.Println(err)
-		return ErrCantDecodeProduct
+		return ErrUserIdIsNotValid
 	}
+	return addCartLine(ctx, prodCollection, userCollection, id, models.ItemKey{Product_ID: productID, Variant: variant}, quantity)
+}
 
+// localhost:8000/cart/quantity?id={product_id}&userID={user_id}&quantity={quantity}
+func SetCartItemQuantity(ctx context.Context, prodCollection, userCollection *mongo.Collection, productID primitive.ObjectID, variant, userID string, quantity int) error {
 	id, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		log.Println(err)
 		return ErrUserIdIsNotValid
 	}
-
-	fmt.Println("productCart: ", productCart)
-	filter := bson.D{primitive.E{Key: "_id", Value: id}}
-	update := bson.D{{Key: "$push", Value: bson.D{primitive.E{Key: "usercart", Value: bson.D{{Key: "$each", Value: productCart}}}}}}
-	if _, err := userCollection.UpdateOne(ctx, filter, update); err != nil {
-		log.Println(err)
-		return ErrCantUpdateUser
-	}
-
-	return nil
+	return setCartLineQuantity(ctx, prodCollection, userCollection, id, models.ItemKey{Product_ID: productID, Variant: variant}, quantity)
 }
 
-// localhost:8000/removeitem?userID={user_id}&id={product_id}
-func RemoveCartIterm(ctx context.Context, prodCollection, userCollection *mongo.Collection, productID primitive.ObjectID, userID string) error {
+// localhost:8000/cart/decrement?id={product_id}&userID={user_id}&quantity={quantity}
+func DecrementCartItem(ctx context.Context, userCollection *mongo.Collection, productID primitive.ObjectID, variant, userID string, quantity int) error {
 	id, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		log.Println(err)
 		return ErrUserIdIsNotValid
 	}
+	return decrementCartLine(ctx, userCollection, id, models.ItemKey{Product_ID: productID, Variant: variant}, quantity)
+}
 
-	filter := bson.D{primitive.E{Key: "_id", Value: id}}
-	update := bson.M{"$pull": bson.M{"usercart": bson.M{"_id": productID}}}
+// The cart line helpers below work on any collection whose documents keep
+// their lines in a "usercart" array, so users and guest carts share them.
 
-	if _, err := userCollection.UpdateMany(ctx, filter, update); err != nil {
-		log.Println(err)
-		return ErrCantRemoveItemCart
+// touchCart adds to update what every write to a cart sets besides its
+// lines: the cart_version counter, which MergeGuestCart checks to notice
+// concurrent changes, and updated_at, which abandoned guest carts expire by.
+func touchCart(update bson.M, now time.Time) bson.M {
+	set, _ := update["$set"].(bson.M)
+	if set == nil {
+		set = bson.M{}
 	}
+	set["updated_at"] = now
+	update["$set"] = set
 
-	return nil
+	inc, _ := update["$inc"].(bson.M)
+	if inc == nil {
+		inc = bson.M{}
+	}
+	inc["cart_version"] = 1
+	update["$inc"] = inc
+	return update
 }
 
-// localhost:8000/cartcheckout?userID={user_id}
-func BuyItemFromCart(ctx context.Context, userCollection *mongo.Collection, userID string) error {
-	id, err := primitive.ObjectIDFromHex(userID)
-	if err != nil {
-		log.Println(err)
-		return ErrUserIdIsNotValid
+// lineMatch matches the cart line of key inside the "usercart" array. Lines
+// stored before variants existed have no variant field at all.
+func lineMatch(key models.ItemKey) bson.M {
+	if key.Variant == "" {
+		return bson.M{"_id": key.Product_ID, "variant": bson.M{"$in": bson.A{"", nil}}}
 	}
+	return bson.M{"_id": key.Product_ID, "variant": key.Variant}
+}
 
-	var getCartItems models.User
-	var orderCart models.Order
-
-	orderCart.Order_ID = primitive.NewObjectID()
-	orderCart.Ordered_At = time.Now()
-	orderCart.Order_Cart = make([]models.ProductUser, 0)
-	orderCart.Payment_method.COD = true
+func addCartLine(ctx context.Context, prodCollection, carts *mongo.Collection, cartID interface{}, key models.ItemKey, quantity int) error {
+	if quantity <= 0 {
+		return ErrInvalidQuantity
+	}
 
-	unwind := bson.D{{Key: "$unwind", Value: bson.D{primitive.E{Key: "path", Value: "$usercart"}}}}
-	grouping := bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$_id"}, {Key: "total", Value: bson.D{{Key: "$sum", Value: "$usercart.price"}}}}}}
-	currentResult, err := userCollection.Aggregate(ctx, mongo.Pipeline{unwind, grouping})
-	ctx.Done()
+	var product models.Product
+	if err := prodCollection.FindOne(ctx, bson.M{"_id": key.Product_ID}).Decode(&product); err != nil {
+		log.Println(err)
+		return errCantFindProduct
+	}
+	line, err := NewCartLine(product, key.Variant, quantity)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	available, err := AvailableStock(product, key.Variant)
+	if err != nil {
+		return err
 	}
+	now := line.Updated_At
+
+	// Bump the quantity of an existing line first, as long as the new
+	// quantity stays within the stock; only push a new line when the item
+	// isn't in the cart yet. The $not guard on the push keeps two concurrent
+	// first adds from creating duplicate lines.
+	for attempt := 0; attempt < 2; attempt++ {
+		match := lineMatch(key)
+		match["quantity"] = bson.M{"$lte": available - int64(quantity)}
+		inc := touchCart(bson.M{"$inc": bson.M{"usercart.$.quantity": quantity}, "$set": bson.M{"usercart.$.updated_at": now}}, now)
+		result, err := carts.UpdateOne(ctx, bson.M{"_id": cartID, "usercart": bson.M{"$elemMatch": match}}, inc)
+		if err != nil {
+			log.Println(err)
+			return ErrCantUpdateUser
+		}
+		if result.MatchedCount > 0 {
+			return nil
+		}
+		inCart, err := carts.CountDocuments(ctx, bson.M{"_id": cartID, "usercart": bson.M{"$elemMatch": lineMatch(key)}})
+		if err != nil {
+			log.Println(err)
+			return ErrCantUpdateUser
+		}
+		if inCart > 0 {
+			return insufficientStock(line, available)
+		}
 
-	var getUserCart []bson.M
-	if err := currentResult.All(ctx, &getUserCart); err != nil {
-		panic(err)
+		push := touchCart(bson.M{"$push": bson.M{"usercart": line}}, now)
+		result, err = carts.UpdateOne(ctx, bson.M{"_id": cartID, "usercart": bson.M{"$not": bson.M{"$elemMatch": lineMatch(key)}}}, push)
+		if err != nil {
+			log.Println(err)
+			return ErrCantUpdateUser
+		}
+		if result.MatchedCount > 0 {
+			return nil
+		}
 	}
-	var totalPrice int64
-	for _, userItem := range getUserCart {
-		price := userItem["total"]
-		totalPrice = (price.(int64))
+
+	return ErrCantUpdateUser
+}
+
+func setCartLineQuantity(ctx context.Context, prodCollection, carts *mongo.Collection, cartID interface{}, key models.ItemKey, quantity int) error {
+	if quantity < 0 {
+		return ErrInvalidQuantity
+	}
+	if quantity == 0 {
+		return pullCartLine(ctx, carts, cartID, key)
 	}
 
-	orderCart.Price = &totalPrice
-	filter := bson.D{primitive.E{Key: "_id", Value: id}}
-	update := bson.D{{Key: "$push", Value: bson.D{primitive.E{Key: "orders", Value: orderCart}}}}
-	if _, err := userCollection.UpdateMany(ctx, filter, update); err != nil {
+	var product models.Product
+	if err := prodCollection.FindOne(ctx, bson.M{"_id": key.Product_ID}).Decode(&product); err != nil {
 		log.Println(err)
+		return errCantFindProduct
+	}
+	if _, err := NewCartLine(product, key.Variant, quantity); err != nil {
 		return err
 	}
 
-	if err := userCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: id}}).Decode(&getCartItems); err != nil {
+	now := time.Now().UTC()
+	update := touchCart(bson.M{"$set": bson.M{"usercart.$.quantity": quantity, "usercart.$.updated_at": now}}, now)
+	result, err := carts.UpdateOne(ctx, bson.M{"_id": cartID, "usercart": bson.M{"$elemMatch": lineMatch(key)}}, update)
+	if err != nil {
 		log.Println(err)
-		return err
+		return ErrCantUpdateUser
+	}
+	if result.MatchedCount == 0 {
+		return ErrCartItemNotFound
+	}
+
+	return nil
+}
+
+func decrementCartLine(ctx context.Context, carts *mongo.Collection, cartID interface{}, key models.ItemKey, quantity int) error {
+	if quantity <= 0 {
+		return ErrInvalidQuantity
 	}
 
-	filter2 := bson.D{primitive.E{Key: "_id", Value: id}}
-	update2 := bson.M{"$push": bson.M{"orders.$[].order_list": bson.M{"$each": getCartItems.UserCart}}}
-	if _, err := userCollection.UpdateOne(ctx, filter2, update2); err != nil {
+	match := lineMatch(key)
+	match["quantity"] = bson.M{"$gt": quantity}
+	filter := bson.M{"_id": cartID, "usercart": bson.M{"$elemMatch": match}}
+	now := time.Now().UTC()
+	update := touchCart(bson.M{"$inc": bson.M{"usercart.$.quantity": -quantity}, "$set": bson.M{"usercart.$.updated_at": now}}, now)
+	result, err := carts.UpdateOne(ctx, filter, update)
+	if err != nil {
 		log.Println(err)
-		return err
+		return ErrCantUpdateUser
+	}
+	if result.MatchedCount > 0 {
+		return nil
 	}
 
-	getUserCartEmpty := make([]models.ProductUser, 0)
-	filter3 := bson.D{primitive.E{Key: "_id", Value: id}}
-	update3 := bson.D{{Key: "$set", Value: bson.D{primitive.E{Key: "usercart", Value: getUserCartEmpty}}}}
+	// Decrementing by the whole quantity or more removes the line.
+	return pullCartLine(ctx, carts, cartID, key)
+}
 
-	if _, err := userCollection.UpdateOne(ctx, filter3, update3); err != nil {
+func pullCartLine(ctx context.Context, carts *mongo.Collection, cartID interface{}, key models.ItemKey) error {
+	update := touchCart(bson.M{"$pull": bson.M{"usercart": lineMatch(key)}}, time.Now().UTC())
+	result, err := carts.UpdateOne(ctx, bson.M{"_id": cartID, "usercart": bson.M{"$elemMatch": lineMatch(key)}}, update)
+	if err != nil {
 		log.Println(err)
-		return ErrCantBuyCartItem
+		return ErrCantRemoveItemCart
+	}
+	if result.MatchedCount == 0 {
+		return ErrCartItemNotFound
 	}
 
 	return nil
 }
 
-func InstantBuyer(ctx context.Context, prodCollection, userCollection *mongo.Collection, productID primitive.ObjectID, userID string) error {
+// localhost:8000/removeitem?userID={user_id}&id={product_id}
+func RemoveCartIterm(ctx context.Context, prodCollection, userCollection *mongo.Collection, productID primitive.ObjectID, variant, userID string) error {
 	id, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
 		log.Println(err)
 		return ErrUserIdIsNotValid
 	}
-	var productDetails models.ProductUser
-	var ordersDetails models.Order
 
-	ordersDetails.Order_ID = primitive.NewObjectID()
-	ordersDetails.Ordered_At = time.Now()
-	ordersDetails.Order_Cart = (make([]models.ProductUser, 0))
-	ordersDetails.Payment_method.COD = true
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := touchCart(bson.M{"$pull": bson.M{"usercart": lineMatch(models.ItemKey{Product_ID: productID, Variant: variant})}}, time.Now().UTC())
 
-	if err := prodCollection.FindOne(ctx, bson.D{primitive.E{Key: "_id", Value: productID}}).Decode(&productDetails); err != nil {
+	if _, err := userCollection.UpdateMany(ctx, filter, update); err != nil {
 		log.Println(err)
-		return err
+		return ErrCantRemoveItemCart
 	}
-	ordersDetails.Price = productDetails.Price
-	filter := bson.D{primitive.E{Key: "_id", Value: id}}
-	update := bson.D{{Key: "$push", Value: bson.D{primitive.E{Key: "orders", Value: ordersDetails}}}}
-	if _, err := userCollection.UpdateOne(ctx, filter, update); err != nil {
+
+	return nil
+}
+
+// ClearCart empties the cart of a user once it has been ordered.
+func ClearCart(ctx context.Context, userCollection *mongo.Collection, userID string) error {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
 		log.Println(err)
-		return err
+		return ErrUserIdIsNotValid
 	}
 
-	filter2 := bson.D{primitive.E{Key: "_id", Value: id}}
-	update2 := bson.M{"$push": bson.M{"orders.$[].order_list": productDetails}}
-	if _, err := userCollection.UpdateOne(ctx, filter2, update2); err != nil {
-		log.Panicln(err)
-		return err
+	getUserCartEmpty := make([]models.ProductUser, 0)
+	filter := bson.D{primitive.E{Key: "_id", Value: id}}
+	update := touchCart(bson.M{
+		"$set":   bson.M{"usercart": getUserCartEmpty},
+		"$unset": bson.M{"cart_coupon": ""},
+	}, time.Now().UTC())
+
+	if _, err := userCollection.UpdateOne(ctx, filter, update); err != nil {
+		log.Println(err)
+		return ErrCantBuyCartItem
 	}
 
 	return nil
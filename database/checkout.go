@@ -0,0 +1,219 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// supportsTransactions reports whether the deployment behind client is a
+// replica set or a sharded cluster; standalone servers have no transactions.
+func supportsTransactions(client *mongo.Client) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var hello bson.M
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		log.Println(err)
+		return false
+	}
+	_, replicaSet := hello["setName"]
+	return replicaSet || hello["msg"] == "isdbgrid"
+}
+
+// withTransaction runs fn as one transaction, retried by the driver on
+// transient errors, so fn must only write through the context it is given.
+// Transactions need a replica set; NewMongoStore refuses to start without
+// one unless MONGODB_ALLOW_STANDALONE is set, in which case fn runs directly
+// and the caller compensates as best it can.
+func (s *MongoStore) withTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !s.transactions {
+		return fn(ctx)
+	}
+
+	session, err := s.client.StartSession()
+	if err != nil {
+		log.Println(err)
+		return ErrCantUpdateOrder
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionCtx)
+	})
+	return err
+}
+
+func (s *MongoStore) PlaceOrder(ctx context.Context, order models.Order, reservation models.Reservation, clearCart bool) error {
+	return s.withTransaction(ctx, func(ctx context.Context) error {
+		err := s.fail("reserve stock")
+		if err == nil {
+			err = ReserveStock(ctx, s.prodCollection, s.stockCollection, s.holdCollection, reservation)
+		}
+		if err != nil {
+			return err
+		}
+		if order.Coupon_Code != "" {
+			err := s.fail("redeem coupon")
+			if err == nil {
+				err = RedeemCoupon(ctx, s.couponCollection, order.Coupon_Code, order.User_ID)
+			}
+			if err != nil {
+				if !s.transactions {
+					s.undoReservation(ctx, order.Order_ID)
+				}
+				return err
+			}
+		}
+		err = s.fail("create order")
+		if err == nil {
+			err = CreateOrder(ctx, s.orderCollection, order)
+		}
+		if err != nil {
+			if !s.transactions {
+				s.undoReservation(ctx, order.Order_ID)
+				s.undoCoupon(ctx, order)
+			}
+			return err
+		}
+		if !clearCart {
+			return nil
+		}
+		err = s.fail("clear cart")
+		if err == nil {
+			err = ClearCart(ctx, s.userCollection, order.User_ID)
+		}
+		if err != nil {
+			if !s.transactions {
+				// The order stands; the cart just keeps its items.
+				log.Println("failed to clear the cart after ordering:", err)
+				return nil
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// undoReservation takes back a reservation whose order could not be stored.
+func (s *MongoStore) undoReservation(ctx context.Context, orderID primitive.ObjectID) {
+	if err := ReleaseReservation(ctx, s.prodCollection, s.stockCollection, s.holdCollection, orderID); err != nil {
+		log.Println("failed to release the stock of order", orderID.Hex(), err)
+	}
+	if _, err := s.holdCollection.DeleteOne(ctx, bson.M{"_id": orderID}); err != nil {
+		log.Println(err)
+	}
+}
+
+// undoCoupon gives back the coupon use of an order that could not be stored.
+func (s *MongoStore) undoCoupon(ctx context.Context, order models.Order) {
+	if order.Coupon_Code == "" {
+		return
+	}
+	if err := ReleaseCoupon(ctx, s.couponCollection, order.Coupon_Code, order.User_ID); err != nil {
+		log.Println("failed to release coupon", order.Coupon_Code, "of order", order.Order_ID.Hex(), err)
+	}
+}
+
+// outOfStockNote is the status note of orders that failed because their
+// stock ran out before the payment arrived.
+const outOfStockNote = "out of stock when the payment arrived"
+
+func (s *MongoStore) MarkOrderPaid(ctx context.Context, orderID primitive.ObjectID, payment models.Payment, note string) (models.Order, error) {
+	var order models.Order
+	var stockErr error
+	err := s.withTransaction(ctx, func(ctx context.Context) error {
+		stockErr = nil
+		err := s.fail("convert reservation")
+		if err == nil {
+			err = ConvertReservation(ctx, s.holdCollection, orderID)
+		}
+		if errors.Is(err, ErrReservationNotHeld) {
+			// The hold lapsed before the payment arrived; the order can
+			// only be paid while its items are still in stock.
+			err = RetakeReservation(ctx, s.prodCollection, s.stockCollection, s.holdCollection, orderID)
+		}
+		if errors.Is(err, ErrInsufficientStock) {
+			stockErr = err
+			failed, err := s.failOrder(ctx, orderID, payment, outOfStockNote)
+			order = failed
+			return err
+		}
+		if errors.Is(err, ErrReservationNotHeld) {
+			log.Println("paid order", orderID.Hex(), "has no stock held:", err)
+		} else if err != nil && !errors.Is(err, ErrReservationNotFound) {
+			return err
+		}
+
+		if err := s.fail("mark paid"); err != nil {
+			return err
+		}
+		if _, err := UpdateOrderStatus(ctx, s.orderCollection, orderID, models.OrderPaid, note); err != nil {
+			return err
+		}
+		if err := s.fail("update payment"); err != nil {
+			return err
+		}
+		paid, err := UpdatePayment(ctx, s.orderCollection, orderID, payment)
+		if err != nil {
+			return err
+		}
+		order = paid
+		return nil
+	})
+	if err == nil {
+		err = stockErr
+	}
+	return order, err
+}
+
+// failOrder marks an order failed with its payment and gives back the use of
+// its coupon, leaving its stock to the caller.
+func (s *MongoStore) failOrder(ctx context.Context, orderID primitive.ObjectID, payment models.Payment, note string) (models.Order, error) {
+	if err := s.fail("mark failed"); err != nil {
+		return models.Order{}, err
+	}
+	failed, err := UpdateOrderStatus(ctx, s.orderCollection, orderID, models.OrderFailed, note)
+	if err != nil {
+		return failed, err
+	}
+	if err := s.fail("update payment"); err != nil {
+		return failed, err
+	}
+	if failed, err = UpdatePayment(ctx, s.orderCollection, orderID, payment); err != nil {
+		return failed, err
+	}
+	if failed.Coupon_Code != "" {
+		if err := ReleaseCoupon(ctx, s.couponCollection, failed.Coupon_Code, failed.User_ID); err != nil {
+			return failed, err
+		}
+	}
+	return failed, nil
+}
+
+func (s *MongoStore) MarkOrderFailed(ctx context.Context, orderID primitive.ObjectID, payment models.Payment, note string) (models.Order, error) {
+	var order models.Order
+	err := s.withTransaction(ctx, func(ctx context.Context) error {
+		failed, err := s.failOrder(ctx, orderID, payment, note)
+		if err != nil {
+			return err
+		}
+		order = failed
+
+		if err := s.fail("release reservation"); err != nil {
+			return err
+		}
+		err = ReleaseReservation(ctx, s.prodCollection, s.stockCollection, s.holdCollection, orderID)
+		if errors.Is(err, ErrReservationNotFound) {
+			return ReleaseStock(ctx, s.prodCollection, s.stockCollection, orderID, order.Order_Cart)
+		}
+		return err
+	})
+	return order, err
+}
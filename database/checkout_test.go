@@ -0,0 +1,371 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// checkoutStores is what the checkout tests use of a store.
+type checkoutStores interface {
+	ProductStore
+	UserStore
+	CartStore
+	OrderStore
+	CheckoutStore
+	CouponStore
+}
+
+// checkoutOrder is a user with two laptops in the cart, the laptop with
+// three units in stock and a coupon, all seeded into stores, along with an
+// order of the two laptops using the coupon and its stock reservation.
+type checkoutOrder struct {
+	stores      checkoutStores
+	product     models.Product
+	user        models.User
+	order       models.Order
+	reservation models.Reservation
+}
+
+// checkoutFixture is a checkoutOrder seeded into a memory store.
+type checkoutFixture struct {
+	store *MemoryStore
+	checkoutOrder
+}
+
+func newCheckoutFixture(t *testing.T) checkoutFixture {
+	t.Helper()
+	store := NewMemoryStore()
+	return checkoutFixture{store: store, checkoutOrder: seedCheckoutOrder(t, store)}
+}
+
+func seedCheckoutOrder(t *testing.T, stores checkoutStores) checkoutOrder {
+	t.Helper()
+	ctx := context.Background()
+
+	name, price := "laptop", int64(200)
+	product := models.Product{Product_ID: primitive.NewObjectID(), Product_Name: &name, Price: &price, Stock: 3}
+	if err := stores.AddProduct(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+
+	userID := primitive.NewObjectID()
+	user := models.User{ID: userID, User_ID: userID.Hex(), UserCart: []models.ProductUser{models.NewProductUser(product, 2)}}
+	if err := stores.CreateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if err := stores.CreateCoupon(ctx, models.Coupon{Code: "SAVE10", Max_Uses: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	total := 2 * price
+	order := models.Order{
+		Order_ID:    primitive.NewObjectID(),
+		User_ID:     user.User_ID,
+		Order_Cart:  []models.ProductUser{models.NewProductUser(product, 2)},
+		Ordered_At:  time.Now().UTC(),
+		Price:       &total,
+		Coupon_Code: "SAVE10",
+		Status:      models.OrderPlaced,
+		Status_History: []models.StatusChange{
+			{Status: models.OrderPlaced, Changed_At: time.Now().UTC()},
+		},
+		Payment_method: models.Payment{Method: models.PaymentCard, Status: models.PaymentPending, Amount: total},
+	}
+	reservation := models.Reservation{
+		Order_ID:   order.Order_ID,
+		User_ID:    order.User_ID,
+		Items:      order.Order_Cart,
+		Status:     models.ReservationHeld,
+		Expires_At: time.Now().UTC().Add(time.Minute),
+		Created_At: time.Now().UTC(),
+	}
+	return checkoutOrder{stores: stores, product: product, user: user, order: order, reservation: reservation}
+}
+
+// place places the order, which must succeed.
+func (o checkoutOrder) place(t *testing.T) {
+	t.Helper()
+	if err := o.stores.PlaceOrder(context.Background(), o.order, o.reservation, true); err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+}
+
+// expect checks the stock of the laptop, the uses of the coupon and the
+// number of lines left in the cart.
+func (o checkoutOrder) expect(t *testing.T, stock int64, couponUses, cartLines int) {
+	t.Helper()
+	ctx := context.Background()
+
+	product, err := o.stores.FindProduct(ctx, o.product.Product_ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if product.Stock != stock {
+		t.Errorf("stock = %d, want %d", product.Stock, stock)
+	}
+	coupon, err := o.stores.FindCoupon(ctx, "SAVE10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if coupon.Uses != couponUses {
+		t.Errorf("coupon uses = %d, want %d", coupon.Uses, couponUses)
+	}
+	cart, err := o.stores.GetCart(ctx, o.user.User_ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cart) != cartLines {
+		t.Errorf("cart has %d lines, want %d", len(cart), cartLines)
+	}
+}
+
+// expectStatus checks the status of the order.
+func (o checkoutOrder) expectStatus(t *testing.T, status models.OrderStatus) models.Order {
+	t.Helper()
+	order, err := o.stores.FindOrderByID(context.Background(), o.order.Order_ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != status {
+		t.Errorf("order is %s, want %s", order.Status, status)
+	}
+	return order
+}
+
+// expectNoOrder checks that the order was not stored.
+func (o checkoutOrder) expectNoOrder(t *testing.T) {
+	t.Helper()
+	if _, err := o.stores.FindOrderByID(context.Background(), o.order.Order_ID); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("FindOrderByID = %v, want %v", err, ErrOrderNotFound)
+	}
+}
+
+func TestPlaceOrder(t *testing.T) {
+	f := newCheckoutFixture(t)
+	f.place(t)
+
+	f.expect(t, 1, 1, 0)
+	f.expectStatus(t, models.OrderPlaced)
+}
+
+func TestPlaceOrderFailureLeavesNothing(t *testing.T) {
+	tests := []struct {
+		name   string
+		inject func(t *testing.T, f *checkoutFixture)
+		want   error
+	}{
+		{
+			name: "stock short",
+			inject: func(t *testing.T, f *checkoutFixture) {
+				f.reservation.Items = []models.ProductUser{models.NewProductUser(f.product, 4)}
+			},
+			want: ErrInsufficientStock,
+		},
+		{
+			name: "coupon used up",
+			inject: func(t *testing.T, f *checkoutFixture) {
+				if err := f.store.redeemCoupon("SAVE10", "someone else"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: ErrCouponUsedUp,
+		},
+		{
+			name: "coupon missing",
+			inject: func(t *testing.T, f *checkoutFixture) {
+				f.order.Coupon_Code = "NOPE"
+			},
+			want: ErrCouponNotFound,
+		},
+		{
+			name: "user missing",
+			inject: func(t *testing.T, f *checkoutFixture) {
+				f.order.User_ID = primitive.NewObjectID().Hex()
+			},
+			want: ErrUserNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := newCheckoutFixture(t)
+			test.inject(t, &f)
+			coupon, err := f.store.FindCoupon(context.Background(), "SAVE10")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = f.store.PlaceOrder(context.Background(), f.order, f.reservation, true)
+			if !errors.Is(err, test.want) {
+				t.Fatalf("PlaceOrder = %v, want %v", err, test.want)
+			}
+			f.expect(t, 3, coupon.Uses, 1)
+			f.expectNoOrder(t)
+			if err := f.store.ConvertReservation(context.Background(), f.order.Order_ID); !errors.Is(err, ErrReservationNotFound) {
+				t.Errorf("ConvertReservation = %v, want %v", err, ErrReservationNotFound)
+			}
+		})
+	}
+}
+
+func TestPlaceOrderTwice(t *testing.T) {
+	f := newCheckoutFixture(t)
+	f.place(t)
+
+	if err := f.store.PlaceOrder(context.Background(), f.order, f.reservation, false); err == nil {
+		t.Fatal("placing the same order twice succeeded")
+	}
+	f.expect(t, 1, 1, 0)
+}
+
+func TestMarkOrderPaid(t *testing.T) {
+	f := newCheckoutFixture(t)
+	f.place(t)
+
+	payment := f.order.Payment_method
+	payment.Status = models.PaymentCaptured
+	order, err := f.store.MarkOrderPaid(context.Background(), f.order.Order_ID, payment, "paid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Payment_method.Status != models.PaymentCaptured {
+		t.Errorf("payment is %s, want %s", order.Payment_method.Status, models.PaymentCaptured)
+	}
+	f.expectStatus(t, models.OrderPaid)
+	f.expect(t, 1, 1, 0)
+	if got := f.store.reservations[f.order.Order_ID].Status; got != models.ReservationConverted {
+		t.Errorf("reservation is %s, want %s", got, models.ReservationConverted)
+	}
+}
+
+func TestMarkOrderPaidInvalidTransitionLeavesNothing(t *testing.T) {
+	f := newCheckoutFixture(t)
+	f.place(t)
+	ctx := context.Background()
+	if _, err := f.store.UpdateOrderStatus(ctx, f.order.Order_ID, models.OrderCancelled, "cancelled"); err != nil {
+		t.Fatal(err)
+	}
+
+	payment := f.order.Payment_method
+	payment.Status = models.PaymentCaptured
+	if _, err := f.store.MarkOrderPaid(ctx, f.order.Order_ID, payment, "paid"); !errors.Is(err, ErrInvalidOrderTransition) {
+		t.Fatalf("MarkOrderPaid = %v, want %v", err, ErrInvalidOrderTransition)
+	}
+	order := f.expectStatus(t, models.OrderCancelled)
+	if order.Payment_method.Status != models.PaymentPending {
+		t.Errorf("payment is %s, want it untouched", order.Payment_method.Status)
+	}
+	if got := f.store.reservations[f.order.Order_ID].Status; got != models.ReservationHeld {
+		t.Errorf("reservation is %s, want it untouched", got)
+	}
+}
+
+func TestMarkOrderPaidRetakesExpiredHold(t *testing.T) {
+	f := newCheckoutFixture(t)
+	f.place(t)
+	ctx := context.Background()
+	if _, err := f.store.ExpireReservations(ctx, time.Now().UTC().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	f.expect(t, 3, 1, 0)
+
+	payment := f.order.Payment_method
+	payment.Status = models.PaymentCaptured
+	if _, err := f.store.MarkOrderPaid(ctx, f.order.Order_ID, payment, "paid"); err != nil {
+		t.Fatal(err)
+	}
+	f.expectStatus(t, models.OrderPaid)
+	f.expect(t, 1, 1, 0)
+	if got := f.store.reservations[f.order.Order_ID].Status; got != models.ReservationConverted {
+		t.Errorf("reservation is %s, want %s", got, models.ReservationConverted)
+	}
+}
+
+func TestMarkOrderPaidFailsOrderWhenStockRanOut(t *testing.T) {
+	f := newCheckoutFixture(t)
+	f.place(t)
+	ctx := context.Background()
+	if _, err := f.store.ExpireReservations(ctx, time.Now().UTC().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.store.SetStock(ctx, models.ItemKey{Product_ID: f.product.Product_ID}, 1, "sold elsewhere"); err != nil {
+		t.Fatal(err)
+	}
+
+	payment := f.order.Payment_method
+	payment.Status = models.PaymentCaptured
+	order, err := f.store.MarkOrderPaid(ctx, f.order.Order_ID, payment, "paid")
+	if !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("MarkOrderPaid = %v, want %v", err, ErrInsufficientStock)
+	}
+	if order.Status != models.OrderFailed || order.Payment_method.Status != models.PaymentCaptured {
+		t.Errorf("order is %s with a %s payment, want it failed with the payment captured", order.Status, order.Payment_method.Status)
+	}
+	f.expectStatus(t, models.OrderFailed)
+	f.expect(t, 1, 0, 0)
+}
+
+func TestMarkOrderFailed(t *testing.T) {
+	f := newCheckoutFixture(t)
+	f.place(t)
+
+	payment := f.order.Payment_method
+	payment.Status = models.PaymentDeclined
+	order, err := f.store.MarkOrderFailed(context.Background(), f.order.Order_ID, payment, "declined")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Payment_method.Status != models.PaymentDeclined {
+		t.Errorf("payment is %s, want %s", order.Payment_method.Status, models.PaymentDeclined)
+	}
+	f.expectStatus(t, models.OrderFailed)
+	f.expect(t, 3, 0, 0)
+
+	// Failing again is refused and gives nothing back twice.
+	if _, err := f.store.MarkOrderFailed(context.Background(), f.order.Order_ID, payment, "declined"); !errors.Is(err, ErrInvalidOrderTransition) {
+		t.Fatalf("MarkOrderFailed = %v, want %v", err, ErrInvalidOrderTransition)
+	}
+	f.expect(t, 3, 0, 0)
+}
+
+func TestMarkOrderFailedInvalidTransitionLeavesNothing(t *testing.T) {
+	f := newCheckoutFixture(t)
+	f.place(t)
+	ctx := context.Background()
+
+	paid := f.order.Payment_method
+	paid.Status = models.PaymentCaptured
+	if _, err := f.store.MarkOrderPaid(ctx, f.order.Order_ID, paid, "paid"); err != nil {
+		t.Fatal(err)
+	}
+
+	declined := f.order.Payment_method
+	declined.Status = models.PaymentDeclined
+	if _, err := f.store.MarkOrderFailed(ctx, f.order.Order_ID, declined, "declined"); !errors.Is(err, ErrInvalidOrderTransition) {
+		t.Fatalf("MarkOrderFailed = %v, want %v", err, ErrInvalidOrderTransition)
+	}
+	order := f.expectStatus(t, models.OrderPaid)
+	if order.Payment_method.Status != models.PaymentCaptured {
+		t.Errorf("payment is %s, want it untouched", order.Payment_method.Status)
+	}
+	f.expect(t, 1, 1, 0)
+}
+
+func TestMarkOrderFailedWithoutReservation(t *testing.T) {
+	f := newCheckoutFixture(t)
+	f.place(t)
+	// Orders placed before reservations existed took their stock directly.
+	delete(f.store.reservations, f.order.Order_ID)
+
+	payment := f.order.Payment_method
+	payment.Status = models.PaymentDeclined
+	if _, err := f.store.MarkOrderFailed(context.Background(), f.order.Order_ID, payment, "declined"); err != nil {
+		t.Fatal(err)
+	}
+	f.expect(t, 3, 0, 0)
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrCouponNotFound   = errors.New("coupon not found")
+	ErrCouponExists     = errors.New("a coupon with this code already exists")
+	ErrCouponUsedUp     = errors.New("this coupon has reached its usage limit")
+	ErrCantUpdateCoupon = errors.New("cannot update the coupon")
+	ErrCantGetCoupons   = errors.New("was unable to get the coupons")
+)
+
+func CreateCoupon(ctx context.Context, couponCollection *mongo.Collection, coupon models.Coupon) error {
+	if _, err := couponCollection.InsertOne(ctx, coupon); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrCouponExists
+		}
+		log.Println(err)
+		return ErrCantUpdateCoupon
+	}
+	return nil
+}
+
+// UpdateCoupon overwrites the definition of a coupon, keeping its usage
+// counts and creation time.
+func UpdateCoupon(ctx context.Context, couponCollection *mongo.Collection, coupon models.Coupon) (models.Coupon, error) {
+	update := bson.M{"$set": bson.M{
+		"description":       coupon.Description,
+		"type":              coupon.Type,
+		"value":             coupon.Value,
+		"buy_quantity":      coupon.Buy_Quantity,
+		"get_quantity":      coupon.Get_Quantity,
+		"starts_at":         coupon.Starts_At,
+		"ends_at":           coupon.Ends_At,
+		"min_cart_value":    coupon.Min_Cart_Value,
+		"max_uses":          coupon.Max_Uses,
+		"max_uses_per_user": coupon.Max_Uses_Per_User,
+		"product_ids":       coupon.Product_IDs,
+		"categories":        coupon.Categories,
+		"disabled":          coupon.Disabled,
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var updated models.Coupon
+	if err := couponCollection.FindOneAndUpdate(ctx, bson.M{"_id": coupon.Code}, update, opts).Decode(&updated); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return updated, ErrCouponNotFound
+		}
+		log.Println(err)
+		return updated, ErrCantUpdateCoupon
+	}
+	return updated, nil
+}
+
+func FindCoupon(ctx context.Context, couponCollection *mongo.Collection, code string) (models.Coupon, error) {
+	var coupon models.Coupon
+	if err := couponCollection.FindOne(ctx, bson.M{"_id": code}).Decode(&coupon); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return coupon, ErrCouponNotFound
+		}
+		log.Println(err)
+		return coupon, ErrCantGetCoupons
+	}
+	return coupon, nil
+}
+
+// ListCoupons returns every coupon, newest first.
+func ListCoupons(ctx context.Context, couponCollection *mongo.Collection) ([]models.Coupon, error) {
+	cursor, err := couponCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		log.Println(err)
+		return nil, ErrCantGetCoupons
+	}
+	defer cursor.Close(ctx)
+
+	coupons := make([]models.Coupon, 0)
+	if err := cursor.All(ctx, &coupons); err != nil {
+		log.Println(err)
+		return nil, ErrCantGetCoupons
+	}
+	return coupons, nil
+}
+
+// userUsesField is the path of the usage count of userID within a coupon.
+// User IDs are object IDs in hex, so they are safe as field names.
+func userUsesField(userID string) (string, error) {
+	if _, err := primitive.ObjectIDFromHex(userID); err != nil {
+		return "", ErrUserIdIsNotValid
+	}
+	return "user_uses." + userID, nil
+}
+
+// RedeemCoupon counts a use of a coupon by userID, failing with
+// ErrCouponUsedUp when that would go over either of its usage limits. The
+// limits are checked by the update itself so concurrent checkouts can't
+// overshoot them.
+func RedeemCoupon(ctx context.Context, couponCollection *mongo.Collection, code, userID string) error {
+	field, err := userUsesField(userID)
+	if err != nil {
+		return err
+	}
+
+	underLimit := func(count interface{}, limit string) bson.M {
+		return bson.M{"$or": bson.A{
+			bson.M{"$lte": bson.A{bson.M{"$ifNull": bson.A{"$" + limit, 0}}, 0}},
+			bson.M{"$lt": bson.A{bson.M{"$ifNull": bson.A{count, 0}}, "$" + limit}},
+		}}
+	}
+	filter := bson.M{
+		"_id": code,
+		"$expr": bson.M{"$and": bson.A{
+			underLimit("$uses", "max_uses"),
+			underLimit("$"+field, "max_uses_per_user"),
+		}},
+	}
+	update := bson.M{"$inc": bson.M{"uses": 1, field: 1}}
+
+	result, err := couponCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Println(err)
+		return ErrCantUpdateCoupon
+	}
+	if result.MatchedCount == 0 {
+		if _, err := FindCoupon(ctx, couponCollection, code); err != nil {
+			return err
+		}
+		return ErrCouponUsedUp
+	}
+	return nil
+}
+
+// ReleaseCoupon gives back a use of a coupon by userID, for orders that were
+// cancelled or never paid.
+func ReleaseCoupon(ctx context.Context, couponCollection *mongo.Collection, code, userID string) error {
+	field, err := userUsesField(userID)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": code, "uses": bson.M{"$gt": 0}, field: bson.M{"$gt": 0}}
+	update := bson.M{"$inc": bson.M{"uses": -1, field: -1}}
+	if _, err := couponCollection.UpdateOne(ctx, filter, update); err != nil {
+		log.Println(err)
+		return ErrCantUpdateCoupon
+	}
+	return nil
+}
+
+// SetCartCoupon applies a coupon code to the cart of a user; an empty code
+// removes it.
+func SetCartCoupon(ctx context.Context, userCollection *mongo.Collection, userID, code string) error {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println(err)
+		return ErrUserIdIsNotValid
+	}
+
+	update := bson.M{"$set": bson.M{"cart_coupon": code}}
+	if code == "" {
+		update = bson.M{"$unset": bson.M{"cart_coupon": ""}}
+	}
+	result, err := userCollection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		log.Println(err)
+		return ErrCantUpdateUser
+	}
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
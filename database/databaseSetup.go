@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
@@ -14,7 +15,12 @@ func DBSet() *mongo.Client {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://localhost:27017"))
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		uri = "mongodb://localhost:27017"
+	}
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -29,7 +35,15 @@ func DBSet() *mongo.Client {
 	return client
 }
 
-var Client *mongo.Client = DBSet()
+// NewStores returns the stores for the given backend: "memory" keeps all data
+// in process, anything else connects to MongoDB.
+func NewStores(backend string) Stores {
+	if backend == "memory" {
+		log.Println("Using in-memory storage")
+		return NewMemoryStores()
+	}
+	return NewMongoStores(DBSet())
+}
 
 func UserDatabase(client *mongo.Client, collectionName string) *mongo.Collection {
 	var collection *mongo.Collection = client.Database("Ecommerce").Collection(collectionName)
@@ -0,0 +1,221 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrGuestCartTokenIsNotValid = errors.New("this cart token is not valid")
+
+// MergeStrategy decides the quantity of a product that is in both the guest
+// cart and the user's cart when the two are merged at login.
+type MergeStrategy string
+
+const (
+	// MergeSum adds both quantities together.
+	MergeSum MergeStrategy = "sum"
+	// MergeMax keeps the larger of the two quantities.
+	MergeMax MergeStrategy = "max"
+	// MergeKeepNewest keeps the line that was changed most recently.
+	MergeKeepNewest MergeStrategy = "newest"
+	// MergeKeepUser keeps the line already in the user's cart.
+	MergeKeepUser MergeStrategy = "user"
+	// MergeKeepGuest keeps the line from the guest cart.
+	MergeKeepGuest MergeStrategy = "guest"
+)
+
+// ParseMergeStrategy parses a strategy name, defaulting to MergeSum when empty.
+func ParseMergeStrategy(name string) (MergeStrategy, error) {
+	switch strategy := MergeStrategy(name); strategy {
+	case "":
+		return MergeSum, nil
+	case MergeSum, MergeMax, MergeKeepNewest, MergeKeepUser, MergeKeepGuest:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown cart merge strategy %q", name)
+	}
+}
+
+// MergeCartItems merges a guest cart into a user's cart. Lines only present in
+// one cart are kept as they are; conflicting lines are resolved by strategy
+// and then hold no more units than stock has of their item, unless the
+// user's cart already had more. Items missing from stock are not limited.
+func MergeCartItems(userCart, guestCart []models.ProductUser, strategy MergeStrategy, stock map[models.ItemKey]int64) []models.ProductUser {
+	merged := append(make([]models.ProductUser, 0, len(userCart)+len(guestCart)), userCart...)
+	for _, guestItem := range guestCart {
+		i := cartIndex(merged, guestItem.Key())
+		if i < 0 {
+			merged = append(merged, guestItem)
+			continue
+		}
+
+		userItem := merged[i]
+		switch strategy {
+		case MergeMax:
+			if guestItem.Quantity > userItem.Quantity {
+				merged[i] = guestItem
+			}
+		case MergeKeepNewest:
+			if guestItem.Updated_At.After(userItem.Updated_At) {
+				merged[i] = guestItem
+			}
+		case MergeKeepUser:
+		case MergeKeepGuest:
+			merged[i] = guestItem
+		default:
+			merged[i].Quantity += guestItem.Quantity
+			if guestItem.Updated_At.After(userItem.Updated_At) {
+				merged[i].Updated_At = guestItem.Updated_At
+			}
+		}
+
+		available, ok := stock[guestItem.Key()]
+		if ok && int64(merged[i].Quantity) > available && merged[i].Quantity > userItem.Quantity {
+			merged[i].Quantity = int(max(available, int64(userItem.Quantity)))
+		}
+	}
+	return merged
+}
+
+// localhost:8000/guest/addtocart?id={product_id}&variant={sku}&quantity={quantity}
+func AddProductToGuestCart(ctx context.Context, prodCollection, guestCollection *mongo.Collection, productID primitive.ObjectID, variant, cartToken string, quantity int) error {
+	if cartToken == "" {
+		return ErrGuestCartTokenIsNotValid
+	}
+
+	// Guest carts are created on their first item.
+	update := bson.M{
+		"$setOnInsert": bson.M{"usercart": make([]models.ProductUser, 0)},
+		"$set":         bson.M{"updated_at": time.Now().UTC()},
+	}
+	if _, err := guestCollection.UpdateOne(ctx, bson.M{"_id": cartToken}, update, options.Update().SetUpsert(true)); err != nil {
+		log.Println(err)
+		return ErrCantUpdateUser
+	}
+
+	return addCartLine(ctx, prodCollection, guestCollection, cartToken, models.ItemKey{Product_ID: productID, Variant: variant}, quantity)
+}
+
+func GetGuestCart(ctx context.Context, guestCollection *mongo.Collection, cartToken string) ([]models.ProductUser, error) {
+	var cart models.GuestCart
+	if err := guestCollection.FindOne(ctx, bson.M{"_id": cartToken}).Decode(&cart); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return make([]models.ProductUser, 0), nil
+		}
+		log.Println(err)
+		return nil, ErrCantGetItem
+	}
+	return cart.UserCart, nil
+}
+
+// maxMergeAttempts bounds how often MergeGuestCart retries when the user's
+// cart changes between reading and writing it.
+const maxMergeAttempts = 3
+
+// MergeGuestCart moves the guest cart into the user's cart and deletes it.
+// A missing guest cart is not an error: there is simply nothing to merge.
+// The merged cart is only written over the one it was merged from, so items
+// the user adds meanwhile, say from another device, are merged in on a retry
+// rather than lost.
+func (s *MongoStore) MergeGuestCart(ctx context.Context, cartToken, userID string, strategy MergeStrategy) error {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println(err)
+		return ErrUserIdIsNotValid
+	}
+
+	var guest models.GuestCart
+	if err := s.guestCollection.FindOne(ctx, bson.M{"_id": cartToken}).Decode(&guest); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		log.Println(err)
+		return ErrCantGetItem
+	}
+	stock, err := cartStock(ctx, s.prodCollection, guest.UserCart)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; attempt < maxMergeAttempts; attempt++ {
+		var user struct {
+			UserCart []models.ProductUser `bson:"usercart"`
+			Version  int64                `bson:"cart_version"`
+		}
+		if err := s.userCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&user); err != nil {
+			log.Println(err)
+			return ErrUserNotFound
+		}
+
+		merged := MergeCartItems(user.UserCart, guest.UserCart, strategy, stock)
+		filter := bson.M{"_id": id, "cart_version": user.Version}
+		if user.Version == 0 {
+			// Carts written before cart_version existed have none.
+			filter["cart_version"] = bson.M{"$in": bson.A{0, nil}}
+		}
+		if err := s.fail("write merged cart"); err != nil {
+			return err
+		}
+		result, err := s.userCollection.UpdateOne(ctx, filter, touchCart(bson.M{"$set": bson.M{"usercart": merged}}, time.Now().UTC()))
+		if err != nil {
+			log.Println(err)
+			return ErrCantUpdateUser
+		}
+		if result.MatchedCount == 0 {
+			continue
+		}
+
+		if _, err := s.guestCollection.DeleteOne(ctx, bson.M{"_id": cartToken}); err != nil {
+			log.Println(err)
+			return err
+		}
+		return nil
+	}
+
+	log.Println("the cart of user", userID, "kept changing while merging guest cart", cartToken)
+	return ErrCantUpdateUser
+}
+
+// cartStock returns the stock of the items of lines, leaving out items that
+// no longer exist.
+func cartStock(ctx context.Context, prodCollection *mongo.Collection, lines []models.ProductUser) (map[models.ItemKey]int64, error) {
+	ids := make([]primitive.ObjectID, 0, len(lines))
+	for _, line := range lines {
+		ids = append(ids, line.Product_ID)
+	}
+	cursor, err := prodCollection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		log.Println(err)
+		return nil, ErrCantFindProduct
+	}
+	var products []models.Product
+	if err := cursor.All(ctx, &products); err != nil {
+		log.Println(err)
+		return nil, ErrCantFindProduct
+	}
+
+	byID := make(map[primitive.ObjectID]models.Product, len(products))
+	for _, product := range products {
+		byID[product.Product_ID] = product
+	}
+	stock := make(map[models.ItemKey]int64, len(lines))
+	for _, line := range lines {
+		product, ok := byID[line.Product_ID]
+		if !ok {
+			continue
+		}
+		if available, err := AvailableStock(product, line.Variant); err == nil {
+			stock[line.Key()] = available
+		}
+	}
+	return stock, nil
+}
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMergeCartItems(t *testing.T) {
+	older, newer := time.Now().UTC().Add(-time.Hour), time.Now().UTC()
+	line := func(id primitive.ObjectID, quantity int, updated time.Time) models.ProductUser {
+		return models.ProductUser{Product_ID: id, Quantity: quantity, Updated_At: updated}
+	}
+	both, userOnly, guestOnly := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+
+	tests := []struct {
+		name     string
+		strategy MergeStrategy
+		user     int
+		guest    int
+		stock    int64
+		want     int
+	}{
+		{name: "sum", strategy: MergeSum, user: 2, guest: 3, stock: 10, want: 5},
+		{name: "sum over stock", strategy: MergeSum, user: 2, guest: 3, stock: 4, want: 4},
+		{name: "max", strategy: MergeMax, user: 2, guest: 3, stock: 10, want: 3},
+		{name: "max over stock", strategy: MergeMax, user: 1, guest: 3, stock: 2, want: 2},
+		{name: "newest", strategy: MergeKeepNewest, user: 2, guest: 3, stock: 10, want: 3},
+		{name: "user", strategy: MergeKeepUser, user: 2, guest: 3, stock: 10, want: 2},
+		{name: "guest over stock", strategy: MergeKeepGuest, user: 1, guest: 3, stock: 2, want: 2},
+		{name: "user already over stock", strategy: MergeSum, user: 3, guest: 1, stock: 2, want: 3},
+		{name: "stock unknown", strategy: MergeSum, user: 2, guest: 3, stock: -1, want: 5},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userCart := []models.ProductUser{line(both, test.user, older), line(userOnly, 1, older)}
+			guestCart := []models.ProductUser{line(both, test.guest, newer), line(guestOnly, 7, newer)}
+			stock := map[models.ItemKey]int64{{Product_ID: guestOnly}: 1}
+			if test.stock >= 0 {
+				stock[models.ItemKey{Product_ID: both}] = test.stock
+			}
+
+			merged := MergeCartItems(userCart, guestCart, test.strategy, stock)
+			if len(merged) != 3 {
+				t.Fatalf("merged %d lines, want 3", len(merged))
+			}
+			if merged[0].Quantity != test.want {
+				t.Errorf("quantity = %d, want %d", merged[0].Quantity, test.want)
+			}
+			// Lines in one cart only are kept as they are.
+			if merged[1].Quantity != 1 || merged[2].Quantity != 7 {
+				t.Errorf("lines in one cart only changed to %d and %d", merged[1].Quantity, merged[2].Quantity)
+			}
+		})
+	}
+}
+
+func TestMergeGuestCartLimitsToStock(t *testing.T) {
+	f := newCheckoutFixture(t)
+	ctx := context.Background()
+	if err := f.store.AddProductToGuestCart(ctx, f.product.Product_ID, "", "guest", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.store.MergeGuestCart(ctx, "guest", f.user.User_ID, MergeSum); err != nil {
+		t.Fatal(err)
+	}
+	cart, err := f.store.GetCart(ctx, f.user.User_ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cart) != 1 || cart[0].Quantity != 3 {
+		t.Errorf("cart = %+v, want one line of the 3 laptops in stock", cart)
+	}
+	if guest, _ := f.store.GetGuestCart(ctx, "guest"); len(guest) != 0 {
+		t.Errorf("guest cart still has %d lines", len(guest))
+	}
+}
+
+func TestMongoMergeGuestCartKeepsConcurrentAdds(t *testing.T) {
+	f := newMongoCheckoutFixture(t, false)
+	ctx := context.Background()
+	name, price := "mouse", int64(20)
+	mouse := models.Product{Product_ID: primitive.NewObjectID(), Product_Name: &name, Price: &price, Stock: 5}
+	if err := f.store.AddProduct(ctx, mouse); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.store.AddProductToGuestCart(ctx, f.product.Product_ID, "", "guest", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// The user adds a mouse from another device between the merge reading
+	// the cart and writing it back.
+	added := false
+	f.store.failpoint = func(step string) error {
+		if step == "write merged cart" && !added {
+			added = true
+			return f.store.AddProductToCart(ctx, mouse.Product_ID, "", f.user.User_ID, 1)
+		}
+		return nil
+	}
+	if err := f.store.MergeGuestCart(ctx, "guest", f.user.User_ID, MergeSum); err != nil {
+		t.Fatal(err)
+	}
+
+	cart, err := f.store.GetCart(ctx, f.user.User_ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quantities := make(map[primitive.ObjectID]int)
+	for _, line := range cart {
+		quantities[line.Product_ID] = line.Quantity
+	}
+	if len(cart) != 2 || quantities[f.product.Product_ID] != 3 || quantities[mouse.Product_ID] != 1 {
+		t.Errorf("cart = %v, want the 3 laptops in stock and the mouse", quantities)
+	}
+	if guest, _ := f.store.GetGuestCart(ctx, "guest"); len(guest) != 0 {
+		t.Errorf("guest cart still has %d lines", len(guest))
+	}
+}
+
+func TestMongoGuestCartWritesRefreshUpdatedAt(t *testing.T) {
+	store := newTestMongoStore(t, false)
+	ctx := context.Background()
+	name, price := "laptop", int64(200)
+	product := models.Product{Product_ID: primitive.NewObjectID(), Product_Name: &name, Price: &price, Stock: 10}
+	if err := store.AddProduct(ctx, product); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddProductToGuestCart(ctx, product.Product_ID, "", "guest", 5); err != nil {
+		t.Fatal(err)
+	}
+
+	writes := []struct {
+		name  string
+		write func() error
+	}{
+		{"set quantity", func() error { return store.SetGuestCartItemQuantity(ctx, product.Product_ID, "", "guest", 4) }},
+		{"decrement", func() error { return store.DecrementGuestCartItem(ctx, product.Product_ID, "", "guest", 1) }},
+		{"remove", func() error { return store.RemoveGuestCartItem(ctx, product.Product_ID, "", "guest") }},
+	}
+	for _, w := range writes {
+		var before models.GuestCart
+		if err := store.guestCollection.FindOne(ctx, bson.M{"_id": "guest"}).Decode(&before); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+		if err := w.write(); err != nil {
+			t.Fatalf("%s: %v", w.name, err)
+		}
+		var after models.GuestCart
+		if err := store.guestCollection.FindOne(ctx, bson.M{"_id": "guest"}).Decode(&after); err != nil {
+			t.Fatal(err)
+		}
+		if !after.Updated_At.After(before.Updated_At) {
+			t.Errorf("%s left updated_at at %s", w.name, after.Updated_At)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrCantSaveIdempotencyKey = errors.New("cannot save the idempotency key")
+
+// IdempotencyRecordID scopes an idempotency key to who sent it.
+func IdempotencyRecordID(userID, key string) string {
+	return userID + ":" + key
+}
+
+// BeginIdempotentRequest claims the key of record for a new request. When an
+// unexpired record already holds the key it is returned instead, with
+// claimed false.
+func BeginIdempotentRequest(ctx context.Context, keyCollection *mongo.Collection, record models.IdempotencyRecord) (models.IdempotencyRecord, bool, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		_, err := keyCollection.InsertOne(ctx, record)
+		if err == nil {
+			return record, true, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			log.Println(err)
+			return record, false, ErrCantSaveIdempotencyKey
+		}
+
+		var existing models.IdempotencyRecord
+		if err := keyCollection.FindOne(ctx, bson.M{"_id": record.Record_ID}).Decode(&existing); err != nil {
+			if err == mongo.ErrNoDocuments {
+				// Expired and removed in the meantime; try again.
+				continue
+			}
+			log.Println(err)
+			return record, false, ErrCantSaveIdempotencyKey
+		}
+		if existing.Expires_At.After(record.Created_At) {
+			return existing, false, nil
+		}
+
+		// The TTL monitor hasn't removed the expired record yet. Take it over
+		// unless another request did so first.
+		filter := bson.M{"_id": record.Record_ID, "expires_at": existing.Expires_At}
+		result, err := keyCollection.ReplaceOne(ctx, filter, record)
+		if err != nil {
+			log.Println(err)
+			return record, false, ErrCantSaveIdempotencyKey
+		}
+		if result.MatchedCount > 0 {
+			return record, true, nil
+		}
+	}
+	return record, false, ErrCantSaveIdempotencyKey
+}
+
+// CompleteIdempotentRequest stores the response of a claimed request.
+func CompleteIdempotentRequest(ctx context.Context, keyCollection *mongo.Collection, recordID string, code int, contentType string, body []byte) error {
+	update := bson.M{"$set": bson.M{
+		"status":        models.IdempotencyCompleted,
+		"response_code": code,
+		"content_type":  contentType,
+		"response_body": body,
+	}}
+	if _, err := keyCollection.UpdateOne(ctx, bson.M{"_id": recordID}, update); err != nil {
+		log.Println(err)
+		return ErrCantSaveIdempotencyKey
+	}
+	return nil
+}
+
+// AbandonIdempotentRequest frees the key of a request that failed on the
+// server's side, so a retry runs it again.
+func AbandonIdempotentRequest(ctx context.Context, keyCollection *mongo.Collection, recordID string) error {
+	if _, err := keyCollection.DeleteOne(ctx, bson.M{"_id": recordID}); err != nil {
+		log.Println(err)
+		return ErrCantSaveIdempotencyKey
+	}
+	return nil
+}
+
+func (s *MongoStore) BeginIdempotentRequest(ctx context.Context, record models.IdempotencyRecord) (models.IdempotencyRecord, bool, error) {
+	return BeginIdempotentRequest(ctx, s.keyCollection, record)
+}
+
+func (s *MongoStore) CompleteIdempotentRequest(ctx context.Context, recordID string, code int, contentType string, body []byte) error {
+	return CompleteIdempotentRequest(ctx, s.keyCollection, recordID, code, contentType, body)
+}
+
+func (s *MongoStore) AbandonIdempotentRequest(ctx context.Context, recordID string) error {
+	return AbandonIdempotentRequest(ctx, s.keyCollection, recordID)
+}
+
+func (s *MemoryStore) BeginIdempotentRequest(ctx context.Context, record models.IdempotencyRecord) (models.IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.idempotencyKeys[record.Record_ID]; ok && existing.Expires_At.After(record.Created_At) {
+		return *existing, false, nil
+	}
+	s.idempotencyKeys[record.Record_ID] = &record
+	s.forgetExpiredKeys(record.Created_At)
+	return record, true, nil
+}
+
+func (s *MemoryStore) CompleteIdempotentRequest(ctx context.Context, recordID string, code int, contentType string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.idempotencyKeys[recordID]
+	if !ok {
+		return nil
+	}
+	record.Status = models.IdempotencyCompleted
+	record.Response_Code = code
+	record.Content_Type = contentType
+	record.Response_Body = append([]byte(nil), body...)
+	return nil
+}
+
+func (s *MemoryStore) AbandonIdempotentRequest(ctx context.Context, recordID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.idempotencyKeys, recordID)
+	return nil
+}
+
+// forgetExpiredKeys drops expired records, standing in for the TTL index of
+// the Mongo store; the caller must hold s.mu.
+func (s *MemoryStore) forgetExpiredKeys(now time.Time) {
+	for id, record := range s.idempotencyKeys {
+		if !record.Expires_At.After(now) {
+			delete(s.idempotencyKeys, id)
+		}
+	}
+}
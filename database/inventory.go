@@ -0,0 +1,435 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInsufficientStock = errors.New("not enough stock")
+	ErrVariantRequired   = errors.New("choose a variant of this product")
+	ErrVariantNotFound   = errors.New("this variant of the product does not exist")
+	ErrNegativeStock     = errors.New("stock cannot go below zero")
+	ErrCantUpdateStock   = errors.New("cannot update the stock")
+
+	ErrReservationNotFound = errors.New("no stock is reserved for this order")
+	ErrReservationNotHeld  = errors.New("the stock reservation was already released")
+)
+
+// NewCartLine returns a line of quantity units of a product, or of one of its
+// variants, at the current price. It fails when the variant doesn't fit the
+// product or fewer than quantity units are in stock.
+func NewCartLine(product models.Product, variant string, quantity int) (models.ProductUser, error) {
+	line := models.NewProductUser(product, quantity)
+	available, err := AvailableStock(product, variant)
+	if err != nil {
+		return line, err
+	}
+	if v, ok := product.FindVariant(variant); ok {
+		line.Variant = v.SKU
+		if v.Price != nil {
+			line.Price = v.Price
+			line.Prices = v.Prices
+		}
+	}
+	if int64(quantity) > available {
+		return line, insufficientStock(line, available)
+	}
+	return line, nil
+}
+
+// AvailableStock returns the stock count of a product, or of one of its
+// variants when variant is set.
+func AvailableStock(product models.Product, variant string) (int64, error) {
+	if variant == "" {
+		if len(product.Variants) > 0 {
+			return 0, ErrVariantRequired
+		}
+		return product.Stock, nil
+	}
+	v, ok := product.FindVariant(variant)
+	if !ok {
+		return 0, ErrVariantNotFound
+	}
+	return v.Stock, nil
+}
+
+func insufficientStock(line models.ProductUser, available int64) error {
+	name := line.Product_ID.Hex()
+	if line.Product_Name != nil {
+		name = *line.Product_Name
+	}
+	if line.Variant != "" {
+		name += " (" + line.Variant + ")"
+	}
+	return fmt.Errorf("%w: %d of %s left", ErrInsufficientStock, available, name)
+}
+
+func newStockAdjustment(key models.ItemKey, delta, stock int64, reason models.StockReason, note string, orderID *primitive.ObjectID) models.StockAdjustment {
+	return models.StockAdjustment{
+		Adjustment_ID: primitive.NewObjectID(),
+		Product_ID:    key.Product_ID,
+		Variant:       key.Variant,
+		Delta:         delta,
+		Stock:         stock,
+		Reason:        reason,
+		Note:          note,
+		Order_ID:      orderID,
+		Created_At:    time.Now().UTC(),
+	}
+}
+
+// stockFilter selects the product holding the stock count of key and returns
+// the path of that count for updates. A non-nil atLeast only matches when at
+// least that many units are in stock, which makes decrements atomic.
+func stockFilter(key models.ItemKey, atLeast *int64) (bson.M, string) {
+	if key.Variant == "" {
+		// Products with variants have no stock of their own.
+		filter := bson.M{"_id": key.Product_ID, "variants.0": bson.M{"$exists": false}}
+		if atLeast != nil {
+			filter["stock"] = bson.M{"$gte": *atLeast}
+		}
+		return filter, "stock"
+	}
+
+	match := bson.M{"sku": key.Variant}
+	if atLeast != nil {
+		match["stock"] = bson.M{"$gte": *atLeast}
+	}
+	return bson.M{"_id": key.Product_ID, "variants": bson.M{"$elemMatch": match}}, "variants.$.stock"
+}
+
+// stockOf reads the stock count of key from a product document.
+func stockOf(product models.Product, key models.ItemKey) int64 {
+	stock, _ := AvailableStock(product, key.Variant)
+	return stock
+}
+
+// stockError explains why a conditional stock update matched no product;
+// notEnough builds the error for a product that exists but is short.
+func stockError(ctx context.Context, prodCollection *mongo.Collection, key models.ItemKey, notEnough func(available int64) error) error {
+	var product models.Product
+	if err := prodCollection.FindOne(ctx, bson.M{"_id": key.Product_ID}).Decode(&product); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ErrCantFindProduct
+		}
+		log.Println(err)
+		return ErrCantUpdateStock
+	}
+	available, err := AvailableStock(product, key.Variant)
+	if err != nil {
+		return err
+	}
+	return notEnough(available)
+}
+
+// ReserveStock takes the items of a reservation out of stock and stores the
+// reservation, all or nothing: when an item is short, the items already
+// taken are put back and the reservation fails with ErrInsufficientStock.
+func ReserveStock(ctx context.Context, prodCollection, adjustmentCollection, reservationCollection *mongo.Collection, reservation models.Reservation) error {
+	adjustments, giveBack, err := takeStock(ctx, prodCollection, reservation)
+	if err != nil {
+		return err
+	}
+
+	if _, err := reservationCollection.InsertOne(ctx, reservation); err != nil {
+		log.Println(err)
+		giveBack()
+		return ErrCantUpdateStock
+	}
+	recordStockAdjustments(ctx, adjustmentCollection, adjustments)
+	return nil
+}
+
+// takeStock takes the items of a reservation out of stock, all or nothing,
+// and returns the adjustments to record along with a function putting the
+// items back.
+func takeStock(ctx context.Context, prodCollection *mongo.Collection, reservation models.Reservation) ([]models.StockAdjustment, func(), error) {
+	adjustments := make([]models.StockAdjustment, 0, len(reservation.Items))
+	taken := make([]models.ProductUser, 0, len(reservation.Items))
+	giveBack := func() {
+		if _, err := restock(ctx, prodCollection, taken); err != nil {
+			log.Println("failed to put back stock of order", reservation.Order_ID.Hex(), err)
+		}
+	}
+
+	for _, line := range reservation.Items {
+		quantity := int64(line.Quantity)
+		filter, field := stockFilter(line.Key(), &quantity)
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+		var product models.Product
+		err := prodCollection.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{field: -quantity}}, opts).Decode(&product)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				err = stockError(ctx, prodCollection, line.Key(), func(available int64) error {
+					return insufficientStock(line, available)
+				})
+			} else {
+				log.Println(err)
+				err = ErrCantUpdateStock
+			}
+			giveBack()
+			return nil, nil, err
+		}
+
+		taken = append(taken, line)
+		adjustments = append(adjustments, newStockAdjustment(line.Key(), -quantity, stockOf(product, line.Key()), models.StockReserved, "", &reservation.Order_ID))
+	}
+	return adjustments, giveBack, nil
+}
+
+// ConvertReservation turns the held stock of an order into a sale. It fails
+// with ErrReservationNotHeld when the hold was released or expired first.
+func ConvertReservation(ctx context.Context, reservationCollection *mongo.Collection, orderID primitive.ObjectID) error {
+	filter := bson.M{"_id": orderID, "status": models.ReservationHeld}
+	result, err := reservationCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"status": models.ReservationConverted}})
+	if err != nil {
+		log.Println(err)
+		return ErrCantUpdateStock
+	}
+	if result.MatchedCount > 0 {
+		return nil
+	}
+	if _, err := findReservation(ctx, reservationCollection, orderID); err != nil {
+		return err
+	}
+	return ErrReservationNotHeld
+}
+
+// RetakeReservation takes the items of an expired hold out of stock again and
+// turns them into a sale, for a payment that arrived after the hold lapsed.
+// It fails with ErrInsufficientStock, taking nothing, when an item ran out
+// in the meantime and with ErrReservationNotHeld when the hold didn't expire.
+func RetakeReservation(ctx context.Context, prodCollection, adjustmentCollection, reservationCollection *mongo.Collection, orderID primitive.ObjectID) error {
+	reservation, err := findReservation(ctx, reservationCollection, orderID)
+	if err != nil {
+		return err
+	}
+	if reservation.Status != models.ReservationExpired {
+		return ErrReservationNotHeld
+	}
+
+	adjustments, giveBack, err := takeStock(ctx, prodCollection, reservation)
+	if err != nil {
+		return err
+	}
+	filter := bson.M{"_id": orderID, "status": models.ReservationExpired}
+	result, err := reservationCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"status": models.ReservationConverted}})
+	if err != nil {
+		log.Println(err)
+		giveBack()
+		return ErrCantUpdateStock
+	}
+	if result.MatchedCount == 0 {
+		giveBack()
+		return ErrReservationNotHeld
+	}
+	recordStockAdjustments(ctx, adjustmentCollection, adjustments)
+	return nil
+}
+
+// ListExpiredReservations returns the holds that expired by now but are
+// still held.
+func ListExpiredReservations(ctx context.Context, reservationCollection *mongo.Collection, now time.Time) ([]models.Reservation, error) {
+	filter := bson.M{"status": models.ReservationHeld, "expires_at": bson.M{"$lte": now}}
+	cursor, err := reservationCollection.Find(ctx, filter)
+	if err != nil {
+		log.Println(err)
+		return nil, ErrCantGetItem
+	}
+	defer cursor.Close(ctx)
+
+	expired := make([]models.Reservation, 0)
+	if err := cursor.All(ctx, &expired); err != nil {
+		log.Println(err)
+		return nil, ErrCantGetItem
+	}
+	return expired, nil
+}
+
+// ReleaseReservation puts the stock reserved for an order back, whether it
+// is still held or was already sold. Releasing twice is a no-op.
+func ReleaseReservation(ctx context.Context, prodCollection, adjustmentCollection, reservationCollection *mongo.Collection, orderID primitive.ObjectID) error {
+	filter := bson.M{"_id": orderID, "status": bson.M{"$in": bson.A{models.ReservationHeld, models.ReservationConverted}}}
+	reservation, err := endReservation(ctx, reservationCollection, filter, models.ReservationReleased)
+	if err == mongo.ErrNoDocuments {
+		_, err = findReservation(ctx, reservationCollection, orderID)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	return ReleaseStock(ctx, prodCollection, adjustmentCollection, orderID, reservation.Items)
+}
+
+// ExpireReservations puts back the stock of every hold that expired by now
+// and returns those reservations. Each hold is claimed with a conditional
+// update, so concurrent sweepers never release the same one twice.
+func ExpireReservations(ctx context.Context, prodCollection, adjustmentCollection, reservationCollection *mongo.Collection, now time.Time) ([]models.Reservation, error) {
+	expired := make([]models.Reservation, 0)
+	filter := bson.M{"status": models.ReservationHeld, "expires_at": bson.M{"$lte": now}}
+	for {
+		reservation, err := endReservation(ctx, reservationCollection, filter, models.ReservationExpired)
+		if err == mongo.ErrNoDocuments {
+			return expired, nil
+		}
+		if err != nil {
+			return expired, err
+		}
+		if err := ReleaseStock(ctx, prodCollection, adjustmentCollection, reservation.Order_ID, reservation.Items); err != nil {
+			return expired, err
+		}
+		expired = append(expired, reservation)
+	}
+}
+
+// endReservation moves the reservation matching filter to status and returns
+// it, or mongo.ErrNoDocuments when none matches.
+func endReservation(ctx context.Context, reservationCollection *mongo.Collection, filter bson.M, status models.ReservationStatus) (models.Reservation, error) {
+	var reservation models.Reservation
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := reservationCollection.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"status": status}}, opts).Decode(&reservation)
+	if err != nil && err != mongo.ErrNoDocuments {
+		log.Println(err)
+		return reservation, ErrCantUpdateStock
+	}
+	return reservation, err
+}
+
+func findReservation(ctx context.Context, reservationCollection *mongo.Collection, orderID primitive.ObjectID) (models.Reservation, error) {
+	var reservation models.Reservation
+	if err := reservationCollection.FindOne(ctx, bson.M{"_id": orderID}).Decode(&reservation); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return reservation, ErrReservationNotFound
+		}
+		log.Println(err)
+		return reservation, ErrCantGetItem
+	}
+	return reservation, nil
+}
+
+// ReleaseStock puts the lines of an order that won't be fulfilled back into
+// stock.
+func ReleaseStock(ctx context.Context, prodCollection, adjustmentCollection *mongo.Collection, orderID primitive.ObjectID, lines []models.ProductUser) error {
+	stocks, err := restock(ctx, prodCollection, lines)
+	if err != nil {
+		return err
+	}
+
+	adjustments := make([]models.StockAdjustment, 0, len(lines))
+	for i, line := range lines {
+		adjustments = append(adjustments, newStockAdjustment(line.Key(), int64(line.Quantity), stocks[i], models.StockReleased, "", &orderID))
+	}
+	recordStockAdjustments(ctx, adjustmentCollection, adjustments)
+	return nil
+}
+
+// restock adds the quantities of lines back to their stock counts and returns
+// the resulting counts.
+func restock(ctx context.Context, prodCollection *mongo.Collection, lines []models.ProductUser) ([]int64, error) {
+	stocks := make([]int64, 0, len(lines))
+	for _, line := range lines {
+		filter, field := stockFilter(line.Key(), nil)
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+		var product models.Product
+		err := prodCollection.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{field: line.Quantity}}, opts).Decode(&product)
+		if err != nil && err != mongo.ErrNoDocuments {
+			log.Println(err)
+			return stocks, ErrCantUpdateStock
+		}
+		// Products that disappeared since the sale have nothing to restock.
+		stocks = append(stocks, stockOf(product, line.Key()))
+	}
+	return stocks, nil
+}
+
+// AdjustStock changes the stock of a product or variant by delta.
+func AdjustStock(ctx context.Context, prodCollection, adjustmentCollection *mongo.Collection, key models.ItemKey, delta int64, note string) (models.StockAdjustment, error) {
+	var atLeast *int64
+	if delta < 0 {
+		needed := -delta
+		atLeast = &needed
+	}
+	filter, field := stockFilter(key, atLeast)
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var product models.Product
+	if err := prodCollection.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{field: delta}}, opts).Decode(&product); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.StockAdjustment{}, stockError(ctx, prodCollection, key, func(int64) error { return ErrNegativeStock })
+		}
+		log.Println(err)
+		return models.StockAdjustment{}, ErrCantUpdateStock
+	}
+
+	adjustment := newStockAdjustment(key, delta, stockOf(product, key), models.StockAdjusted, note, nil)
+	recordStockAdjustments(ctx, adjustmentCollection, []models.StockAdjustment{adjustment})
+	return adjustment, nil
+}
+
+// SetStock overwrites the stock of a product or variant, e.g. after a stock
+// count, and records the difference.
+func SetStock(ctx context.Context, prodCollection, adjustmentCollection *mongo.Collection, key models.ItemKey, stock int64, note string) (models.StockAdjustment, error) {
+	if stock < 0 {
+		return models.StockAdjustment{}, ErrNegativeStock
+	}
+	filter, field := stockFilter(key, nil)
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+
+	var before models.Product
+	if err := prodCollection.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{field: stock}}, opts).Decode(&before); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return models.StockAdjustment{}, stockError(ctx, prodCollection, key, func(int64) error { return ErrCantUpdateStock })
+		}
+		log.Println(err)
+		return models.StockAdjustment{}, ErrCantUpdateStock
+	}
+
+	adjustment := newStockAdjustment(key, stock-stockOf(before, key), stock, models.StockAdjusted, note, nil)
+	recordStockAdjustments(ctx, adjustmentCollection, []models.StockAdjustment{adjustment})
+	return adjustment, nil
+}
+
+// recordStockAdjustments appends to the audit trail. The stock has already
+// changed at this point, so failures are logged rather than returned.
+func recordStockAdjustments(ctx context.Context, adjustmentCollection *mongo.Collection, adjustments []models.StockAdjustment) {
+	if len(adjustments) == 0 {
+		return
+	}
+	documents := make([]interface{}, 0, len(adjustments))
+	for _, adjustment := range adjustments {
+		documents = append(documents, adjustment)
+	}
+	if _, err := adjustmentCollection.InsertMany(ctx, documents); err != nil {
+		log.Println("failed to record stock adjustments:", err)
+	}
+}
+
+// ListStockAdjustments returns the audit trail of a product, newest first.
+func ListStockAdjustments(ctx context.Context, adjustmentCollection *mongo.Collection, productID primitive.ObjectID) ([]models.StockAdjustment, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := adjustmentCollection.Find(ctx, bson.M{"product_id": productID}, opts)
+	if err != nil {
+		log.Println(err)
+		return nil, ErrCantGetItem
+	}
+	defer cursor.Close(ctx)
+
+	adjustments := make([]models.StockAdjustment, 0)
+	if err := cursor.All(ctx, &adjustments); err != nil {
+		log.Println(err)
+		return nil, ErrCantGetItem
+	}
+	return adjustments, nil
+}
@@ -0,0 +1,1532 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryStore implements the store interfaces in process memory. Data is lost
+// when the process exits, which makes it suitable for development and CI.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	products map[primitive.ObjectID]models.Product
+	// productOrder keeps products listed in insertion order.
+	productOrder []primitive.ObjectID
+	users        map[primitive.ObjectID]*models.User
+	guestCarts   map[string]*models.GuestCart
+	orders       map[primitive.ObjectID]*models.Order
+	orderIDs     []primitive.ObjectID
+	returns      map[primitive.ObjectID]*models.ReturnRequest
+	returnIDs    []primitive.ObjectID
+	shipments    map[primitive.ObjectID]*models.Shipment
+	// shipmentIDs keeps shipments in the order they were created.
+	shipmentIDs []primitive.ObjectID
+	events      map[string]models.PaymentEvent
+	// adjustments is the inventory audit trail, oldest first.
+	adjustments  []models.StockAdjustment
+	reservations map[primitive.ObjectID]*models.Reservation
+	// idempotencyKeys are keyed by IdempotencyRecordID.
+	idempotencyKeys map[string]*models.IdempotencyRecord
+	coupons         map[string]*models.Coupon
+	promotions      map[primitive.ObjectID]*models.Promotion
+	sessions        map[primitive.ObjectID]*models.Session
+	// revocations is the token revocation list, oldest first.
+	revocations []models.Revocation
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		products:     make(map[primitive.ObjectID]models.Product),
+		users:        make(map[primitive.ObjectID]*models.User),
+		guestCarts:   make(map[string]*models.GuestCart),
+		orders:       make(map[primitive.ObjectID]*models.Order),
+		returns:      make(map[primitive.ObjectID]*models.ReturnRequest),
+		shipments:    make(map[primitive.ObjectID]*models.Shipment),
+		events:       make(map[string]models.PaymentEvent),
+		reservations: make(map[primitive.ObjectID]*models.Reservation),
+
+		idempotencyKeys: make(map[string]*models.IdempotencyRecord),
+		coupons:         make(map[string]*models.Coupon),
+		promotions:      make(map[primitive.ObjectID]*models.Promotion),
+		sessions:        make(map[primitive.ObjectID]*models.Session),
+	}
+}
+
+// NewMemoryStores returns Stores backed by a single MemoryStore.
+func NewMemoryStores() Stores {
+	store := NewMemoryStore()
+	return Stores{
+		Products:      store,
+		Users:         store,
+		Carts:         store,
+		GuestCarts:    store,
+		Orders:        store,
+		Returns:       store,
+		Shipments:     store,
+		PaymentEvents: store,
+		Inventory:     store,
+		Checkout:      store,
+		Idempotency:   store,
+		Coupons:       store,
+		Promotions:    store,
+		Sessions:      store,
+		Revocations:   store,
+	}
+}
+
+func (s *MemoryStore) AddProduct(ctx context.Context, product models.Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.products[product.Product_ID]; !ok {
+		s.productOrder = append(s.productOrder, product.Product_ID)
+	}
+	// Stock changes replace the variants slice rather than writing to it, so
+	// the products handed out never change underneath their readers.
+	product.Variants = append([]models.Variant(nil), product.Variants...)
+	s.products[product.Product_ID] = product
+	return nil
+}
+
+func (s *MemoryStore) FindProduct(ctx context.Context, productID primitive.ObjectID) (models.Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	product, ok := s.products[productID]
+	if !ok {
+		return product, ErrCantFindProduct
+	}
+	return product, nil
+}
+
+func (s *MemoryStore) UpdateProductPrice(ctx context.Context, key models.ItemKey, price int64, prices []models.Money) (models.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	product, ok := s.products[key.Product_ID]
+	if !ok {
+		return product, ErrCantFindProduct
+	}
+	prices = append([]models.Money(nil), prices...)
+	if key.Variant == "" {
+		product.Price = &price
+		product.Prices = prices
+		s.products[key.Product_ID] = product
+		return product, nil
+	}
+
+	variants := append([]models.Variant(nil), product.Variants...)
+	for i := range variants {
+		if variants[i].SKU == key.Variant {
+			variants[i].Price = &price
+			variants[i].Prices = prices
+			product.Variants = variants
+			s.products[key.Product_ID] = product
+			return product, nil
+		}
+	}
+	return product, ErrVariantNotFound
+}
+
+func (s *MemoryStore) ListProducts(ctx context.Context) ([]models.Product, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	products := make([]models.Product, 0, len(s.productOrder))
+	for _, id := range s.productOrder {
+		products = append(products, s.products[id])
+	}
+	return products, nil
+}
+
+func (s *MemoryStore) SearchProducts(ctx context.Context, name string) ([]models.Product, error) {
+	pattern, err := regexp.Compile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	products := make([]models.Product, 0)
+	for _, id := range s.productOrder {
+		product := s.products[id]
+		if product.Product_Name != nil && pattern.MatchString(*product.Product_Name) {
+			products = append(products, product)
+		}
+	}
+	return products, nil
+}
+
+func (s *MemoryStore) CreateUser(ctx context.Context, user models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[user.ID]; ok {
+		return ErrCantCreateUser
+	}
+	stored := cloneUser(user)
+	s.users[user.ID] = &stored
+	return nil
+}
+
+func (s *MemoryStore) FindUser(ctx context.Context, userID string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, err := s.user(userID)
+	if err != nil {
+		return models.User{}, err
+	}
+	return cloneUser(*user), nil
+}
+
+func (s *MemoryStore) FindUserByEmail(ctx context.Context, email string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.Email != nil && *user.Email == email {
+			return cloneUser(*user), nil
+		}
+	}
+	return models.User{}, ErrUserNotFound
+}
+
+func (s *MemoryStore) EmailExists(ctx context.Context, email string) (bool, error) {
+	_, err := s.FindUserByEmail(ctx, email)
+	if err == ErrUserNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *MemoryStore) PhoneExists(ctx context.Context, phone string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.Phone != nil && *user.Phone == phone {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *MemoryStore) UpdateTokens(ctx context.Context, userID, token, refreshToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	user.Token = &token
+	user.Refresh_Token = &refreshToken
+	user.Updated_At = time.Now().UTC().Truncate(time.Second)
+	return nil
+}
+
+func (s *MemoryStore) AddAddress(ctx context.Context, userID string, address models.Address) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	user.Address_Details = append(user.Address_Details, address)
+	clearAddressDefaults(user.Address_Details, address)
+	return nil
+}
+
+func (s *MemoryStore) UpdateAddress(ctx context.Context, userID string, address models.Address) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	for i := range user.Address_Details {
+		if user.Address_Details[i].Address_ID == address.Address_ID {
+			user.Address_Details[i] = address
+			clearAddressDefaults(user.Address_Details, address)
+			return nil
+		}
+	}
+	return ErrAddressNotFound
+}
+
+func (s *MemoryStore) DeleteAddress(ctx context.Context, userID string, addressID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	for i, address := range user.Address_Details {
+		if address.Address_ID == addressID {
+			user.Address_Details = append(user.Address_Details[:i:i], user.Address_Details[i+1:]...)
+			return nil
+		}
+	}
+	return ErrAddressNotFound
+}
+
+// clearAddressDefaults unsets the default flags address holds on the other
+// addresses among addresses.
+func clearAddressDefaults(addresses []models.Address, address models.Address) {
+	for i := range addresses {
+		if addresses[i].Address_ID == address.Address_ID {
+			continue
+		}
+		if address.Default_Shipping {
+			addresses[i].Default_Shipping = false
+		}
+		if address.Default_Billing {
+			addresses[i].Default_Billing = false
+		}
+	}
+}
+
+func (s *MemoryStore) AddProductToCart(ctx context.Context, productID primitive.ObjectID, variant, userID string, quantity int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	return s.addLine(&user.UserCart, models.ItemKey{Product_ID: productID, Variant: variant}, quantity)
+}
+
+func (s *MemoryStore) SetCartItemQuantity(ctx context.Context, productID primitive.ObjectID, variant, userID string, quantity int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	return s.setLineQuantity(&user.UserCart, models.ItemKey{Product_ID: productID, Variant: variant}, quantity)
+}
+
+func (s *MemoryStore) DecrementCartItem(ctx context.Context, productID primitive.ObjectID, variant, userID string, quantity int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	return decrementLine(&user.UserCart, models.ItemKey{Product_ID: productID, Variant: variant}, quantity)
+}
+
+func (s *MemoryStore) RemoveCartItem(ctx context.Context, productID primitive.ObjectID, variant, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	if err := removeLine(&user.UserCart, models.ItemKey{Product_ID: productID, Variant: variant}); err != nil && err != ErrCartItemNotFound {
+		return err
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetCart(ctx context.Context, userID string) ([]models.ProductUser, error) {
+	user, err := s.FindUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return user.UserCart, nil
+}
+
+func (s *MemoryStore) AddProductToGuestCart(ctx context.Context, productID primitive.ObjectID, variant, cartToken string, quantity int) error {
+	if cartToken == "" {
+		return ErrGuestCartTokenIsNotValid
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cart, ok := s.guestCarts[cartToken]
+	if !ok {
+		cart = &models.GuestCart{Cart_ID: cartToken, UserCart: make([]models.ProductUser, 0)}
+	}
+	if err := s.addLine(&cart.UserCart, models.ItemKey{Product_ID: productID, Variant: variant}, quantity); err != nil {
+		return err
+	}
+	cart.Updated_At = time.Now().UTC()
+	s.guestCarts[cartToken] = cart
+	return nil
+}
+
+func (s *MemoryStore) SetGuestCartItemQuantity(ctx context.Context, productID primitive.ObjectID, variant, cartToken string, quantity int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cart, ok := s.guestCarts[cartToken]
+	if !ok {
+		return ErrCartItemNotFound
+	}
+	cart.Updated_At = time.Now().UTC()
+	return s.setLineQuantity(&cart.UserCart, models.ItemKey{Product_ID: productID, Variant: variant}, quantity)
+}
+
+func (s *MemoryStore) DecrementGuestCartItem(ctx context.Context, productID primitive.ObjectID, variant, cartToken string, quantity int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cart, ok := s.guestCarts[cartToken]
+	if !ok {
+		return ErrCartItemNotFound
+	}
+	cart.Updated_At = time.Now().UTC()
+	return decrementLine(&cart.UserCart, models.ItemKey{Product_ID: productID, Variant: variant}, quantity)
+}
+
+func (s *MemoryStore) RemoveGuestCartItem(ctx context.Context, productID primitive.ObjectID, variant, cartToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cart, ok := s.guestCarts[cartToken]
+	if !ok {
+		return ErrCartItemNotFound
+	}
+	cart.Updated_At = time.Now().UTC()
+	return removeLine(&cart.UserCart, models.ItemKey{Product_ID: productID, Variant: variant})
+}
+
+func (s *MemoryStore) GetGuestCart(ctx context.Context, cartToken string) ([]models.ProductUser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cart, ok := s.guestCarts[cartToken]
+	if !ok {
+		return make([]models.ProductUser, 0), nil
+	}
+	return append(make([]models.ProductUser, 0, len(cart.UserCart)), cart.UserCart...), nil
+}
+
+func (s *MemoryStore) MergeGuestCart(ctx context.Context, cartToken, userID string, strategy MergeStrategy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	cart, ok := s.guestCarts[cartToken]
+	if !ok {
+		return nil
+	}
+	stock := make(map[models.ItemKey]int64, len(cart.UserCart))
+	for _, line := range cart.UserCart {
+		if available, err := s.stock(line.Key()); err == nil {
+			stock[line.Key()] = available
+		}
+	}
+	user.UserCart = MergeCartItems(user.UserCart, cart.UserCart, strategy, stock)
+	delete(s.guestCarts, cartToken)
+	return nil
+}
+
+// addLine adds quantity units of an item to cart; the caller must hold s.mu.
+func (s *MemoryStore) addLine(cart *[]models.ProductUser, key models.ItemKey, quantity int) error {
+	if quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+	product, ok := s.products[key.Product_ID]
+	if !ok {
+		return errCantFindProduct
+	}
+	line, err := NewCartLine(product, key.Variant, quantity)
+	if err != nil {
+		return err
+	}
+
+	if i := cartIndex(*cart, key); i >= 0 {
+		// The quantity already in the cart counts against the stock too.
+		total, err := NewCartLine(product, key.Variant, (*cart)[i].Quantity+quantity)
+		if err != nil {
+			return err
+		}
+		(*cart)[i].Quantity = total.Quantity
+		(*cart)[i].Updated_At = line.Updated_At
+		return nil
+	}
+	*cart = append(*cart, line)
+	return nil
+}
+
+func (s *MemoryStore) setLineQuantity(cart *[]models.ProductUser, key models.ItemKey, quantity int) error {
+	if quantity < 0 {
+		return ErrInvalidQuantity
+	}
+	if quantity == 0 {
+		return removeLine(cart, key)
+	}
+
+	i := cartIndex(*cart, key)
+	if i < 0 {
+		return ErrCartItemNotFound
+	}
+	product, ok := s.products[key.Product_ID]
+	if !ok {
+		return errCantFindProduct
+	}
+	if _, err := NewCartLine(product, key.Variant, quantity); err != nil {
+		return err
+	}
+	(*cart)[i].Quantity = quantity
+	(*cart)[i].Updated_At = time.Now().UTC()
+	return nil
+}
+
+func decrementLine(cart *[]models.ProductUser, key models.ItemKey, quantity int) error {
+	if quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+
+	i := cartIndex(*cart, key)
+	if i < 0 {
+		return ErrCartItemNotFound
+	}
+	if (*cart)[i].Quantity <= quantity {
+		return removeLine(cart, key)
+	}
+	(*cart)[i].Quantity -= quantity
+	(*cart)[i].Updated_At = time.Now().UTC()
+	return nil
+}
+
+func removeLine(cart *[]models.ProductUser, key models.ItemKey) error {
+	i := cartIndex(*cart, key)
+	if i < 0 {
+		return ErrCartItemNotFound
+	}
+	*cart = append((*cart)[:i:i], (*cart)[i+1:]...)
+	return nil
+}
+
+func (s *MemoryStore) ClearCart(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	user.UserCart = make([]models.ProductUser, 0)
+	user.Cart_Coupon = ""
+	return nil
+}
+
+func (s *MemoryStore) SetCartCoupon(ctx context.Context, userID, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, err := s.user(userID)
+	if err != nil {
+		return err
+	}
+	user.Cart_Coupon = code
+	return nil
+}
+
+func (s *MemoryStore) GetCartCoupon(ctx context.Context, userID string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, err := s.user(userID)
+	if err != nil {
+		return "", err
+	}
+	return user.Cart_Coupon, nil
+}
+
+func (s *MemoryStore) CreateCoupon(ctx context.Context, coupon models.Coupon) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.coupons[coupon.Code]; ok {
+		return ErrCouponExists
+	}
+	stored := cloneCoupon(coupon)
+	s.coupons[coupon.Code] = &stored
+	return nil
+}
+
+func (s *MemoryStore) UpdateCoupon(ctx context.Context, coupon models.Coupon) (models.Coupon, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.coupons[coupon.Code]
+	if !ok {
+		return models.Coupon{}, ErrCouponNotFound
+	}
+	coupon.Uses = stored.Uses
+	coupon.User_Uses = stored.User_Uses
+	coupon.Created_At = stored.Created_At
+	*stored = cloneCoupon(coupon)
+	return cloneCoupon(*stored), nil
+}
+
+func (s *MemoryStore) FindCoupon(ctx context.Context, code string) (models.Coupon, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	coupon, ok := s.coupons[code]
+	if !ok {
+		return models.Coupon{}, ErrCouponNotFound
+	}
+	return cloneCoupon(*coupon), nil
+}
+
+func (s *MemoryStore) ListCoupons(ctx context.Context) ([]models.Coupon, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	coupons := make([]models.Coupon, 0, len(s.coupons))
+	for _, coupon := range s.coupons {
+		coupons = append(coupons, cloneCoupon(*coupon))
+	}
+	sort.Slice(coupons, func(i, j int) bool { return coupons[i].Created_At.After(coupons[j].Created_At) })
+	return coupons, nil
+}
+
+func (s *MemoryStore) ReleaseCoupon(ctx context.Context, code, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.releaseCoupon(code, userID)
+	return nil
+}
+
+// redeemCoupon counts a use of a coupon; the caller must hold s.mu.
+func (s *MemoryStore) redeemCoupon(code, userID string) error {
+	coupon, ok := s.coupons[code]
+	if !ok {
+		return ErrCouponNotFound
+	}
+	if !coupon.UsesLeft(userID) {
+		return ErrCouponUsedUp
+	}
+	if coupon.User_Uses == nil {
+		coupon.User_Uses = make(map[string]int)
+	}
+	coupon.Uses++
+	coupon.User_Uses[userID]++
+	return nil
+}
+
+// releaseCoupon gives back a use of a coupon; the caller must hold s.mu.
+func (s *MemoryStore) releaseCoupon(code, userID string) {
+	coupon, ok := s.coupons[code]
+	if !ok || coupon.Uses == 0 || coupon.User_Uses[userID] == 0 {
+		return
+	}
+	coupon.Uses--
+	coupon.User_Uses[userID]--
+}
+
+func (s *MemoryStore) CreatePromotion(ctx context.Context, promotion models.Promotion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.promotions[promotion.Promotion_ID]; ok {
+		return ErrCantUpdatePromotion
+	}
+	stored := clonePromotion(promotion)
+	s.promotions[promotion.Promotion_ID] = &stored
+	return nil
+}
+
+func (s *MemoryStore) UpdatePromotion(ctx context.Context, promotion models.Promotion) (models.Promotion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.promotions[promotion.Promotion_ID]
+	if !ok {
+		return models.Promotion{}, ErrPromotionNotFound
+	}
+	promotion.Created_At = stored.Created_At
+	*stored = clonePromotion(promotion)
+	return clonePromotion(*stored), nil
+}
+
+func (s *MemoryStore) DeletePromotion(ctx context.Context, promotionID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.promotions[promotionID]; !ok {
+		return ErrPromotionNotFound
+	}
+	delete(s.promotions, promotionID)
+	return nil
+}
+
+func (s *MemoryStore) FindPromotion(ctx context.Context, promotionID primitive.ObjectID) (models.Promotion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	promotion, ok := s.promotions[promotionID]
+	if !ok {
+		return models.Promotion{}, ErrPromotionNotFound
+	}
+	return clonePromotion(*promotion), nil
+}
+
+func (s *MemoryStore) ListPromotions(ctx context.Context, enabledOnly bool) ([]models.Promotion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	promotions := make([]models.Promotion, 0, len(s.promotions))
+	for _, promotion := range s.promotions {
+		if enabledOnly && promotion.Disabled {
+			continue
+		}
+		promotions = append(promotions, clonePromotion(*promotion))
+	}
+	sort.Slice(promotions, func(i, j int) bool {
+		if promotions[i].Priority != promotions[j].Priority {
+			return promotions[i].Priority > promotions[j].Priority
+		}
+		return promotions[i].Created_At.Before(promotions[j].Created_At)
+	})
+	return promotions, nil
+}
+
+func (s *MemoryStore) CreateOrder(ctx context.Context, order models.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[order.Order_ID]; ok {
+		return ErrCantBuyCartItem
+	}
+	s.insertOrder(order)
+	return nil
+}
+
+func (s *MemoryStore) UpdatePayment(ctx context.Context, orderID primitive.ObjectID, payment models.Payment) (models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderID]
+	if !ok {
+		return models.Order{}, ErrOrderNotFound
+	}
+	order.Payment_method = payment
+	return cloneOrder(*order), nil
+}
+
+func (s *MemoryStore) FindOrder(ctx context.Context, userID string, orderID primitive.ObjectID) (models.Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	order, ok := s.orders[orderID]
+	if !ok || order.User_ID != userID {
+		return models.Order{}, ErrOrderNotFound
+	}
+	return cloneOrder(*order), nil
+}
+
+func (s *MemoryStore) ListOrders(ctx context.Context, userID string, page, pageSize int) ([]models.Order, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	userOrders := make([]*models.Order, 0)
+	for _, id := range s.orderIDs {
+		if order := s.orders[id]; order.User_ID == userID {
+			userOrders = append(userOrders, order)
+		}
+	}
+	sort.SliceStable(userOrders, func(i, j int) bool {
+		return userOrders[i].Ordered_At.After(userOrders[j].Ordered_At)
+	})
+
+	orders := make([]models.Order, 0, pageSize)
+	for i := (page - 1) * pageSize; i >= 0 && i < len(userOrders) && len(orders) < pageSize; i++ {
+		orders = append(orders, cloneOrder(*userOrders[i]))
+	}
+	return orders, int64(len(userOrders)), nil
+}
+
+func (s *MemoryStore) FindOrderByID(ctx context.Context, orderID primitive.ObjectID) (models.Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	order, ok := s.orders[orderID]
+	if !ok {
+		return models.Order{}, ErrOrderNotFound
+	}
+	return cloneOrder(*order), nil
+}
+
+func (s *MemoryStore) UpdateOrderStatus(ctx context.Context, orderID primitive.ObjectID, status models.OrderStatus, note string) (models.Order, error) {
+	if !status.Valid() {
+		return models.Order{}, ErrInvalidOrderStatus
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.updateOrderStatus(orderID, status, note)
+}
+
+// updateOrderStatus requires s.mu to be held.
+func (s *MemoryStore) updateOrderStatus(orderID primitive.ObjectID, status models.OrderStatus, note string) (models.Order, error) {
+	order, ok := s.orders[orderID]
+	if !ok {
+		return models.Order{}, ErrOrderNotFound
+	}
+	if !order.Status.CanTransitionTo(status) {
+		return cloneOrder(*order), transitionError(order.Status, status)
+	}
+	order.Status = status
+	order.Status_History = append(order.Status_History, models.StatusChange{Status: status, Changed_At: time.Now().UTC(), Note: note})
+	return cloneOrder(*order), nil
+}
+
+func (s *MemoryStore) AddRefund(ctx context.Context, orderID primitive.ObjectID, refund models.Refund) (models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderID]
+	if !ok {
+		return models.Order{}, ErrOrderNotFound
+	}
+	order.Refunds = append(order.Refunds, refund)
+	order.Refunded += refundedAmount(refund)
+	return cloneOrder(*order), nil
+}
+
+// insertOrder stores a copy of order; the caller must hold s.mu.
+func (s *MemoryStore) insertOrder(order models.Order) {
+	stored := cloneOrder(order)
+	s.orders[order.Order_ID] = &stored
+	s.orderIDs = append(s.orderIDs, order.Order_ID)
+}
+
+func (s *MemoryStore) CreateReturn(ctx context.Context, ret models.ReturnRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.returns[ret.Return_ID]; ok {
+		return ErrCantCreateReturn
+	}
+	stored := cloneReturn(ret)
+	s.returns[ret.Return_ID] = &stored
+	s.returnIDs = append(s.returnIDs, ret.Return_ID)
+	return nil
+}
+
+func (s *MemoryStore) FindReturn(ctx context.Context, returnID primitive.ObjectID) (models.ReturnRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ret, ok := s.returns[returnID]
+	if !ok {
+		return models.ReturnRequest{}, ErrReturnNotFound
+	}
+	return cloneReturn(*ret), nil
+}
+
+func (s *MemoryStore) ListOrderReturns(ctx context.Context, orderID primitive.ObjectID) ([]models.ReturnRequest, error) {
+	return s.listReturns(func(ret *models.ReturnRequest) bool { return ret.Order_ID == orderID }), nil
+}
+
+func (s *MemoryStore) ListReturns(ctx context.Context, userID string, status models.ReturnStatus) ([]models.ReturnRequest, error) {
+	return s.listReturns(func(ret *models.ReturnRequest) bool {
+		return (userID == "" || ret.User_ID == userID) && (status == "" || ret.Status == status)
+	}), nil
+}
+
+// listReturns returns the return requests matching match, newest first.
+func (s *MemoryStore) listReturns(match func(*models.ReturnRequest) bool) []models.ReturnRequest {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	returns := make([]models.ReturnRequest, 0)
+	for i := len(s.returnIDs) - 1; i >= 0; i-- {
+		if ret := s.returns[s.returnIDs[i]]; match(ret) {
+			returns = append(returns, cloneReturn(*ret))
+		}
+	}
+	return returns
+}
+
+func (s *MemoryStore) UpdateReturnStatus(ctx context.Context, returnID primitive.ObjectID, status models.ReturnStatus, note string) (models.ReturnRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ret, ok := s.returns[returnID]
+	if !ok {
+		return models.ReturnRequest{}, ErrReturnNotFound
+	}
+	if !ret.Status.CanTransitionTo(status) {
+		return cloneReturn(*ret), returnTransitionError(ret.Status, status)
+	}
+	ret.Status = status
+	ret.Status_History = append(ret.Status_History, models.ReturnStatusChange{Status: status, Changed_At: time.Now().UTC(), Note: note})
+	return cloneReturn(*ret), nil
+}
+
+func (s *MemoryStore) SetReturnRefund(ctx context.Context, returnID primitive.ObjectID, refund models.Refund) (models.ReturnRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ret, ok := s.returns[returnID]
+	if !ok {
+		return models.ReturnRequest{}, ErrReturnNotFound
+	}
+	ret.Refund = &refund
+	return cloneReturn(*ret), nil
+}
+
+// user returns the stored user; the caller must hold s.mu.
+func (s *MemoryStore) user(userID string) (*models.User, error) {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, ErrUserIdIsNotValid
+	}
+	user, ok := s.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func cartIndex(cart []models.ProductUser, key models.ItemKey) int {
+	for i, item := range cart {
+		if item.Key() == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// cloneUser copies the slices of a user so callers can't mutate stored state.
+func cloneUser(user models.User) models.User {
+	user.UserCart = append(make([]models.ProductUser, 0, len(user.UserCart)), user.UserCart...)
+	user.Address_Details = append(make([]models.Address, 0, len(user.Address_Details)), user.Address_Details...)
+	return user
+}
+
+// cloneOrder copies the slices of an order so callers can't mutate stored state.
+func cloneOrder(order models.Order) models.Order {
+	order.Order_Cart = append(make([]models.ProductUser, 0, len(order.Order_Cart)), order.Order_Cart...)
+	order.Status_History = append(make([]models.StatusChange, 0, len(order.Status_History)), order.Status_History...)
+	if order.Refunds != nil {
+		order.Refunds = append(make([]models.Refund, 0, len(order.Refunds)), order.Refunds...)
+	}
+	if order.Discounts != nil {
+		order.Discounts = append(make([]models.Discount, 0, len(order.Discounts)), order.Discounts...)
+	}
+	if order.Tax_Lines != nil {
+		order.Tax_Lines = append(make([]models.TaxLine, 0, len(order.Tax_Lines)), order.Tax_Lines...)
+	}
+	if order.Shipping_Address != nil {
+		address := *order.Shipping_Address
+		order.Shipping_Address = &address
+	}
+	if order.Billing_Address != nil {
+		address := *order.Billing_Address
+		order.Billing_Address = &address
+	}
+	if order.Shipping != nil {
+		shipping := *order.Shipping
+		order.Shipping = &shipping
+	}
+	return order
+}
+
+// cloneRule copies the slices of a discount rule so callers can't mutate
+// stored state.
+func cloneRule(rule models.DiscountRule) models.DiscountRule {
+	rule.Product_IDs = append([]primitive.ObjectID(nil), rule.Product_IDs...)
+	rule.Categories = append([]string(nil), rule.Categories...)
+	return rule
+}
+
+// clonePromotion copies the slices of a promotion so callers can't mutate
+// stored state.
+func clonePromotion(promotion models.Promotion) models.Promotion {
+	promotion.DiscountRule = cloneRule(promotion.DiscountRule)
+	return promotion
+}
+
+// cloneCoupon copies the slices and usage counts of a coupon so callers
+// can't mutate stored state.
+func cloneCoupon(coupon models.Coupon) models.Coupon {
+	coupon.DiscountRule = cloneRule(coupon.DiscountRule)
+	if coupon.User_Uses != nil {
+		uses := make(map[string]int, len(coupon.User_Uses))
+		for userID, n := range coupon.User_Uses {
+			uses[userID] = n
+		}
+		coupon.User_Uses = uses
+	}
+	return coupon
+}
+
+// cloneReturn copies the slices of a return request so callers can't mutate
+// stored state.
+func cloneReturn(ret models.ReturnRequest) models.ReturnRequest {
+	ret.Items = append(make([]models.ReturnItem, 0, len(ret.Items)), ret.Items...)
+	ret.Status_History = append(make([]models.ReturnStatusChange, 0, len(ret.Status_History)), ret.Status_History...)
+	if ret.Refund != nil {
+		refund := *ret.Refund
+		ret.Refund = &refund
+	}
+	return ret
+}
+
+func (s *MemoryStore) CreateShipment(ctx context.Context, shipment models.Shipment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.shipments[shipment.Shipment_ID]; ok {
+		return ErrCantCreateShipment
+	}
+	order, ok := s.orders[shipment.Order_ID]
+	if !ok {
+		return ErrOrderNotFound
+	}
+	if !order.Shippable() {
+		return ErrOrderNotShippable
+	}
+	previous := make([]models.Shipment, 0)
+	for _, id := range s.shipmentIDs {
+		if s.shipments[id].Order_ID == shipment.Order_ID {
+			previous = append(previous, *s.shipments[id])
+		}
+	}
+	if err := checkShipmentItems(order.Order_Cart, previous, shipment.Items); err != nil {
+		return err
+	}
+	if order.Status != models.OrderShipped {
+		if _, err := s.updateOrderStatus(order.Order_ID, models.OrderShipped, "shipment "+shipment.Tracking_Number); err != nil {
+			return err
+		}
+	}
+	stored := cloneShipment(shipment)
+	s.shipments[shipment.Shipment_ID] = &stored
+	s.shipmentIDs = append(s.shipmentIDs, shipment.Shipment_ID)
+	return nil
+}
+
+func (s *MemoryStore) FindShipment(ctx context.Context, shipmentID primitive.ObjectID) (models.Shipment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	shipment, ok := s.shipments[shipmentID]
+	if !ok {
+		return models.Shipment{}, ErrShipmentNotFound
+	}
+	return cloneShipment(*shipment), nil
+}
+
+func (s *MemoryStore) ListOrderShipments(ctx context.Context, orderID primitive.ObjectID) ([]models.Shipment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	shipments := make([]models.Shipment, 0)
+	for _, id := range s.shipmentIDs {
+		if shipment := s.shipments[id]; shipment.Order_ID == orderID {
+			shipments = append(shipments, cloneShipment(*shipment))
+		}
+	}
+	return shipments, nil
+}
+
+func (s *MemoryStore) AddShipmentEvents(ctx context.Context, shipmentID primitive.ObjectID, events []models.TrackingEvent) (models.Shipment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	shipment, ok := s.shipments[shipmentID]
+	if !ok {
+		return models.Shipment{}, ErrShipmentNotFound
+	}
+	if len(events) > 0 {
+		shipment.Events = append(shipment.Events, events...)
+		shipment.Status = events[len(events)-1].Status
+	}
+	return cloneShipment(*shipment), nil
+}
+
+func cloneShipment(shipment models.Shipment) models.Shipment {
+	shipment.Items = append(make([]models.ShipmentItem, 0, len(shipment.Items)), shipment.Items...)
+	shipment.Events = append(make([]models.TrackingEvent, 0, len(shipment.Events)), shipment.Events...)
+	return shipment
+}
+
+func (s *MemoryStore) CreateSession(ctx context.Context, session models.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[session.Session_ID] = &session
+	return nil
+}
+
+func (s *MemoryStore) FindSession(ctx context.Context, sessionID primitive.ObjectID) (models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return models.Session{}, ErrSessionNotFound
+	}
+	return *session, nil
+}
+
+func (s *MemoryStore) RotateSession(ctx context.Context, sessionID primitive.ObjectID, refreshID, nextID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return ErrSessionNotFound
+	}
+	if session.Revoked_At != nil || session.Refresh_ID != refreshID {
+		return rotationError(*session, refreshID)
+	}
+	session.Refresh_ID = nextID
+	session.Refreshed_At = time.Now().UTC()
+	session.Expires_At = expiresAt
+	return nil
+}
+
+func (s *MemoryStore) RevokeSession(ctx context.Context, sessionID primitive.ObjectID, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.sessions[sessionID]; ok && session.Revoked_At == nil {
+		now := time.Now().UTC()
+		session.Revoked_At = &now
+		session.Revoke_Reason = reason
+	}
+	return nil
+}
+
+func (s *MemoryStore) RevokeUserSessions(ctx context.Context, userID, reason string) ([]models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	revoked := make([]models.Session, 0)
+	for _, session := range s.sessions {
+		if session.User_ID == userID && session.Revoked_At == nil {
+			session.Revoked_At = &now
+			session.Revoke_Reason = reason
+			revoked = append(revoked, *session)
+		}
+	}
+	return revoked, nil
+}
+
+func (s *MemoryStore) AddRevocations(ctx context.Context, revocations []models.Revocation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Entries whose tokens all expired are dropped on the way.
+	now := time.Now().UTC()
+	live := s.revocations[:0]
+	for _, revocation := range s.revocations {
+		if revocation.Expires_At.After(now) {
+			live = append(live, revocation)
+		}
+	}
+	s.revocations = live
+
+	for _, revocation := range revocations {
+		known := false
+		for _, r := range s.revocations {
+			if r.Revocation_ID == revocation.Revocation_ID {
+				known = true
+				break
+			}
+		}
+		if !known {
+			s.revocations = append(s.revocations, revocation)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) ListRevocations(ctx context.Context, since time.Time) ([]models.Revocation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now().UTC()
+	revocations := make([]models.Revocation, 0)
+	for _, revocation := range s.revocations {
+		if !revocation.Revoked_At.Before(since) && revocation.Expires_At.After(now) {
+			revocations = append(revocations, revocation)
+		}
+	}
+	return revocations, nil
+}
+
+func (s *MemoryStore) RecordPaymentEvent(ctx context.Context, event models.PaymentEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.events[event.Event_ID]; ok {
+		return ErrDuplicatePaymentEvent
+	}
+	s.events[event.Event_ID] = event
+	return nil
+}
+
+func (s *MemoryStore) ForgetPaymentEvent(ctx context.Context, eventID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.events, eventID)
+	return nil
+}
+
+func (s *MemoryStore) ReserveStock(ctx context.Context, reservation models.Reservation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkReservation(reservation); err != nil {
+		return err
+	}
+	s.reserve(reservation)
+	return nil
+}
+
+// checkReservation reports whether every item of a reservation is in stock;
+// the caller must hold s.mu.
+func (s *MemoryStore) checkReservation(reservation models.Reservation) error {
+	if _, ok := s.reservations[reservation.Order_ID]; ok {
+		return ErrCantUpdateStock
+	}
+	return s.checkStock(reservation.Items)
+}
+
+// checkStock reports whether every line is in stock; the caller must hold
+// s.mu.
+func (s *MemoryStore) checkStock(lines []models.ProductUser) error {
+	// Check every item before taking anything so a short item leaves the
+	// stock untouched.
+	for _, line := range lines {
+		available, err := s.stock(line.Key())
+		if err != nil {
+			return err
+		}
+		if int64(line.Quantity) > available {
+			return insufficientStock(line, available)
+		}
+	}
+	return nil
+}
+
+// reserve takes the items of a checked reservation out of stock; the caller
+// must hold s.mu.
+func (s *MemoryStore) reserve(reservation models.Reservation) {
+	s.takeStock(reservation.Order_ID, reservation.Items)
+	reservation.Items = append([]models.ProductUser(nil), reservation.Items...)
+	s.reservations[reservation.Order_ID] = &reservation
+}
+
+// takeStock takes checked lines of an order out of stock; the caller must
+// hold s.mu.
+func (s *MemoryStore) takeStock(orderID primitive.ObjectID, lines []models.ProductUser) {
+	for _, line := range lines {
+		stock := s.changeStock(line.Key(), -int64(line.Quantity))
+		s.adjustments = append(s.adjustments, newStockAdjustment(line.Key(), -int64(line.Quantity), stock, models.StockReserved, "", &orderID))
+	}
+}
+
+func (s *MemoryStore) ConvertReservation(ctx context.Context, orderID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.convertReservation(orderID)
+}
+
+// convertReservation requires s.mu to be held.
+func (s *MemoryStore) convertReservation(orderID primitive.ObjectID) error {
+	reservation, ok := s.reservations[orderID]
+	if !ok {
+		return ErrReservationNotFound
+	}
+	if reservation.Status != models.ReservationHeld {
+		return ErrReservationNotHeld
+	}
+	reservation.Status = models.ReservationConverted
+	return nil
+}
+
+// retakeReservation takes the items of an expired hold out of stock again
+// and sells them; the caller must hold s.mu.
+func (s *MemoryStore) retakeReservation(orderID primitive.ObjectID) error {
+	reservation, ok := s.reservations[orderID]
+	if !ok {
+		return ErrReservationNotFound
+	}
+	if reservation.Status != models.ReservationExpired {
+		return ErrReservationNotHeld
+	}
+	if err := s.checkStock(reservation.Items); err != nil {
+		return err
+	}
+	s.takeStock(orderID, reservation.Items)
+	reservation.Status = models.ReservationConverted
+	return nil
+}
+
+func (s *MemoryStore) ReleaseReservation(ctx context.Context, orderID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.releaseReservation(orderID)
+}
+
+// releaseReservation requires s.mu to be held.
+func (s *MemoryStore) releaseReservation(orderID primitive.ObjectID) error {
+	reservation, ok := s.reservations[orderID]
+	if !ok {
+		return ErrReservationNotFound
+	}
+	if reservation.Status != models.ReservationHeld && reservation.Status != models.ReservationConverted {
+		return nil
+	}
+	reservation.Status = models.ReservationReleased
+	s.restock(orderID, reservation.Items)
+	return nil
+}
+
+func (s *MemoryStore) ListExpiredReservations(ctx context.Context, now time.Time) ([]models.Reservation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	expired := make([]models.Reservation, 0)
+	for _, reservation := range s.reservations {
+		if reservation.Status == models.ReservationHeld && !reservation.Expires_At.After(now) {
+			expired = append(expired, *reservation)
+		}
+	}
+	return expired, nil
+}
+
+func (s *MemoryStore) ExpireReservations(ctx context.Context, now time.Time) ([]models.Reservation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := make([]models.Reservation, 0)
+	for orderID, reservation := range s.reservations {
+		if reservation.Status != models.ReservationHeld || reservation.Expires_At.After(now) {
+			continue
+		}
+		reservation.Status = models.ReservationExpired
+		s.restock(orderID, reservation.Items)
+		expired = append(expired, *reservation)
+	}
+	return expired, nil
+}
+
+func (s *MemoryStore) ReleaseStock(ctx context.Context, orderID primitive.ObjectID, lines []models.ProductUser) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.restock(orderID, lines)
+	return nil
+}
+
+// restock puts the lines of an order back into stock; the caller must hold
+// s.mu.
+func (s *MemoryStore) restock(orderID primitive.ObjectID, lines []models.ProductUser) {
+	for _, line := range lines {
+		product, ok := s.products[line.Product_ID]
+		if !ok {
+			continue
+		}
+		if _, err := AvailableStock(product, line.Variant); err != nil {
+			continue
+		}
+		stock := s.changeStock(line.Key(), int64(line.Quantity))
+		s.adjustments = append(s.adjustments, newStockAdjustment(line.Key(), int64(line.Quantity), stock, models.StockReleased, "", &orderID))
+	}
+}
+
+func (s *MemoryStore) AdjustStock(ctx context.Context, key models.ItemKey, delta int64, note string) (models.StockAdjustment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	available, err := s.stock(key)
+	if err != nil {
+		return models.StockAdjustment{}, err
+	}
+	if available+delta < 0 {
+		return models.StockAdjustment{}, ErrNegativeStock
+	}
+	adjustment := newStockAdjustment(key, delta, s.changeStock(key, delta), models.StockAdjusted, note, nil)
+	s.adjustments = append(s.adjustments, adjustment)
+	return adjustment, nil
+}
+
+func (s *MemoryStore) SetStock(ctx context.Context, key models.ItemKey, stock int64, note string) (models.StockAdjustment, error) {
+	if stock < 0 {
+		return models.StockAdjustment{}, ErrNegativeStock
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	available, err := s.stock(key)
+	if err != nil {
+		return models.StockAdjustment{}, err
+	}
+	adjustment := newStockAdjustment(key, stock-available, s.changeStock(key, stock-available), models.StockAdjusted, note, nil)
+	s.adjustments = append(s.adjustments, adjustment)
+	return adjustment, nil
+}
+
+func (s *MemoryStore) ListStockAdjustments(ctx context.Context, productID primitive.ObjectID) ([]models.StockAdjustment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	adjustments := make([]models.StockAdjustment, 0)
+	for i := len(s.adjustments) - 1; i >= 0; i-- {
+		if s.adjustments[i].Product_ID == productID {
+			adjustments = append(adjustments, s.adjustments[i])
+		}
+	}
+	return adjustments, nil
+}
+
+// stock returns the stock count of an item; the caller must hold s.mu.
+func (s *MemoryStore) stock(key models.ItemKey) (int64, error) {
+	product, ok := s.products[key.Product_ID]
+	if !ok {
+		return 0, ErrCantFindProduct
+	}
+	return AvailableStock(product, key.Variant)
+}
+
+// changeStock adds delta to the stock count of an existing item and returns
+// the new count; the caller must hold s.mu.
+func (s *MemoryStore) changeStock(key models.ItemKey, delta int64) int64 {
+	product := s.products[key.Product_ID]
+	if key.Variant == "" {
+		product.Stock += delta
+		s.products[key.Product_ID] = product
+		return product.Stock
+	}
+
+	variants := append([]models.Variant(nil), product.Variants...)
+	var stock int64
+	for i := range variants {
+		if variants[i].SKU == key.Variant {
+			variants[i].Stock += delta
+			stock = variants[i].Stock
+		}
+	}
+	product.Variants = variants
+	s.products[key.Product_ID] = product
+	return stock
+}
+
+// PlaceOrder checks every step of the checkout before writing anything, and
+// holds the lock throughout, so a failing step leaves no trace.
+func (s *MemoryStore) PlaceOrder(ctx context.Context, order models.Order, reservation models.Reservation, clearCart bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[order.Order_ID]; ok {
+		return ErrCantBuyCartItem
+	}
+	var user *models.User
+	if clearCart {
+		var err error
+		if user, err = s.user(order.User_ID); err != nil {
+			return err
+		}
+	}
+	if err := s.checkReservation(reservation); err != nil {
+		return err
+	}
+	if order.Coupon_Code != "" {
+		if err := s.redeemCoupon(order.Coupon_Code, order.User_ID); err != nil {
+			return err
+		}
+	}
+
+	s.reserve(reservation)
+	s.insertOrder(order)
+	if user != nil {
+		user.UserCart = make([]models.ProductUser, 0)
+		user.Cart_Coupon = ""
+	}
+	return nil
+}
+
+func (s *MemoryStore) MarkOrderPaid(ctx context.Context, orderID primitive.ObjectID, payment models.Payment, note string) (models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderID]
+	if !ok {
+		return models.Order{}, ErrOrderNotFound
+	}
+	if !order.Status.CanTransitionTo(models.OrderPaid) {
+		return cloneOrder(*order), transitionError(order.Status, models.OrderPaid)
+	}
+
+	err := s.convertReservation(orderID)
+	if err == ErrReservationNotHeld {
+		err = s.retakeReservation(orderID)
+	}
+	if errors.Is(err, ErrInsufficientStock) {
+		if _, failErr := s.updateOrderStatus(orderID, models.OrderFailed, outOfStockNote); failErr != nil {
+			return models.Order{}, failErr
+		}
+		order.Payment_method = payment
+		s.releaseCoupon(order.Coupon_Code, order.User_ID)
+		return cloneOrder(*order), err
+	}
+	if err == ErrReservationNotHeld {
+		log.Println("paid order", orderID.Hex(), "has no stock held:", err)
+	} else if err != nil && err != ErrReservationNotFound {
+		return models.Order{}, err
+	}
+
+	if _, err := s.updateOrderStatus(orderID, models.OrderPaid, note); err != nil {
+		return models.Order{}, err
+	}
+	order.Payment_method = payment
+	return cloneOrder(*order), nil
+}
+
+func (s *MemoryStore) MarkOrderFailed(ctx context.Context, orderID primitive.ObjectID, payment models.Payment, note string) (models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, err := s.updateOrderStatus(orderID, models.OrderFailed, note)
+	if err != nil {
+		return models.Order{}, err
+	}
+	s.orders[orderID].Payment_method = payment
+	s.releaseCoupon(order.Coupon_Code, order.User_ID)
+	if err := s.releaseReservation(orderID); err == ErrReservationNotFound {
+		s.restock(orderID, order.Order_Cart)
+	}
+	return cloneOrder(*s.orders[orderID]), nil
+}
@@ -0,0 +1,627 @@
+package database
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore implements the store interfaces on top of MongoDB collections.
+type MongoStore struct {
+	client *mongo.Client
+	// transactions is set when the deployment supports multi-document
+	// transactions, which needs a replica set or a sharded cluster.
+	transactions bool
+
+	prodCollection   *mongo.Collection
+	userCollection   *mongo.Collection
+	guestCollection  *mongo.Collection
+	orderCollection  *mongo.Collection
+	returnCollection *mongo.Collection
+	eventCollection  *mongo.Collection
+	stockCollection  *mongo.Collection
+	holdCollection   *mongo.Collection
+	keyCollection    *mongo.Collection
+	couponCollection *mongo.Collection
+	promoCollection  *mongo.Collection
+	shipCollection   *mongo.Collection
+	sessCollection   *mongo.Collection
+	revokeCollection *mongo.Collection
+
+	// failpoint, when set, is asked before each step of a multi-step write
+	// and makes the step fail with the error it returns. Tests use it to see
+	// what a failure halfway through leaves behind.
+	failpoint func(step string) error
+}
+
+func NewMongoStore(client *mongo.Client) *MongoStore {
+	store := newMongoStore(client, client.Database("Ecommerce"))
+	store.transactions = supportsTransactions(client)
+	if !store.transactions {
+		if os.Getenv("MONGODB_ALLOW_STANDALONE") != "true" {
+			log.Fatal("MongoDB is not a replica set, which checkout needs for its transactions; " +
+				"run a replica set (a single-node one is enough) or set MONGODB_ALLOW_STANDALONE=true to run without them")
+		}
+		log.Println("WARNING: MongoDB is not a replica set and MONGODB_ALLOW_STANDALONE is set; checkout runs " +
+			"WITHOUT transactions, and a crash halfway through can leave stock, coupon uses and orders out of step")
+	}
+	store.createIndexes()
+	return store
+}
+
+// newMongoStore returns a store on the collections of db, without checking
+// the deployment or creating indexes.
+func newMongoStore(client *mongo.Client, db *mongo.Database) *MongoStore {
+	return &MongoStore{
+		client:           client,
+		prodCollection:   db.Collection("Products"),
+		userCollection:   db.Collection("Users"),
+		guestCollection:  db.Collection("GuestCarts"),
+		orderCollection:  db.Collection("Orders"),
+		returnCollection: db.Collection("Returns"),
+		eventCollection:  db.Collection("PaymentEvents"),
+		stockCollection:  db.Collection("StockAdjustments"),
+		holdCollection:   db.Collection("Reservations"),
+		keyCollection:    db.Collection("IdempotencyKeys"),
+		couponCollection: db.Collection("Coupons"),
+		promoCollection:  db.Collection("Promotions"),
+		shipCollection:   db.Collection("Shipments"),
+		sessCollection:   db.Collection("Sessions"),
+		revokeCollection: db.Collection("Revocations"),
+	}
+}
+
+// fail reports the error the failpoint injects at step, if any.
+func (s *MongoStore) fail(step string) error {
+	if s.failpoint == nil {
+		return nil
+	}
+	return s.failpoint(step)
+}
+
+// createIndexes makes sure the indexes the store relies on exist. Failures
+// are logged rather than fatal since the store still works without them.
+func (s *MongoStore) createIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := []struct {
+		collection *mongo.Collection
+		model      mongo.IndexModel
+	}{
+		// Abandoned guest carts expire after 30 days without changes.
+		{s.guestCollection, mongo.IndexModel{
+			Keys:    bson.D{{Key: "updated_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(30 * 24 * 60 * 60),
+		}},
+		// Order history of a user, newest first.
+		{s.orderCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "ordered_at", Value: -1}},
+		}},
+		{s.orderCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "ordered_at", Value: -1}},
+		}},
+		{s.returnCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "order_id", Value: 1}},
+		}},
+		// Audit trail of a product, newest first.
+		{s.stockCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "created_at", Value: -1}},
+		}},
+		// Expired holds for the reservation sweeper.
+		{s.holdCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "status", Value: 1}, {Key: "expires_at", Value: 1}},
+		}},
+		// Idempotency keys go once their replay window is over.
+		{s.keyCollection, mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		}},
+		// Providers stop redelivering webhook events long before 30 days.
+		{s.eventCollection, mongo.IndexModel{
+			Keys:    bson.D{{Key: "received_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(30 * 24 * 60 * 60),
+		}},
+		{s.returnCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+		}},
+		{s.shipCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "order_id", Value: 1}, {Key: "created_at", Value: 1}},
+		}},
+		// Sessions go once their last refresh token expired.
+		{s.sessCollection, mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		}},
+		{s.sessCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "user_id", Value: 1}},
+		}},
+		// Revocations go once the tokens they cover expired.
+		{s.revokeCollection, mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		}},
+		{s.revokeCollection, mongo.IndexModel{
+			Keys: bson.D{{Key: "revoked_at", Value: 1}},
+		}},
+	}
+	for _, index := range indexes {
+		if _, err := index.collection.Indexes().CreateOne(ctx, index.model); err != nil {
+			log.Println("failed to create index:", err)
+		}
+	}
+}
+
+// NewMongoStores returns Stores backed by a single MongoStore.
+func NewMongoStores(client *mongo.Client) Stores {
+	store := NewMongoStore(client)
+	return Stores{
+		Products:      store,
+		Users:         store,
+		Carts:         store,
+		GuestCarts:    store,
+		Orders:        store,
+		Returns:       store,
+		Shipments:     store,
+		PaymentEvents: store,
+		Inventory:     store,
+		Checkout:      store,
+		Idempotency:   store,
+		Coupons:       store,
+		Promotions:    store,
+		Sessions:      store,
+		Revocations:   store,
+	}
+}
+
+func (s *MongoStore) AddProductToCart(ctx context.Context, productID primitive.ObjectID, variant, userID string, quantity int) error {
+	return AddProductToCart(ctx, s.prodCollection, s.userCollection, productID, variant, userID, quantity)
+}
+
+func (s *MongoStore) SetCartItemQuantity(ctx context.Context, productID primitive.ObjectID, variant, userID string, quantity int) error {
+	return SetCartItemQuantity(ctx, s.prodCollection, s.userCollection, productID, variant, userID, quantity)
+}
+
+func (s *MongoStore) DecrementCartItem(ctx context.Context, productID primitive.ObjectID, variant, userID string, quantity int) error {
+	return DecrementCartItem(ctx, s.userCollection, productID, variant, userID, quantity)
+}
+
+func (s *MongoStore) RemoveCartItem(ctx context.Context, productID primitive.ObjectID, variant, userID string) error {
+	return RemoveCartIterm(ctx, s.prodCollection, s.userCollection, productID, variant, userID)
+}
+
+func (s *MongoStore) GetCart(ctx context.Context, userID string) ([]models.ProductUser, error) {
+	user, err := s.FindUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return user.UserCart, nil
+}
+
+func (s *MongoStore) AddProductToGuestCart(ctx context.Context, productID primitive.ObjectID, variant, cartToken string, quantity int) error {
+	return AddProductToGuestCart(ctx, s.prodCollection, s.guestCollection, productID, variant, cartToken, quantity)
+}
+
+func (s *MongoStore) SetGuestCartItemQuantity(ctx context.Context, productID primitive.ObjectID, variant, cartToken string, quantity int) error {
+	return setCartLineQuantity(ctx, s.prodCollection, s.guestCollection, cartToken, models.ItemKey{Product_ID: productID, Variant: variant}, quantity)
+}
+
+func (s *MongoStore) DecrementGuestCartItem(ctx context.Context, productID primitive.ObjectID, variant, cartToken string, quantity int) error {
+	return decrementCartLine(ctx, s.guestCollection, cartToken, models.ItemKey{Product_ID: productID, Variant: variant}, quantity)
+}
+
+func (s *MongoStore) RemoveGuestCartItem(ctx context.Context, productID primitive.ObjectID, variant, cartToken string) error {
+	return pullCartLine(ctx, s.guestCollection, cartToken, models.ItemKey{Product_ID: productID, Variant: variant})
+}
+
+func (s *MongoStore) GetGuestCart(ctx context.Context, cartToken string) ([]models.ProductUser, error) {
+	return GetGuestCart(ctx, s.guestCollection, cartToken)
+}
+
+func (s *MongoStore) ClearCart(ctx context.Context, userID string) error {
+	return ClearCart(ctx, s.userCollection, userID)
+}
+
+func (s *MongoStore) SetCartCoupon(ctx context.Context, userID, code string) error {
+	return SetCartCoupon(ctx, s.userCollection, userID, code)
+}
+
+func (s *MongoStore) GetCartCoupon(ctx context.Context, userID string) (string, error) {
+	user, err := s.FindUser(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return user.Cart_Coupon, nil
+}
+
+func (s *MongoStore) CreateCoupon(ctx context.Context, coupon models.Coupon) error {
+	return CreateCoupon(ctx, s.couponCollection, coupon)
+}
+
+func (s *MongoStore) UpdateCoupon(ctx context.Context, coupon models.Coupon) (models.Coupon, error) {
+	return UpdateCoupon(ctx, s.couponCollection, coupon)
+}
+
+func (s *MongoStore) FindCoupon(ctx context.Context, code string) (models.Coupon, error) {
+	return FindCoupon(ctx, s.couponCollection, code)
+}
+
+func (s *MongoStore) ListCoupons(ctx context.Context) ([]models.Coupon, error) {
+	return ListCoupons(ctx, s.couponCollection)
+}
+
+func (s *MongoStore) ReleaseCoupon(ctx context.Context, code, userID string) error {
+	return ReleaseCoupon(ctx, s.couponCollection, code, userID)
+}
+
+func (s *MongoStore) CreatePromotion(ctx context.Context, promotion models.Promotion) error {
+	return CreatePromotion(ctx, s.promoCollection, promotion)
+}
+
+func (s *MongoStore) UpdatePromotion(ctx context.Context, promotion models.Promotion) (models.Promotion, error) {
+	return UpdatePromotion(ctx, s.promoCollection, promotion)
+}
+
+func (s *MongoStore) DeletePromotion(ctx context.Context, promotionID primitive.ObjectID) error {
+	return DeletePromotion(ctx, s.promoCollection, promotionID)
+}
+
+func (s *MongoStore) FindPromotion(ctx context.Context, promotionID primitive.ObjectID) (models.Promotion, error) {
+	return FindPromotion(ctx, s.promoCollection, promotionID)
+}
+
+func (s *MongoStore) ListPromotions(ctx context.Context, enabledOnly bool) ([]models.Promotion, error) {
+	filter := bson.M{}
+	if enabledOnly {
+		filter["disabled"] = bson.M{"$ne": true}
+	}
+	return ListPromotions(ctx, s.promoCollection, filter)
+}
+
+func (s *MongoStore) CreateOrder(ctx context.Context, order models.Order) error {
+	return CreateOrder(ctx, s.orderCollection, order)
+}
+
+func (s *MongoStore) UpdatePayment(ctx context.Context, orderID primitive.ObjectID, payment models.Payment) (models.Order, error) {
+	return UpdatePayment(ctx, s.orderCollection, orderID, payment)
+}
+
+func (s *MongoStore) FindOrder(ctx context.Context, userID string, orderID primitive.ObjectID) (models.Order, error) {
+	return FindOrder(ctx, s.orderCollection, userID, orderID)
+}
+
+func (s *MongoStore) FindOrderByID(ctx context.Context, orderID primitive.ObjectID) (models.Order, error) {
+	return FindOrderByID(ctx, s.orderCollection, orderID)
+}
+
+func (s *MongoStore) UpdateOrderStatus(ctx context.Context, orderID primitive.ObjectID, status models.OrderStatus, note string) (models.Order, error) {
+	return UpdateOrderStatus(ctx, s.orderCollection, orderID, status, note)
+}
+
+func (s *MongoStore) AddRefund(ctx context.Context, orderID primitive.ObjectID, refund models.Refund) (models.Order, error) {
+	return AddRefund(ctx, s.orderCollection, orderID, refund)
+}
+
+func (s *MongoStore) ListOrders(ctx context.Context, userID string, page, pageSize int) ([]models.Order, int64, error) {
+	return ListOrders(ctx, s.orderCollection, userID, page, pageSize)
+}
+
+func (s *MongoStore) AddProduct(ctx context.Context, product models.Product) error {
+	if _, err := s.prodCollection.InsertOne(ctx, product); err != nil {
+		log.Println(err)
+		return ErrCantInsertProduct
+	}
+	return nil
+}
+
+func (s *MongoStore) FindProduct(ctx context.Context, productID primitive.ObjectID) (models.Product, error) {
+	var product models.Product
+	if err := s.prodCollection.FindOne(ctx, bson.M{"_id": productID}).Decode(&product); err != nil {
+		log.Println(err)
+		return product, ErrCantFindProduct
+	}
+	return product, nil
+}
+
+func (s *MongoStore) UpdateProductPrice(ctx context.Context, key models.ItemKey, price int64, prices []models.Money) (models.Product, error) {
+	filter := bson.M{"_id": key.Product_ID}
+	update := bson.M{"price": price, "prices": prices}
+	if key.Variant != "" {
+		filter["variants.sku"] = key.Variant
+		update = bson.M{"variants.$.price": price, "variants.$.prices": prices}
+	}
+
+	var product models.Product
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.prodCollection.FindOneAndUpdate(ctx, filter, bson.M{"$set": update}, opts).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		if _, findErr := s.FindProduct(ctx, key.Product_ID); findErr != nil {
+			return product, ErrCantFindProduct
+		}
+		return product, ErrVariantNotFound
+	}
+	if err != nil {
+		log.Println(err)
+		return product, ErrCantUpdateProduct
+	}
+	return product, nil
+}
+
+func (s *MongoStore) ListProducts(ctx context.Context) ([]models.Product, error) {
+	return s.findProducts(ctx, bson.D{})
+}
+
+func (s *MongoStore) SearchProducts(ctx context.Context, name string) ([]models.Product, error) {
+	return s.findProducts(ctx, bson.M{"product_name": bson.M{"$regex": name}})
+}
+
+func (s *MongoStore) findProducts(ctx context.Context, filter interface{}) ([]models.Product, error) {
+	cursor, err := s.prodCollection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	products := make([]models.Product, 0)
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	return products, cursor.Err()
+}
+
+func (s *MongoStore) CreateUser(ctx context.Context, user models.User) error {
+	if _, err := s.userCollection.InsertOne(ctx, user); err != nil {
+		log.Println(err)
+		return ErrCantCreateUser
+	}
+	return nil
+}
+
+func (s *MongoStore) FindUser(ctx context.Context, userID string) (models.User, error) {
+	var user models.User
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println(err)
+		return user, ErrUserIdIsNotValid
+	}
+	return s.findUser(ctx, bson.M{"_id": id})
+}
+
+func (s *MongoStore) FindUserByEmail(ctx context.Context, email string) (models.User, error) {
+	return s.findUser(ctx, bson.M{"email": email})
+}
+
+func (s *MongoStore) findUser(ctx context.Context, filter interface{}) (models.User, error) {
+	var user models.User
+	if err := s.userCollection.FindOne(ctx, filter).Decode(&user); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return user, ErrUserNotFound
+		}
+		log.Println(err)
+		return user, err
+	}
+	return user, nil
+}
+
+func (s *MongoStore) EmailExists(ctx context.Context, email string) (bool, error) {
+	count, err := s.userCollection.CountDocuments(ctx, bson.M{"email": email})
+	return count > 0, err
+}
+
+func (s *MongoStore) PhoneExists(ctx context.Context, phone string) (bool, error) {
+	count, err := s.userCollection.CountDocuments(ctx, bson.M{"phone": phone})
+	return count > 0, err
+}
+
+func (s *MongoStore) UpdateTokens(ctx context.Context, userID, token, refreshToken string) error {
+	updateObj := bson.D{
+		{Key: "token", Value: token},
+		{Key: "refresh_token", Value: refreshToken},
+		{Key: "updated_at", Value: time.Now().UTC().Truncate(time.Second)},
+	}
+	if _, err := s.userCollection.UpdateOne(ctx, bson.M{"user_id": userID}, bson.D{{Key: "$set", Value: updateObj}}); err != nil {
+		log.Println(err)
+		return ErrCantUpdateUser
+	}
+	return nil
+}
+
+func (s *MongoStore) AddAddress(ctx context.Context, userID string, address models.Address) error {
+	if err := s.updateUser(ctx, userID, bson.D{{Key: "$push", Value: bson.D{primitive.E{Key: "address", Value: address}}}}); err != nil {
+		return err
+	}
+	return s.clearAddressDefaults(ctx, userID, address)
+}
+
+func (s *MongoStore) UpdateAddress(ctx context.Context, userID string, address models.Address) error {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println(err)
+		return ErrUserIdIsNotValid
+	}
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "address._id", Value: address.Address_ID}}
+	result, err := s.userCollection.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: bson.D{{Key: "address.$", Value: address}}}})
+	if err != nil {
+		log.Println(err)
+		return ErrCantUpdateUser
+	}
+	if result.MatchedCount == 0 {
+		return ErrAddressNotFound
+	}
+	return s.clearAddressDefaults(ctx, userID, address)
+}
+
+func (s *MongoStore) DeleteAddress(ctx context.Context, userID string, addressID primitive.ObjectID) error {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println(err)
+		return ErrUserIdIsNotValid
+	}
+	filter := bson.D{{Key: "_id", Value: id}, {Key: "address._id", Value: addressID}}
+	update := bson.D{{Key: "$pull", Value: bson.D{{Key: "address", Value: bson.D{{Key: "_id", Value: addressID}}}}}}
+	result, err := s.userCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Println(err)
+		return ErrCantUpdateUser
+	}
+	if result.MatchedCount == 0 {
+		return ErrAddressNotFound
+	}
+	return nil
+}
+
+// clearAddressDefaults unsets the default flags address holds on the other
+// addresses of the user.
+func (s *MongoStore) clearAddressDefaults(ctx context.Context, userID string, address models.Address) error {
+	var set bson.D
+	if address.Default_Shipping {
+		set = append(set, bson.E{Key: "address.$[other].default_shipping", Value: false})
+	}
+	if address.Default_Billing {
+		set = append(set, bson.E{Key: "address.$[other].default_billing", Value: false})
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println(err)
+		return ErrUserIdIsNotValid
+	}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{"other._id": bson.M{"$ne": address.Address_ID}}},
+	})
+	if _, err := s.userCollection.UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, bson.D{{Key: "$set", Value: set}}, opts); err != nil {
+		log.Println(err)
+		return ErrCantUpdateUser
+	}
+	return nil
+}
+
+func (s *MongoStore) updateUser(ctx context.Context, userID string, update interface{}) error {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println(err)
+		return ErrUserIdIsNotValid
+	}
+	if _, err := s.userCollection.UpdateOne(ctx, bson.D{primitive.E{Key: "_id", Value: id}}, update); err != nil {
+		log.Println(err)
+		return ErrCantUpdateUser
+	}
+	return nil
+}
+
+func (s *MongoStore) CreateReturn(ctx context.Context, ret models.ReturnRequest) error {
+	return CreateReturn(ctx, s.returnCollection, ret)
+}
+
+func (s *MongoStore) FindReturn(ctx context.Context, returnID primitive.ObjectID) (models.ReturnRequest, error) {
+	return FindReturn(ctx, s.returnCollection, returnID)
+}
+
+func (s *MongoStore) FindShipment(ctx context.Context, shipmentID primitive.ObjectID) (models.Shipment, error) {
+	return FindShipment(ctx, s.shipCollection, shipmentID)
+}
+
+func (s *MongoStore) ListOrderShipments(ctx context.Context, orderID primitive.ObjectID) ([]models.Shipment, error) {
+	return ListOrderShipments(ctx, s.shipCollection, orderID)
+}
+
+func (s *MongoStore) CreateSession(ctx context.Context, session models.Session) error {
+	return CreateSession(ctx, s.sessCollection, session)
+}
+
+func (s *MongoStore) FindSession(ctx context.Context, sessionID primitive.ObjectID) (models.Session, error) {
+	return FindSession(ctx, s.sessCollection, sessionID)
+}
+
+func (s *MongoStore) RotateSession(ctx context.Context, sessionID primitive.ObjectID, refreshID, nextID string, expiresAt time.Time) error {
+	return RotateSession(ctx, s.sessCollection, sessionID, refreshID, nextID, expiresAt)
+}
+
+func (s *MongoStore) RevokeSession(ctx context.Context, sessionID primitive.ObjectID, reason string) error {
+	return RevokeSession(ctx, s.sessCollection, sessionID, reason)
+}
+
+func (s *MongoStore) RevokeUserSessions(ctx context.Context, userID, reason string) ([]models.Session, error) {
+	return RevokeUserSessions(ctx, s.sessCollection, userID, reason)
+}
+
+func (s *MongoStore) AddRevocations(ctx context.Context, revocations []models.Revocation) error {
+	return AddRevocations(ctx, s.revokeCollection, revocations)
+}
+
+func (s *MongoStore) ListRevocations(ctx context.Context, since time.Time) ([]models.Revocation, error) {
+	return ListRevocations(ctx, s.revokeCollection, since)
+}
+
+func (s *MongoStore) AddShipmentEvents(ctx context.Context, shipmentID primitive.ObjectID, events []models.TrackingEvent) (models.Shipment, error) {
+	return AddShipmentEvents(ctx, s.shipCollection, shipmentID, events)
+}
+
+func (s *MongoStore) ListOrderReturns(ctx context.Context, orderID primitive.ObjectID) ([]models.ReturnRequest, error) {
+	return ListReturns(ctx, s.returnCollection, bson.M{"order_id": orderID})
+}
+
+func (s *MongoStore) ListReturns(ctx context.Context, userID string, status models.ReturnStatus) ([]models.ReturnRequest, error) {
+	return ListReturns(ctx, s.returnCollection, returnsFilter(userID, status))
+}
+
+func (s *MongoStore) UpdateReturnStatus(ctx context.Context, returnID primitive.ObjectID, status models.ReturnStatus, note string) (models.ReturnRequest, error) {
+	return UpdateReturnStatus(ctx, s.returnCollection, returnID, status, note)
+}
+
+func (s *MongoStore) SetReturnRefund(ctx context.Context, returnID primitive.ObjectID, refund models.Refund) (models.ReturnRequest, error) {
+	return SetReturnRefund(ctx, s.returnCollection, returnID, refund)
+}
+
+func (s *MongoStore) RecordPaymentEvent(ctx context.Context, event models.PaymentEvent) error {
+	return RecordPaymentEvent(ctx, s.eventCollection, event)
+}
+
+func (s *MongoStore) ForgetPaymentEvent(ctx context.Context, eventID string) error {
+	return ForgetPaymentEvent(ctx, s.eventCollection, eventID)
+}
+
+func (s *MongoStore) ReserveStock(ctx context.Context, reservation models.Reservation) error {
+	return ReserveStock(ctx, s.prodCollection, s.stockCollection, s.holdCollection, reservation)
+}
+
+func (s *MongoStore) ConvertReservation(ctx context.Context, orderID primitive.ObjectID) error {
+	return ConvertReservation(ctx, s.holdCollection, orderID)
+}
+
+func (s *MongoStore) ReleaseReservation(ctx context.Context, orderID primitive.ObjectID) error {
+	return ReleaseReservation(ctx, s.prodCollection, s.stockCollection, s.holdCollection, orderID)
+}
+
+func (s *MongoStore) ListExpiredReservations(ctx context.Context, now time.Time) ([]models.Reservation, error) {
+	return ListExpiredReservations(ctx, s.holdCollection, now)
+}
+
+func (s *MongoStore) ExpireReservations(ctx context.Context, now time.Time) ([]models.Reservation, error) {
+	return ExpireReservations(ctx, s.prodCollection, s.stockCollection, s.holdCollection, now)
+}
+
+func (s *MongoStore) ReleaseStock(ctx context.Context, orderID primitive.ObjectID, lines []models.ProductUser) error {
+	return ReleaseStock(ctx, s.prodCollection, s.stockCollection, orderID, lines)
+}
+
+func (s *MongoStore) AdjustStock(ctx context.Context, key models.ItemKey, delta int64, note string) (models.StockAdjustment, error) {
+	return AdjustStock(ctx, s.prodCollection, s.stockCollection, key, delta, note)
+}
+
+func (s *MongoStore) SetStock(ctx context.Context, key models.ItemKey, stock int64, note string) (models.StockAdjustment, error) {
+	return SetStock(ctx, s.prodCollection, s.stockCollection, key, stock, note)
+}
+
+func (s *MongoStore) ListStockAdjustments(ctx context.Context, productID primitive.ObjectID) ([]models.StockAdjustment, error) {
+	return ListStockAdjustments(ctx, s.stockCollection, productID)
+}
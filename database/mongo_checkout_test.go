@@ -0,0 +1,209 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var errInjected = errors.New("injected failure")
+
+// mongoCheckoutFixture is a checkoutOrder seeded into a MongoDB store.
+type mongoCheckoutFixture struct {
+	store *MongoStore
+	checkoutOrder
+}
+
+func newMongoCheckoutFixture(t *testing.T, transactions bool) mongoCheckoutFixture {
+	t.Helper()
+	store := newTestMongoStore(t, transactions)
+	return mongoCheckoutFixture{store: store, checkoutOrder: seedCheckoutOrder(t, store)}
+}
+
+// failAt makes the store fail at step until the test ends.
+func (f mongoCheckoutFixture) failAt(step string) {
+	f.store.failpoint = func(at string) error {
+		if at == step {
+			return errInjected
+		}
+		return nil
+	}
+}
+
+// expectHold checks the status of the reservation of the order, or that
+// there is none when status is empty.
+func (f mongoCheckoutFixture) expectHold(t *testing.T, status models.ReservationStatus) {
+	t.Helper()
+	var hold models.Reservation
+	err := f.store.holdCollection.FindOne(context.Background(), bson.M{"_id": f.order.Order_ID}).Decode(&hold)
+	switch {
+	case status == "" && errors.Is(err, mongo.ErrNoDocuments):
+	case status == "":
+		t.Errorf("reservation is %s, want none (%v)", hold.Status, err)
+	case err != nil:
+		t.Errorf("reservation: %v, want %s", err, status)
+	case hold.Status != status:
+		t.Errorf("reservation is %s, want %s", hold.Status, status)
+	}
+}
+
+func TestMongoPlaceOrder(t *testing.T) {
+	deployments(t, func(t *testing.T, transactions bool) {
+		f := newMongoCheckoutFixture(t, transactions)
+		f.place(t)
+
+		f.expect(t, 1, 1, 0)
+		f.expectStatus(t, models.OrderPlaced)
+		f.expectHold(t, models.ReservationHeld)
+	})
+}
+
+func TestMongoPlaceOrderFailureLeavesNothing(t *testing.T) {
+	for _, step := range []string{"reserve stock", "redeem coupon", "create order", "clear cart"} {
+		t.Run(step, func(t *testing.T) {
+			deployments(t, func(t *testing.T, transactions bool) {
+				f := newMongoCheckoutFixture(t, transactions)
+				f.failAt(step)
+
+				err := f.store.PlaceOrder(context.Background(), f.order, f.reservation, true)
+				if step == "clear cart" && !transactions {
+					// Without a transaction the order stands and the cart
+					// keeps its items.
+					if err != nil {
+						t.Fatalf("PlaceOrder = %v, want the order placed", err)
+					}
+					f.expect(t, 1, 1, 1)
+					f.expectStatus(t, models.OrderPlaced)
+					f.expectHold(t, models.ReservationHeld)
+					return
+				}
+				if !errors.Is(err, errInjected) {
+					t.Fatalf("PlaceOrder = %v, want %v", err, errInjected)
+				}
+				f.expect(t, 3, 0, 1)
+				f.expectNoOrder(t)
+				f.expectHold(t, "")
+			})
+		})
+	}
+}
+
+func TestMongoPlaceOrderRealFailureLeavesNothing(t *testing.T) {
+	deployments(t, func(t *testing.T, transactions bool) {
+		f := newMongoCheckoutFixture(t, transactions)
+		if err := f.store.CreateOrder(context.Background(), f.order); err != nil {
+			t.Fatal(err)
+		}
+
+		// The order exists already, so storing it fails after the stock
+		// and the coupon were taken.
+		if err := f.store.PlaceOrder(context.Background(), f.order, f.reservation, true); err == nil {
+			t.Fatal("placing a stored order succeeded")
+		}
+		f.expect(t, 3, 0, 1)
+		f.expectHold(t, "")
+	})
+}
+
+func TestMongoWithTransactionRetriesTransientErrors(t *testing.T) {
+	f := newMongoCheckoutFixture(t, true)
+	failed := false
+	f.store.failpoint = func(step string) error {
+		if step == "create order" && !failed {
+			failed = true
+			return mongo.CommandError{Message: "injected", Labels: []string{"TransientTransactionError"}}
+		}
+		return nil
+	}
+
+	f.place(t)
+	if !failed {
+		t.Fatal("the failpoint never fired")
+	}
+	f.expect(t, 1, 1, 0)
+	f.expectStatus(t, models.OrderPlaced)
+	f.expectHold(t, models.ReservationHeld)
+}
+
+func TestMongoMarkOrderPaid(t *testing.T) {
+	deployments(t, func(t *testing.T, transactions bool) {
+		f := newMongoCheckoutFixture(t, transactions)
+		f.place(t)
+
+		payment := f.order.Payment_method
+		payment.Status = models.PaymentCaptured
+		if _, err := f.store.MarkOrderPaid(context.Background(), f.order.Order_ID, payment, "paid"); err != nil {
+			t.Fatal(err)
+		}
+		f.expectStatus(t, models.OrderPaid)
+		f.expect(t, 1, 1, 0)
+		f.expectHold(t, models.ReservationConverted)
+	})
+}
+
+func TestMongoMarkOrderFailed(t *testing.T) {
+	deployments(t, func(t *testing.T, transactions bool) {
+		f := newMongoCheckoutFixture(t, transactions)
+		f.place(t)
+
+		payment := f.order.Payment_method
+		payment.Status = models.PaymentDeclined
+		if _, err := f.store.MarkOrderFailed(context.Background(), f.order.Order_ID, payment, "declined"); err != nil {
+			t.Fatal(err)
+		}
+		f.expectStatus(t, models.OrderFailed)
+		f.expect(t, 3, 0, 0)
+		f.expectHold(t, models.ReservationReleased)
+	})
+}
+
+// Paying and failing orders are not compensated without transactions, so
+// their failures are only checked on a replica set.
+
+func TestMongoMarkOrderPaidFailureLeavesNothing(t *testing.T) {
+	for _, step := range []string{"convert reservation", "mark paid", "update payment"} {
+		t.Run(step, func(t *testing.T) {
+			f := newMongoCheckoutFixture(t, true)
+			f.place(t)
+			f.failAt(step)
+
+			payment := f.order.Payment_method
+			payment.Status = models.PaymentCaptured
+			if _, err := f.store.MarkOrderPaid(context.Background(), f.order.Order_ID, payment, "paid"); !errors.Is(err, errInjected) {
+				t.Fatalf("MarkOrderPaid = %v, want %v", err, errInjected)
+			}
+			order := f.expectStatus(t, models.OrderPlaced)
+			if order.Payment_method.Status != models.PaymentPending {
+				t.Errorf("payment is %s, want it untouched", order.Payment_method.Status)
+			}
+			f.expect(t, 1, 1, 0)
+			f.expectHold(t, models.ReservationHeld)
+		})
+	}
+}
+
+func TestMongoMarkOrderFailedFailureLeavesNothing(t *testing.T) {
+	for _, step := range []string{"mark failed", "update payment", "release reservation"} {
+		t.Run(step, func(t *testing.T) {
+			f := newMongoCheckoutFixture(t, true)
+			f.place(t)
+			f.failAt(step)
+
+			payment := f.order.Payment_method
+			payment.Status = models.PaymentDeclined
+			if _, err := f.store.MarkOrderFailed(context.Background(), f.order.Order_ID, payment, "declined"); !errors.Is(err, errInjected) {
+				t.Fatalf("MarkOrderFailed = %v, want %v", err, errInjected)
+			}
+			order := f.expectStatus(t, models.OrderPlaced)
+			if order.Payment_method.Status != models.PaymentPending {
+				t.Errorf("payment is %s, want it untouched", order.Payment_method.Status)
+			}
+			f.expect(t, 1, 1, 0)
+			f.expectHold(t, models.ReservationHeld)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestMongoStore returns a store on a fresh database of the MongoDB at
+// MONGODB_TEST_URI, dropped when the test ends, and skips the test when the
+// variable is unset. Without transactions the store runs the way it does on
+// a standalone server; with them the test is skipped unless the server is a
+// replica set.
+func newTestMongoStore(t *testing.T, transactions bool) *MongoStore {
+	t.Helper()
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database("ecommerce_test_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := db.Drop(ctx); err != nil {
+			t.Log(err)
+		}
+		if err := client.Disconnect(ctx); err != nil {
+			t.Log(err)
+		}
+	})
+	if transactions && !supportsTransactions(client) {
+		t.Skip("MONGODB_TEST_URI is not a replica set")
+	}
+
+	store := newMongoStore(client, db)
+	store.transactions = transactions
+	store.createIndexes()
+	return store
+}
+
+// testStore is every store interface, which both stores implement.
+type testStore interface {
+	ProductStore
+	UserStore
+	CartStore
+	GuestCartStore
+	OrderStore
+	ReturnStore
+	ShipmentStore
+	PaymentEventStore
+	InventoryStore
+	CheckoutStore
+	IdempotencyStore
+	CouponStore
+	PromotionStore
+	SessionStore
+	RevocationStore
+}
+
+// eachStore runs test on a memory store and, when MONGODB_TEST_URI is set,
+// on MongoDB with and without transactions.
+func eachStore(t *testing.T, test func(t *testing.T, store testStore)) {
+	t.Run("memory", func(t *testing.T) { test(t, NewMemoryStore()) })
+	deployments(t, func(t *testing.T, transactions bool) { test(t, newTestMongoStore(t, transactions)) })
+}
+
+// deployments runs test once on a store with transactions and once on one
+// without, the way MONGODB_ALLOW_STANDALONE runs it.
+func deployments(t *testing.T, test func(t *testing.T, transactions bool)) {
+	t.Run("replica set", func(t *testing.T) { test(t, true) })
+	t.Run("standalone", func(t *testing.T) { test(t, false) })
+}
@@ -0,0 +1,189 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrOrderNotFound          = errors.New("order not found")
+	ErrCantGetOrders          = errors.New("was unable to get the orders")
+	ErrCantUpdateOrder        = errors.New("cannot update the order")
+	ErrInvalidOrderStatus     = errors.New("unknown order status")
+	ErrInvalidOrderTransition = errors.New("illegal order status transition")
+)
+
+// transitionError explains why an order can't move to the requested status.
+func transitionError(from, to models.OrderStatus) error {
+	return fmt.Errorf("%w: an order that is %s cannot become %s", ErrInvalidOrderTransition, from, to)
+}
+
+// NewOrder builds an order of the given lines for a user, priced from the
+// unit prices captured on the lines.
+func NewOrder(userID string, lines []models.ProductUser) models.Order {
+	var subtotal, discount, tax int64
+	for _, line := range lines {
+		subtotal += line.LineTotal()
+		discount += line.Discount
+		tax += line.Tax
+	}
+	totalPrice := subtotal - discount + tax
+
+	now := time.Now().UTC()
+	return models.Order{
+		Order_ID:       primitive.NewObjectID(),
+		User_ID:        userID,
+		Order_Cart:     append(make([]models.ProductUser, 0, len(lines)), lines...),
+		Ordered_At:     now,
+		Subtotal:       subtotal,
+		Price:          &totalPrice,
+		Discount:       &discount,
+		Tax:            tax,
+		Status:         models.OrderPlaced,
+		Status_History: []models.StatusChange{{Status: models.OrderPlaced, Changed_At: now}},
+	}
+}
+
+func CreateOrder(ctx context.Context, orderCollection *mongo.Collection, order models.Order) error {
+	if _, err := orderCollection.InsertOne(ctx, order); err != nil {
+		log.Println(err)
+		return ErrCantBuyCartItem
+	}
+	return nil
+}
+
+// UpdatePayment replaces the payment details of an order.
+func UpdatePayment(ctx context.Context, orderCollection *mongo.Collection, orderID primitive.ObjectID, payment models.Payment) (models.Order, error) {
+	var order models.Order
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := orderCollection.FindOneAndUpdate(ctx, bson.M{"_id": orderID}, bson.M{"$set": bson.M{"payment": payment}}, opts).Decode(&order); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return order, ErrOrderNotFound
+		}
+		log.Println(err)
+		return order, ErrCantUpdateOrder
+	}
+	return order, nil
+}
+
+// FindOrderByID returns any order, whoever placed it.
+func FindOrderByID(ctx context.Context, orderCollection *mongo.Collection, orderID primitive.ObjectID) (models.Order, error) {
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"_id": orderID}).Decode(&order); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return order, ErrOrderNotFound
+		}
+		log.Println(err)
+		return order, ErrCantGetOrders
+	}
+	return order, nil
+}
+
+// UpdateOrderStatus moves an order to status, appending the change to its
+// history. The current status is checked in the update filter, so concurrent
+// updates can't both apply an illegal sequence.
+func UpdateOrderStatus(ctx context.Context, orderCollection *mongo.Collection, orderID primitive.ObjectID, status models.OrderStatus, note string) (models.Order, error) {
+	var order models.Order
+	if !status.Valid() {
+		return order, ErrInvalidOrderStatus
+	}
+
+	filter := bson.M{"_id": orderID, "status": bson.M{"$in": models.StatusesBefore(status)}}
+	update := bson.M{
+		"$set":  bson.M{"status": status},
+		"$push": bson.M{"status_history": models.StatusChange{Status: status, Changed_At: time.Now().UTC(), Note: note}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := orderCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&order)
+	if err == nil {
+		return order, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		log.Println(err)
+		return order, ErrCantUpdateOrder
+	}
+
+	// Nothing matched: either the order doesn't exist or its status doesn't
+	// allow the transition.
+	current, err := FindOrderByID(ctx, orderCollection, orderID)
+	if err != nil {
+		return order, err
+	}
+	return current, transitionError(current.Status, status)
+}
+
+// AddRefund records a refund on an order. Refunds that didn't fail count
+// towards the refunded amount of the order.
+func AddRefund(ctx context.Context, orderCollection *mongo.Collection, orderID primitive.ObjectID, refund models.Refund) (models.Order, error) {
+	var order models.Order
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	update := bson.M{"$push": bson.M{"refunds": refund}, "$inc": bson.M{"refunded_amount": refundedAmount(refund)}}
+	if err := orderCollection.FindOneAndUpdate(ctx, bson.M{"_id": orderID}, update, opts).Decode(&order); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return order, ErrOrderNotFound
+		}
+		log.Println(err)
+		return order, ErrCantUpdateOrder
+	}
+	return order, nil
+}
+
+func refundedAmount(refund models.Refund) int64 {
+	if refund.Status == models.RefundFailed {
+		return 0
+	}
+	return refund.Amount
+}
+
+// FindOrder returns an order of the user. Orders of other users are reported
+// as not found.
+func FindOrder(ctx context.Context, orderCollection *mongo.Collection, userID string, orderID primitive.ObjectID) (models.Order, error) {
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"_id": orderID, "user_id": userID}).Decode(&order); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return order, ErrOrderNotFound
+		}
+		log.Println(err)
+		return order, ErrCantGetOrders
+	}
+	return order, nil
+}
+
+// ListOrders returns one page of the user's orders, newest first, together
+// with the total number of orders the user has.
+func ListOrders(ctx context.Context, orderCollection *mongo.Collection, userID string, page, pageSize int) ([]models.Order, int64, error) {
+	filter := bson.M{"user_id": userID}
+
+	total, err := orderCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		log.Println(err)
+		return nil, 0, ErrCantGetOrders
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "ordered_at", Value: -1}}).
+		SetSkip(int64((page - 1) * pageSize)).
+		SetLimit(int64(pageSize))
+	cursor, err := orderCollection.Find(ctx, filter, opts)
+	if err != nil {
+		log.Println(err)
+		return nil, 0, ErrCantGetOrders
+	}
+	defer cursor.Close(ctx)
+
+	orders := make([]models.Order, 0)
+	if err := cursor.All(ctx, &orders); err != nil {
+		log.Println(err)
+		return nil, 0, ErrCantGetOrders
+	}
+	return orders, total, nil
+}
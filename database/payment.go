@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrDuplicatePaymentEvent = errors.New("this payment event was already processed")
+	ErrCantRecordEvent       = errors.New("cannot record the payment event")
+)
+
+// RecordPaymentEvent stores a processed webhook event. The event ID is the
+// document ID, so recording the same event twice fails.
+func RecordPaymentEvent(ctx context.Context, eventCollection *mongo.Collection, event models.PaymentEvent) error {
+	if _, err := eventCollection.InsertOne(ctx, event); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicatePaymentEvent
+		}
+		log.Println(err)
+		return ErrCantRecordEvent
+	}
+	return nil
+}
+
+func ForgetPaymentEvent(ctx context.Context, eventCollection *mongo.Collection, eventID string) error {
+	if _, err := eventCollection.DeleteOne(ctx, bson.M{"_id": eventID}); err != nil {
+		log.Println(err)
+		return ErrCantRecordEvent
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrPromotionNotFound   = errors.New("promotion not found")
+	ErrCantUpdatePromotion = errors.New("cannot update the promotion")
+	ErrCantGetPromotions   = errors.New("was unable to get the promotions")
+)
+
+func CreatePromotion(ctx context.Context, promotionCollection *mongo.Collection, promotion models.Promotion) error {
+	if _, err := promotionCollection.InsertOne(ctx, promotion); err != nil {
+		log.Println(err)
+		return ErrCantUpdatePromotion
+	}
+	return nil
+}
+
+// UpdatePromotion overwrites a promotion, keeping its creation time.
+func UpdatePromotion(ctx context.Context, promotionCollection *mongo.Collection, promotion models.Promotion) (models.Promotion, error) {
+	existing, err := FindPromotion(ctx, promotionCollection, promotion.Promotion_ID)
+	if err != nil {
+		return existing, err
+	}
+	promotion.Created_At = existing.Created_At
+
+	result, err := promotionCollection.ReplaceOne(ctx, bson.M{"_id": promotion.Promotion_ID}, promotion)
+	if err != nil {
+		log.Println(err)
+		return promotion, ErrCantUpdatePromotion
+	}
+	if result.MatchedCount == 0 {
+		return promotion, ErrPromotionNotFound
+	}
+	return promotion, nil
+}
+
+func DeletePromotion(ctx context.Context, promotionCollection *mongo.Collection, promotionID primitive.ObjectID) error {
+	result, err := promotionCollection.DeleteOne(ctx, bson.M{"_id": promotionID})
+	if err != nil {
+		log.Println(err)
+		return ErrCantUpdatePromotion
+	}
+	if result.DeletedCount == 0 {
+		return ErrPromotionNotFound
+	}
+	return nil
+}
+
+func FindPromotion(ctx context.Context, promotionCollection *mongo.Collection, promotionID primitive.ObjectID) (models.Promotion, error) {
+	var promotion models.Promotion
+	if err := promotionCollection.FindOne(ctx, bson.M{"_id": promotionID}).Decode(&promotion); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return promotion, ErrPromotionNotFound
+		}
+		log.Println(err)
+		return promotion, ErrCantGetPromotions
+	}
+	return promotion, nil
+}
+
+// ListPromotions returns the promotions matching filter by descending
+// priority.
+func ListPromotions(ctx context.Context, promotionCollection *mongo.Collection, filter bson.M) ([]models.Promotion, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "priority", Value: -1}, {Key: "created_at", Value: 1}})
+	cursor, err := promotionCollection.Find(ctx, filter, opts)
+	if err != nil {
+		log.Println(err)
+		return nil, ErrCantGetPromotions
+	}
+	defer cursor.Close(ctx)
+
+	promotions := make([]models.Promotion, 0)
+	if err := cursor.All(ctx, &promotions); err != nil {
+		log.Println(err)
+		return nil, ErrCantGetPromotions
+	}
+	return promotions, nil
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrReturnNotFound          = errors.New("return request not found")
+	ErrCantCreateReturn        = errors.New("cannot create the return request")
+	ErrCantUpdateReturn        = errors.New("cannot update the return request")
+	ErrCantGetReturns          = errors.New("was unable to get the return requests")
+	ErrInvalidReturnTransition = errors.New("illegal return status transition")
+)
+
+func returnTransitionError(from, to models.ReturnStatus) error {
+	return fmt.Errorf("%w: a return that is %s cannot become %s", ErrInvalidReturnTransition, from, to)
+}
+
+func CreateReturn(ctx context.Context, returnCollection *mongo.Collection, ret models.ReturnRequest) error {
+	if _, err := returnCollection.InsertOne(ctx, ret); err != nil {
+		log.Println(err)
+		return ErrCantCreateReturn
+	}
+	return nil
+}
+
+func FindReturn(ctx context.Context, returnCollection *mongo.Collection, returnID primitive.ObjectID) (models.ReturnRequest, error) {
+	var ret models.ReturnRequest
+	if err := returnCollection.FindOne(ctx, bson.M{"_id": returnID}).Decode(&ret); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ret, ErrReturnNotFound
+		}
+		log.Println(err)
+		return ret, ErrCantGetReturns
+	}
+	return ret, nil
+}
+
+// ListReturns returns the return requests matching filter, newest first.
+func ListReturns(ctx context.Context, returnCollection *mongo.Collection, filter bson.M) ([]models.ReturnRequest, error) {
+	cursor, err := returnCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		log.Println(err)
+		return nil, ErrCantGetReturns
+	}
+	defer cursor.Close(ctx)
+
+	returns := make([]models.ReturnRequest, 0)
+	if err := cursor.All(ctx, &returns); err != nil {
+		log.Println(err)
+		return nil, ErrCantGetReturns
+	}
+	return returns, nil
+}
+
+// returnsFilter matches the returns of a user (any user when empty) in a
+// status (any status when empty).
+func returnsFilter(userID string, status models.ReturnStatus) bson.M {
+	filter := bson.M{}
+	if userID != "" {
+		filter["user_id"] = userID
+	}
+	if status != "" {
+		filter["status"] = status
+	}
+	return filter
+}
+
+// UpdateReturnStatus moves a return request to status. Like orders, the
+// current status is checked in the update filter.
+func UpdateReturnStatus(ctx context.Context, returnCollection *mongo.Collection, returnID primitive.ObjectID, status models.ReturnStatus, note string) (models.ReturnRequest, error) {
+	var ret models.ReturnRequest
+
+	before := make([]models.ReturnStatus, 0)
+	for _, s := range []models.ReturnStatus{models.ReturnRequested, models.ReturnApproved, models.ReturnRejected, models.ReturnReceived} {
+		if s.CanTransitionTo(status) {
+			before = append(before, s)
+		}
+	}
+
+	filter := bson.M{"_id": returnID, "status": bson.M{"$in": before}}
+	update := bson.M{
+		"$set":  bson.M{"status": status},
+		"$push": bson.M{"status_history": models.ReturnStatusChange{Status: status, Changed_At: time.Now().UTC(), Note: note}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := returnCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&ret)
+	if err == nil {
+		return ret, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		log.Println(err)
+		return ret, ErrCantUpdateReturn
+	}
+
+	current, err := FindReturn(ctx, returnCollection, returnID)
+	if err != nil {
+		return ret, err
+	}
+	return current, returnTransitionError(current.Status, status)
+}
+
+func SetReturnRefund(ctx context.Context, returnCollection *mongo.Collection, returnID primitive.ObjectID, refund models.Refund) (models.ReturnRequest, error) {
+	var ret models.ReturnRequest
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := returnCollection.FindOneAndUpdate(ctx, bson.M{"_id": returnID}, bson.M{"$set": bson.M{"refund": refund}}, opts).Decode(&ret); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ret, ErrReturnNotFound
+		}
+		log.Println(err)
+		return ret, ErrCantUpdateReturn
+	}
+	return ret, nil
+}
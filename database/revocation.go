@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrCantRevokeToken    = errors.New("cannot revoke the token")
+	ErrCantGetRevocations = errors.New("was unable to get the revoked tokens")
+)
+
+// AddRevocations adds entries to the revocation list. Revoking something
+// again keeps the first entry.
+func AddRevocations(ctx context.Context, revocationCollection *mongo.Collection, revocations []models.Revocation) error {
+	if len(revocations) == 0 {
+		return nil
+	}
+	writes := make([]mongo.WriteModel, len(revocations))
+	for i, revocation := range revocations {
+		writes[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": revocation.Revocation_ID}).
+			SetUpdate(bson.M{"$setOnInsert": revocation}).
+			SetUpsert(true)
+	}
+	if _, err := revocationCollection.BulkWrite(ctx, writes); err != nil {
+		log.Println(err)
+		return ErrCantRevokeToken
+	}
+	return nil
+}
+
+// ListRevocations returns the unexpired entries of the revocation list added
+// since the given time, all of them for the zero time.
+func ListRevocations(ctx context.Context, revocationCollection *mongo.Collection, since time.Time) ([]models.Revocation, error) {
+	filter := bson.M{"revoked_at": bson.M{"$gte": since}, "expires_at": bson.M{"$gt": time.Now().UTC()}}
+	cursor, err := revocationCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "revoked_at", Value: 1}}))
+	if err != nil {
+		log.Println(err)
+		return nil, ErrCantGetRevocations
+	}
+	defer cursor.Close(ctx)
+
+	revocations := make([]models.Revocation, 0)
+	if err := cursor.All(ctx, &revocations); err != nil {
+		log.Println(err)
+		return nil, ErrCantGetRevocations
+	}
+	return revocations, nil
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrSessionRevoked     = errors.New("the session was revoked")
+	ErrRefreshTokenReused = errors.New("the refresh token was already used")
+	ErrCantCreateSession  = errors.New("cannot create the session")
+	ErrCantUpdateSession  = errors.New("cannot update the session")
+	ErrCantGetSession     = errors.New("was unable to get the session")
+)
+
+func CreateSession(ctx context.Context, sessionCollection *mongo.Collection, session models.Session) error {
+	if _, err := sessionCollection.InsertOne(ctx, session); err != nil {
+		log.Println(err)
+		return ErrCantCreateSession
+	}
+	return nil
+}
+
+func FindSession(ctx context.Context, sessionCollection *mongo.Collection, sessionID primitive.ObjectID) (models.Session, error) {
+	var session models.Session
+	if err := sessionCollection.FindOne(ctx, bson.M{"_id": sessionID}).Decode(&session); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return session, ErrSessionNotFound
+		}
+		log.Println(err)
+		return session, ErrCantGetSession
+	}
+	return session, nil
+}
+
+// RotateSession replaces the refresh token refreshID of a session with
+// nextID. Only the current refresh token of a live session can be rotated:
+// it fails with ErrSessionRevoked for revoked sessions and with
+// ErrRefreshTokenReused when refreshID was already replaced.
+func RotateSession(ctx context.Context, sessionCollection *mongo.Collection, sessionID primitive.ObjectID, refreshID, nextID string, expiresAt time.Time) error {
+	filter := bson.M{"_id": sessionID, "refresh_id": refreshID, "revoked_at": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{
+		"refresh_id":   nextID,
+		"refreshed_at": time.Now().UTC(),
+		"expires_at":   expiresAt,
+	}}
+	result, err := sessionCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Println(err)
+		return ErrCantUpdateSession
+	}
+	if result.MatchedCount == 1 {
+		return nil
+	}
+
+	session, err := FindSession(ctx, sessionCollection, sessionID)
+	if err != nil {
+		return err
+	}
+	return rotationError(session, refreshID)
+}
+
+// rotationError says why refreshID couldn't rotate session.
+func rotationError(session models.Session, refreshID string) error {
+	switch {
+	case session.Revoked_At != nil:
+		return ErrSessionRevoked
+	case session.Refresh_ID != refreshID:
+		return ErrRefreshTokenReused
+	default:
+		return ErrCantUpdateSession
+	}
+}
+
+// RevokeSession revokes a session for reason, unless it already was.
+func RevokeSession(ctx context.Context, sessionCollection *mongo.Collection, sessionID primitive.ObjectID, reason string) error {
+	filter := bson.M{"_id": sessionID, "revoked_at": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"revoked_at": time.Now().UTC(), "revoke_reason": reason}}
+	if _, err := sessionCollection.UpdateOne(ctx, filter, update); err != nil {
+		log.Println(err)
+		return ErrCantUpdateSession
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every live session of a user for reason and
+// returns them.
+func RevokeUserSessions(ctx context.Context, sessionCollection *mongo.Collection, userID, reason string) ([]models.Session, error) {
+	filter := bson.M{"user_id": userID, "revoked_at": bson.M{"$exists": false}}
+	cursor, err := sessionCollection.Find(ctx, filter)
+	if err != nil {
+		log.Println(err)
+		return nil, ErrCantGetSession
+	}
+	defer cursor.Close(ctx)
+
+	sessions := make([]models.Session, 0)
+	if err := cursor.All(ctx, &sessions); err != nil {
+		log.Println(err)
+		return nil, ErrCantGetSession
+	}
+	if len(sessions) == 0 {
+		return sessions, nil
+	}
+
+	ids := make([]primitive.ObjectID, len(sessions))
+	for i, session := range sessions {
+		ids[i] = session.Session_ID
+	}
+	now := time.Now().UTC()
+	update := bson.M{"$set": bson.M{"revoked_at": now, "revoke_reason": reason}}
+	if _, err := sessionCollection.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids}, "revoked_at": bson.M{"$exists": false}}, update); err != nil {
+		log.Println(err)
+		return nil, ErrCantUpdateSession
+	}
+	for i := range sessions {
+		sessions[i].Revoked_At = &now
+		sessions[i].Revoke_Reason = reason
+	}
+	return sessions, nil
+}
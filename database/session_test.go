@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newTestSession stores a live session of userID whose refresh token is
+// refreshID.
+func newTestSession(t *testing.T, sessions SessionStore, userID, refreshID string) models.Session {
+	t.Helper()
+	now := time.Now().UTC()
+	session := models.Session{
+		Session_ID: primitive.NewObjectID(),
+		User_ID:    userID,
+		Refresh_ID: refreshID,
+		Created_At: now,
+		Expires_At: now.Add(time.Hour),
+	}
+	if err := sessions.CreateSession(context.Background(), session); err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+func TestRotateSession(t *testing.T) {
+	eachStore(t, func(t *testing.T, store testStore) {
+		ctx := context.Background()
+		expires := time.Now().UTC().Add(2 * time.Hour)
+
+		t.Run("current refresh token", func(t *testing.T) {
+			session := newTestSession(t, store, "user", "first")
+			if err := store.RotateSession(ctx, session.Session_ID, "first", "second", expires); err != nil {
+				t.Fatal(err)
+			}
+			stored, err := store.FindSession(ctx, session.Session_ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Refresh_ID != "second" || stored.Refreshed_At.IsZero() {
+				t.Errorf("got refresh ID %q refreshed at %v, want second and a time", stored.Refresh_ID, stored.Refreshed_At)
+			}
+		})
+
+		t.Run("replaced refresh token", func(t *testing.T) {
+			session := newTestSession(t, store, "user", "first")
+			if err := store.RotateSession(ctx, session.Session_ID, "first", "second", expires); err != nil {
+				t.Fatal(err)
+			}
+			err := store.RotateSession(ctx, session.Session_ID, "first", "third", expires)
+			if !errors.Is(err, ErrRefreshTokenReused) {
+				t.Fatalf("got %v, want ErrRefreshTokenReused", err)
+			}
+			stored, err := store.FindSession(ctx, session.Session_ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Refresh_ID != "second" {
+				t.Errorf("got refresh ID %q, want second", stored.Refresh_ID)
+			}
+		})
+
+		t.Run("revoked session", func(t *testing.T) {
+			session := newTestSession(t, store, "user", "first")
+			if err := store.RevokeSession(ctx, session.Session_ID, "logout"); err != nil {
+				t.Fatal(err)
+			}
+			// Even the current refresh token can't rotate a revoked session.
+			for _, refreshID := range []string{"first", "older"} {
+				err := store.RotateSession(ctx, session.Session_ID, refreshID, "next", expires)
+				if !errors.Is(err, ErrSessionRevoked) {
+					t.Errorf("rotating %s: got %v, want ErrSessionRevoked", refreshID, err)
+				}
+			}
+		})
+
+		t.Run("unknown session", func(t *testing.T) {
+			err := store.RotateSession(ctx, primitive.NewObjectID(), "first", "second", expires)
+			if !errors.Is(err, ErrSessionNotFound) {
+				t.Fatalf("got %v, want ErrSessionNotFound", err)
+			}
+		})
+	})
+}
+
+func TestRotationError(t *testing.T) {
+	revokedAt := time.Now().UTC()
+	tests := []struct {
+		name    string
+		session models.Session
+		want    error
+	}{
+		{"revoked", models.Session{Refresh_ID: "current", Revoked_At: &revokedAt}, ErrSessionRevoked},
+		{"revoked and replaced", models.Session{Refresh_ID: "next", Revoked_At: &revokedAt}, ErrSessionRevoked},
+		{"replaced", models.Session{Refresh_ID: "next"}, ErrRefreshTokenReused},
+		// The update missed a live session on its current token: that's a
+		// write failure, not a replay.
+		{"current", models.Session{Refresh_ID: "current"}, ErrCantUpdateSession},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := rotationError(tt.session, "current"); err != tt.want {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	eachStore(t, func(t *testing.T, store testStore) {
+		ctx := context.Background()
+		session := newTestSession(t, store, "user", "first")
+
+		if err := store.RevokeSession(ctx, session.Session_ID, "logout"); err != nil {
+			t.Fatal(err)
+		}
+		// Revoking again keeps the first reason.
+		if err := store.RevokeSession(ctx, session.Session_ID, "refresh token reused"); err != nil {
+			t.Fatal(err)
+		}
+		stored, err := store.FindSession(ctx, session.Session_ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Revoked_At == nil || stored.Revoke_Reason != "logout" {
+			t.Errorf("got revoked at %v for %q, want revoked for logout", stored.Revoked_At, stored.Revoke_Reason)
+		}
+	})
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	eachStore(t, func(t *testing.T, store testStore) {
+		ctx := context.Background()
+		first := newTestSession(t, store, "user", "a")
+		second := newTestSession(t, store, "user", "b")
+		revoked := newTestSession(t, store, "user", "c")
+		other := newTestSession(t, store, "other", "d")
+		if err := store.RevokeSession(ctx, revoked.Session_ID, "logout"); err != nil {
+			t.Fatal(err)
+		}
+
+		sessions, err := store.RevokeUserSessions(ctx, "user", "logout of all sessions")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[primitive.ObjectID]bool)
+		for _, session := range sessions {
+			got[session.Session_ID] = true
+			if session.Revoked_At == nil || session.Revoke_Reason != "logout of all sessions" {
+				t.Errorf("session %s returned as revoked at %v for %q", session.Session_ID.Hex(), session.Revoked_At, session.Revoke_Reason)
+			}
+		}
+		if len(got) != 2 || !got[first.Session_ID] || !got[second.Session_ID] {
+			t.Errorf("got %d sessions, want the user's 2 live ones", len(sessions))
+		}
+
+		for _, id := range []primitive.ObjectID{first.Session_ID, second.Session_ID} {
+			if err := store.RotateSession(ctx, id, "a", "next", time.Now().UTC().Add(time.Hour)); !errors.Is(err, ErrSessionRevoked) {
+				t.Errorf("rotating session %s: got %v, want ErrSessionRevoked", id.Hex(), err)
+			}
+		}
+		stored, err := store.FindSession(ctx, revoked.Session_ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Revoke_Reason != "logout" {
+			t.Errorf("got reason %q for the session revoked before, want logout", stored.Revoke_Reason)
+		}
+		stored, err = store.FindSession(ctx, other.Session_ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Revoked_At != nil {
+			t.Error("the other user's session was revoked")
+		}
+	})
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrShipmentNotFound     = errors.New("shipment not found")
+	ErrShipmentExceedsOrder = errors.New("the item is not part of the order or was already shipped")
+	ErrShipmentConflict     = errors.New("other shipments of the order were being created, try again")
+	ErrOrderNotShippable    = errors.New("only paid orders and cash on delivery orders can be shipped")
+	ErrCantCreateShipment   = errors.New("cannot create the shipment")
+	ErrCantUpdateShipment   = errors.New("cannot update the shipment")
+	ErrCantGetShipments     = errors.New("was unable to get the shipments")
+
+	// errShipmentRaced means another shipment of the order was stored
+	// between checking a shipment and storing it.
+	errShipmentRaced = errors.New("another shipment of the order was created meanwhile")
+)
+
+// maxShipmentAttempts is how many times a shipment that raced others of its
+// order is checked again before giving up.
+const maxShipmentAttempts = 3
+
+func CreateShipment(ctx context.Context, shipmentCollection *mongo.Collection, shipment models.Shipment) error {
+	if _, err := shipmentCollection.InsertOne(ctx, shipment); err != nil {
+		log.Println(err)
+		return ErrCantCreateShipment
+	}
+	return nil
+}
+
+// CreateShipment checks the items of a shipment against its order and the
+// shipments before it, stores it and marks the order shipped. Shipments of
+// an order take turns through a version on the order, which each one bumps
+// after being inserted as long as the order can still ship: a shipment
+// finding the version bumped by another, or the order cancelled meanwhile,
+// takes its insert back and is checked again.
+func (s *MongoStore) CreateShipment(ctx context.Context, shipment models.Shipment) error {
+	for attempt := 0; attempt < maxShipmentAttempts; attempt++ {
+		err := s.withTransaction(ctx, func(ctx context.Context) error {
+			return s.insertShipment(ctx, shipment)
+		})
+		if err != errShipmentRaced {
+			return err
+		}
+	}
+	return ErrShipmentConflict
+}
+
+func (s *MongoStore) insertShipment(ctx context.Context, shipment models.Shipment) error {
+	// The version is read before the shipments, so every shipment counted
+	// in it is listed.
+	var order struct {
+		models.Order `bson:",inline"`
+		Version      int64 `bson:"shipment_version"`
+	}
+	if err := s.orderCollection.FindOne(ctx, bson.M{"_id": shipment.Order_ID}).Decode(&order); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ErrOrderNotFound
+		}
+		log.Println(err)
+		return ErrCantGetOrders
+	}
+	if !order.Shippable() {
+		return ErrOrderNotShippable
+	}
+	previous, err := ListOrderShipments(ctx, s.shipCollection, shipment.Order_ID)
+	if err != nil {
+		return err
+	}
+	if err := checkShipmentItems(order.Order_Cart, previous, shipment.Items); err != nil {
+		return err
+	}
+	if err := CreateShipment(ctx, s.shipCollection, shipment); err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": shipment.Order_ID, "shipment_version": order.Version, "$or": shippableStatuses}
+	if order.Version == 0 {
+		// Orders that never shipped have no version yet.
+		filter["shipment_version"] = bson.M{"$in": bson.A{0, nil}}
+	}
+	update := bson.M{"$inc": bson.M{"shipment_version": 1}}
+	if order.Status != models.OrderShipped {
+		update["$set"] = bson.M{"status": models.OrderShipped}
+		update["$push"] = bson.M{"status_history": models.StatusChange{
+			Status: models.OrderShipped, Changed_At: time.Now().UTC(), Note: "shipment " + shipment.Tracking_Number,
+		}}
+	}
+	var result *mongo.UpdateResult
+	err = s.fail("bump shipment version")
+	if err == nil {
+		result, err = s.orderCollection.UpdateOne(ctx, filter, update)
+	}
+	if err == nil && result.MatchedCount == 1 {
+		return nil
+	}
+	var labeled mongo.LabeledError
+	if errors.As(err, &labeled) && labeled.HasErrorLabel("TransientTransactionError") {
+		// A concurrent transaction bumped the version; the driver runs the
+		// transaction again.
+		return err
+	}
+	if !s.transactions {
+		if _, deleteErr := s.shipCollection.DeleteOne(ctx, bson.M{"_id": shipment.Shipment_ID}); deleteErr != nil {
+			log.Println("failed to take back shipment", shipment.Shipment_ID.Hex(), deleteErr)
+		}
+	}
+	if err != nil {
+		log.Println(err)
+		return ErrCantCreateShipment
+	}
+	return errShipmentRaced
+}
+
+// shippableStatuses matches the orders models.Order.Shippable allows to ship.
+var shippableStatuses = bson.A{
+	bson.M{"status": bson.M{"$in": bson.A{models.OrderPaid, models.OrderShipped}}},
+	bson.M{"status": models.OrderPlaced, "payment.cod": true},
+}
+
+// checkShipmentItems reports whether items fit in the order lines once the
+// items of the earlier shipments are counted.
+func checkShipmentItems(lines []models.ProductUser, previous []models.Shipment, items []models.ShipmentItem) error {
+	ordered := make(map[models.ItemKey]int, len(lines))
+	for _, line := range lines {
+		ordered[line.Key()] += line.Quantity
+	}
+	shipped := make(map[models.ItemKey]int)
+	for _, shipment := range previous {
+		for _, item := range shipment.Items {
+			shipped[item.Key()] += item.Quantity
+		}
+	}
+	for _, item := range items {
+		key := item.Key()
+		shipped[key] += item.Quantity
+		if item.Quantity <= 0 || shipped[key] > ordered[key] {
+			return ErrShipmentExceedsOrder
+		}
+	}
+	return nil
+}
+
+func FindShipment(ctx context.Context, shipmentCollection *mongo.Collection, shipmentID primitive.ObjectID) (models.Shipment, error) {
+	var shipment models.Shipment
+	if err := shipmentCollection.FindOne(ctx, bson.M{"_id": shipmentID}).Decode(&shipment); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return shipment, ErrShipmentNotFound
+		}
+		log.Println(err)
+		return shipment, ErrCantGetShipments
+	}
+	return shipment, nil
+}
+
+// ListOrderShipments returns the shipments of an order, oldest first.
+func ListOrderShipments(ctx context.Context, shipmentCollection *mongo.Collection, orderID primitive.ObjectID) ([]models.Shipment, error) {
+	cursor, err := shipmentCollection.Find(ctx, bson.M{"order_id": orderID}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		log.Println(err)
+		return nil, ErrCantGetShipments
+	}
+	defer cursor.Close(ctx)
+
+	shipments := make([]models.Shipment, 0)
+	if err := cursor.All(ctx, &shipments); err != nil {
+		log.Println(err)
+		return nil, ErrCantGetShipments
+	}
+	return shipments, nil
+}
+
+// AddShipmentEvents appends the events to the timeline of a shipment and sets
+// its status to the one of the last event.
+func AddShipmentEvents(ctx context.Context, shipmentCollection *mongo.Collection, shipmentID primitive.ObjectID, events []models.TrackingEvent) (models.Shipment, error) {
+	if len(events) == 0 {
+		return FindShipment(ctx, shipmentCollection, shipmentID)
+	}
+
+	var shipment models.Shipment
+	update := bson.M{
+		"$set":  bson.M{"status": events[len(events)-1].Status},
+		"$push": bson.M{"events": bson.M{"$each": events}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := shipmentCollection.FindOneAndUpdate(ctx, bson.M{"_id": shipmentID}, update, opts).Decode(&shipment); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return shipment, ErrShipmentNotFound
+		}
+		log.Println(err)
+		return shipment, ErrCantUpdateShipment
+	}
+	return shipment, nil
+}
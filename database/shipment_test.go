@@ -0,0 +1,233 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// shipmentStores is what the shipment tests use of a store.
+type shipmentStores interface {
+	OrderStore
+	ShipmentStore
+}
+
+// newShipmentOrder stores an order of two units of one product with the
+// given status, paid by card or in cash on delivery.
+func newShipmentOrder(t *testing.T, stores shipmentStores, status models.OrderStatus, cod bool) models.Order {
+	t.Helper()
+	name, price := "laptop", int64(200)
+	product := models.Product{Product_ID: primitive.NewObjectID(), Product_Name: &name, Price: &price}
+	order := models.Order{
+		Order_ID:       primitive.NewObjectID(),
+		User_ID:        primitive.NewObjectID().Hex(),
+		Order_Cart:     []models.ProductUser{models.NewProductUser(product, 2)},
+		Ordered_At:     time.Now().UTC(),
+		Status:         status,
+		Status_History: []models.StatusChange{{Status: status, Changed_At: time.Now().UTC()}},
+		Payment_method: models.Payment{COD: cod},
+	}
+	if err := stores.CreateOrder(context.Background(), order); err != nil {
+		t.Fatal(err)
+	}
+	return order
+}
+
+// shipOne returns a shipment of n units of the product of order.
+func shipOne(order models.Order, n int) models.Shipment {
+	return models.Shipment{
+		Shipment_ID:     primitive.NewObjectID(),
+		Order_ID:        order.Order_ID,
+		Tracking_Number: "LOC" + primitive.NewObjectID().Hex(),
+		Items:           []models.ShipmentItem{{Product_ID: order.Order_Cart[0].Product_ID, Quantity: n}},
+		Created_At:      time.Now().UTC(),
+	}
+}
+
+// expectShipped checks how many shipments order has and that it was marked
+// shipped once.
+func expectShipped(t *testing.T, stores shipmentStores, order models.Order, shipments int) {
+	t.Helper()
+	ctx := context.Background()
+	stored, err := stores.ListOrderShipments(ctx, order.Order_ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != shipments {
+		t.Errorf("order has %d shipments, want %d", len(stored), shipments)
+	}
+	found, err := stores.FindOrderByID(ctx, order.Order_ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	marked := 0
+	for _, change := range found.Status_History {
+		if change.Status == models.OrderShipped {
+			marked++
+		}
+	}
+	if found.Status != models.OrderShipped || marked != 1 {
+		t.Errorf("order is %s and was marked shipped %d times, want shipped once", found.Status, marked)
+	}
+}
+
+func TestCreateShipmentConcurrently(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores testStore) {
+		order := newShipmentOrder(t, stores, models.OrderPaid, false)
+
+		// Every shipment ships one of the two units ordered, so only two of
+		// them fit.
+		const shipments = 8
+		var wg sync.WaitGroup
+		errs := make(chan error, shipments)
+		for i := 0; i < shipments; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- stores.CreateShipment(context.Background(), shipOne(order, 1))
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		created := 0
+		for err := range errs {
+			switch {
+			case err == nil:
+				created++
+			case !errors.Is(err, ErrShipmentExceedsOrder) && !errors.Is(err, ErrShipmentConflict):
+				t.Errorf("CreateShipment = %v, want nil, %v or %v", err, ErrShipmentExceedsOrder, ErrShipmentConflict)
+			}
+		}
+		if created != 2 {
+			t.Errorf("%d shipments were created, want 2", created)
+		}
+		expectShipped(t, stores, order, 2)
+	})
+}
+
+func TestCreateShipmentChecksItems(t *testing.T) {
+	eachStore(t, func(t *testing.T, stores testStore) {
+		order := newShipmentOrder(t, stores, models.OrderPaid, false)
+
+		tests := []struct {
+			name string
+			item models.ShipmentItem
+		}{
+			{"not ordered", models.ShipmentItem{Product_ID: primitive.NewObjectID(), Quantity: 1}},
+			{"too many", models.ShipmentItem{Product_ID: order.Order_Cart[0].Product_ID, Quantity: 3}},
+			{"nothing", models.ShipmentItem{Product_ID: order.Order_Cart[0].Product_ID}},
+		}
+		for _, test := range tests {
+			shipment := shipOne(order, 1)
+			shipment.Items = []models.ShipmentItem{test.item}
+			if err := stores.CreateShipment(context.Background(), shipment); !errors.Is(err, ErrShipmentExceedsOrder) {
+				t.Errorf("%s: CreateShipment = %v, want %v", test.name, err, ErrShipmentExceedsOrder)
+			}
+		}
+		found, err := stores.FindOrderByID(context.Background(), order.Order_ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found.Status != models.OrderPaid {
+			t.Errorf("order is %s, want it still paid", found.Status)
+		}
+	})
+}
+
+func TestCreateShipmentMarksOrderShipped(t *testing.T) {
+	tests := []struct {
+		name   string
+		status models.OrderStatus
+		cod    bool
+	}{
+		{"paid", models.OrderPaid, false},
+		{"cash on delivery", models.OrderPlaced, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			eachStore(t, func(t *testing.T, stores testStore) {
+				order := newShipmentOrder(t, stores, test.status, test.cod)
+				for i := 0; i < 2; i++ {
+					if err := stores.CreateShipment(context.Background(), shipOne(order, 1)); err != nil {
+						t.Fatal(err)
+					}
+				}
+				expectShipped(t, stores, order, 2)
+			})
+		})
+	}
+}
+
+func TestCreateShipmentRefusesOrdersThatCantShip(t *testing.T) {
+	tests := []struct {
+		name   string
+		status models.OrderStatus
+		cod    bool
+	}{
+		{"unpaid", models.OrderPlaced, false},
+		{"failed", models.OrderFailed, false},
+		{"cancelled", models.OrderCancelled, true},
+		{"delivered", models.OrderDelivered, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			eachStore(t, func(t *testing.T, stores testStore) {
+				order := newShipmentOrder(t, stores, test.status, test.cod)
+				if err := stores.CreateShipment(context.Background(), shipOne(order, 1)); !errors.Is(err, ErrOrderNotShippable) {
+					t.Fatalf("CreateShipment = %v, want %v", err, ErrOrderNotShippable)
+				}
+				shipments, err := stores.ListOrderShipments(context.Background(), order.Order_ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(shipments) != 0 {
+					t.Errorf("order has %d shipments, want none", len(shipments))
+				}
+			})
+		})
+	}
+}
+
+func TestMongoCreateShipmentForOrderCancelledMeanwhile(t *testing.T) {
+	deployments(t, func(t *testing.T, transactions bool) {
+		store := newTestMongoStore(t, transactions)
+		ctx := context.Background()
+		order := newShipmentOrder(t, store, models.OrderPaid, false)
+
+		// The order is cancelled after the shipment was checked against it,
+		// before the shipment bumps its version.
+		cancelled := false
+		store.failpoint = func(step string) error {
+			if step == "bump shipment version" && !cancelled {
+				cancelled = true
+				_, err := UpdateOrderStatus(ctx, store.orderCollection, order.Order_ID, models.OrderCancelled, "cancelled")
+				return err
+			}
+			return nil
+		}
+
+		if err := store.CreateShipment(ctx, shipOne(order, 1)); !errors.Is(err, ErrOrderNotShippable) {
+			t.Fatalf("CreateShipment = %v, want %v", err, ErrOrderNotShippable)
+		}
+		shipments, err := store.ListOrderShipments(ctx, order.Order_ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(shipments) != 0 {
+			t.Errorf("order has %d shipments, want none", len(shipments))
+		}
+		found, err := store.FindOrderByID(ctx, order.Order_ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found.Status != models.OrderCancelled {
+			t.Errorf("order is %s, want %s", found.Status, models.OrderCancelled)
+		}
+	})
+}
@@ -0,0 +1,272 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ProductStore persists the product catalogue.
+type ProductStore interface {
+	AddProduct(ctx context.Context, product models.Product) error
+	FindProduct(ctx context.Context, productID primitive.ObjectID) (models.Product, error)
+	ListProducts(ctx context.Context) ([]models.Product, error)
+	SearchProducts(ctx context.Context, name string) ([]models.Product, error)
+	// UpdateProductPrice sets the price of a product, or of one of its
+	// variants when key names one, and returns the product. Carts keep the
+	// price their items were added at until checkout.
+	UpdateProductPrice(ctx context.Context, key models.ItemKey, price int64, prices []models.Money) (models.Product, error)
+}
+
+// UserStore persists user accounts together with their tokens and addresses.
+type UserStore interface {
+	CreateUser(ctx context.Context, user models.User) error
+	FindUser(ctx context.Context, userID string) (models.User, error)
+	FindUserByEmail(ctx context.Context, email string) (models.User, error)
+	EmailExists(ctx context.Context, email string) (bool, error)
+	PhoneExists(ctx context.Context, phone string) (bool, error)
+	UpdateTokens(ctx context.Context, userID, token, refreshToken string) error
+	// AddAddress adds address to the address book of a user, and
+	// UpdateAddress replaces the address with the same Address_ID. An address
+	// that is a default takes the flag over from the other addresses.
+	AddAddress(ctx context.Context, userID string, address models.Address) error
+	UpdateAddress(ctx context.Context, userID string, address models.Address) error
+	// DeleteAddress fails with ErrAddressNotFound when the user has no
+	// address with that ID.
+	DeleteAddress(ctx context.Context, userID string, addressID primitive.ObjectID) error
+}
+
+// CartStore persists the cart of a user. Each product, or variant of a
+// product, appears at most once in a cart, with a quantity and the unit price
+// captured when it was first added. variant is the SKU of the variant and
+// empty for products without variants.
+type CartStore interface {
+	// AddProductToCart adds quantity units of the product, creating the line
+	// if the product isn't in the cart yet. It fails with ErrInsufficientStock
+	// when fewer than quantity units are in stock.
+	AddProductToCart(ctx context.Context, productID primitive.ObjectID, variant, userID string, quantity int) error
+	// SetCartItemQuantity overwrites the quantity of a line; zero removes it.
+	SetCartItemQuantity(ctx context.Context, productID primitive.ObjectID, variant, userID string, quantity int) error
+	// DecrementCartItem removes quantity units, dropping the line when none remain.
+	DecrementCartItem(ctx context.Context, productID primitive.ObjectID, variant, userID string, quantity int) error
+	RemoveCartItem(ctx context.Context, productID primitive.ObjectID, variant, userID string) error
+	GetCart(ctx context.Context, userID string) ([]models.ProductUser, error)
+	// ClearCart empties the cart and removes its coupon.
+	ClearCart(ctx context.Context, userID string) error
+	// SetCartCoupon applies a coupon code to the cart; an empty code
+	// removes it.
+	SetCartCoupon(ctx context.Context, userID, code string) error
+	// GetCartCoupon returns the code applied to the cart, empty if none.
+	GetCartCoupon(ctx context.Context, userID string) (string, error)
+}
+
+// GuestCartStore persists the carts of shoppers who haven't logged in yet,
+// keyed by an opaque cart token.
+type GuestCartStore interface {
+	AddProductToGuestCart(ctx context.Context, productID primitive.ObjectID, variant, cartToken string, quantity int) error
+	SetGuestCartItemQuantity(ctx context.Context, productID primitive.ObjectID, variant, cartToken string, quantity int) error
+	DecrementGuestCartItem(ctx context.Context, productID primitive.ObjectID, variant, cartToken string, quantity int) error
+	RemoveGuestCartItem(ctx context.Context, productID primitive.ObjectID, variant, cartToken string) error
+	// GetGuestCart returns an empty cart for tokens without a cart.
+	GetGuestCart(ctx context.Context, cartToken string) ([]models.ProductUser, error)
+	// MergeGuestCart moves the guest cart into the user's cart and deletes it.
+	MergeGuestCart(ctx context.Context, cartToken, userID string, strategy MergeStrategy) error
+}
+
+// OrderStore persists orders and their lifecycle.
+type OrderStore interface {
+	CreateOrder(ctx context.Context, order models.Order) error
+	UpdatePayment(ctx context.Context, orderID primitive.ObjectID, payment models.Payment) (models.Order, error)
+	// FindOrder returns ErrOrderNotFound for orders of other users.
+	FindOrder(ctx context.Context, userID string, orderID primitive.ObjectID) (models.Order, error)
+	// ListOrders returns a page of the user's orders, newest first, and the
+	// total number of orders.
+	ListOrders(ctx context.Context, userID string, page, pageSize int) ([]models.Order, int64, error)
+	// FindOrderByID returns any order, whoever placed it.
+	FindOrderByID(ctx context.Context, orderID primitive.ObjectID) (models.Order, error)
+	// UpdateOrderStatus moves an order to status, failing with
+	// ErrInvalidOrderTransition when the current status doesn't allow it.
+	UpdateOrderStatus(ctx context.Context, orderID primitive.ObjectID, status models.OrderStatus, note string) (models.Order, error)
+	AddRefund(ctx context.Context, orderID primitive.ObjectID, refund models.Refund) (models.Order, error)
+}
+
+// ReturnStore persists return requests (RMAs) for delivered orders.
+type ReturnStore interface {
+	CreateReturn(ctx context.Context, ret models.ReturnRequest) error
+	FindReturn(ctx context.Context, returnID primitive.ObjectID) (models.ReturnRequest, error)
+	// ListOrderReturns returns every return request of an order.
+	ListOrderReturns(ctx context.Context, orderID primitive.ObjectID) ([]models.ReturnRequest, error)
+	// ListReturns returns the return requests of a user, newest first. An
+	// empty userID or status matches any.
+	ListReturns(ctx context.Context, userID string, status models.ReturnStatus) ([]models.ReturnRequest, error)
+	// UpdateReturnStatus fails with ErrInvalidReturnTransition when the
+	// current status doesn't allow the change.
+	UpdateReturnStatus(ctx context.Context, returnID primitive.ObjectID, status models.ReturnStatus, note string) (models.ReturnRequest, error)
+	SetReturnRefund(ctx context.Context, returnID primitive.ObjectID, refund models.Refund) (models.ReturnRequest, error)
+}
+
+// ShipmentStore persists the shipments sent for orders and their tracking
+// timelines.
+type ShipmentStore interface {
+	// CreateShipment stores a shipment and marks its order shipped. It fails
+	// with ErrOrderNotShippable when the order can't ship, and with
+	// ErrShipmentExceedsOrder when an item isn't part of the order, or more
+	// of it would ship than was ordered counting the earlier shipments. The
+	// checks and the writes are one step, so concurrent shipments can't ship
+	// an item twice or ship an order cancelled meanwhile.
+	CreateShipment(ctx context.Context, shipment models.Shipment) error
+	FindShipment(ctx context.Context, shipmentID primitive.ObjectID) (models.Shipment, error)
+	// ListOrderShipments returns every shipment of an order, oldest first.
+	ListOrderShipments(ctx context.Context, orderID primitive.ObjectID) ([]models.Shipment, error)
+	// AddShipmentEvents appends events to the timeline of a shipment, which
+	// takes the status of the last one.
+	AddShipmentEvents(ctx context.Context, shipmentID primitive.ObjectID, events []models.TrackingEvent) (models.Shipment, error)
+}
+
+// InventoryStore keeps the stock counts of products and variants, with an
+// audit trail of every change to them. Items are identified by product and
+// variant SKU as in carts.
+type InventoryStore interface {
+	// ReserveStock takes the items of a reservation out of stock and keeps
+	// them held for its order, all or nothing. It fails with
+	// ErrInsufficientStock when any item is short.
+	ReserveStock(ctx context.Context, reservation models.Reservation) error
+	// ConvertReservation turns a hold into a sale, failing with
+	// ErrReservationNotHeld when it was released or expired first.
+	ConvertReservation(ctx context.Context, orderID primitive.ObjectID) error
+	// ReleaseReservation puts the reserved stock of an order back, held or
+	// sold. It fails with ErrReservationNotFound for orders placed before
+	// reservations existed.
+	ReleaseReservation(ctx context.Context, orderID primitive.ObjectID) error
+	// ListExpiredReservations returns the holds that expired by now without
+	// releasing them, and ExpireReservations releases them and returns them.
+	ListExpiredReservations(ctx context.Context, now time.Time) ([]models.Reservation, error)
+	ExpireReservations(ctx context.Context, now time.Time) ([]models.Reservation, error)
+	// ReleaseStock puts the lines of an order that won't be fulfilled back.
+	ReleaseStock(ctx context.Context, orderID primitive.ObjectID, lines []models.ProductUser) error
+	// AdjustStock changes a stock count by delta, failing with
+	// ErrNegativeStock rather than going below zero.
+	AdjustStock(ctx context.Context, key models.ItemKey, delta int64, note string) (models.StockAdjustment, error)
+	// SetStock overwrites a stock count and records the difference.
+	SetStock(ctx context.Context, key models.ItemKey, stock int64, note string) (models.StockAdjustment, error)
+	// ListStockAdjustments returns the audit trail of a product, newest first.
+	ListStockAdjustments(ctx context.Context, productID primitive.ObjectID) ([]models.StockAdjustment, error)
+}
+
+// CheckoutStore writes each outcome of a checkout as one unit of work, so a
+// failure at any step leaves nothing of it behind. On MongoDB that takes
+// transactions, and so a replica set; on a standalone server the steps are
+// undone one by one on failure, which a crash can interrupt.
+type CheckoutStore interface {
+	// PlaceOrder reserves the stock of an order, counts a use of its coupon,
+	// stores the order and, when clearCart is set, empties the cart of the
+	// user who placed it. It fails with ErrCouponUsedUp when the coupon
+	// reached a usage limit.
+	PlaceOrder(ctx context.Context, order models.Order, reservation models.Reservation, clearCart bool) error
+	// MarkOrderPaid records the captured payment of a placed order, marks it
+	// paid and turns its stock reservation into a sale. When the hold lapsed
+	// before the payment arrived, the stock is taken again; if an item ran
+	// out in the meantime, the order is marked failed instead, with the
+	// payment recorded, and it fails with ErrInsufficientStock so the payment
+	// can be paid back.
+	MarkOrderPaid(ctx context.Context, orderID primitive.ObjectID, payment models.Payment, note string) (models.Order, error)
+	// MarkOrderFailed records the failed payment of a placed order, marks it
+	// failed, puts its stock back and gives back the use of its coupon.
+	MarkOrderFailed(ctx context.Context, orderID primitive.ObjectID, payment models.Payment, note string) (models.Order, error)
+}
+
+// CouponStore persists coupons and counts the orders placed with them.
+// Coupons are keyed by their code.
+type CouponStore interface {
+	// CreateCoupon fails with ErrCouponExists when the code is taken.
+	CreateCoupon(ctx context.Context, coupon models.Coupon) error
+	// UpdateCoupon overwrites the definition of a coupon, keeping its usage
+	// counts.
+	UpdateCoupon(ctx context.Context, coupon models.Coupon) (models.Coupon, error)
+	FindCoupon(ctx context.Context, code string) (models.Coupon, error)
+	// ListCoupons returns every coupon, newest first.
+	ListCoupons(ctx context.Context) ([]models.Coupon, error)
+	// ReleaseCoupon gives back a use of a coupon, for orders that were
+	// cancelled. Checkout counts the uses as part of CheckoutStore.PlaceOrder.
+	ReleaseCoupon(ctx context.Context, code, userID string) error
+}
+
+// PromotionStore persists the promotions applied to carts without a code.
+type PromotionStore interface {
+	CreatePromotion(ctx context.Context, promotion models.Promotion) error
+	// UpdatePromotion overwrites a promotion, keeping its creation time.
+	UpdatePromotion(ctx context.Context, promotion models.Promotion) (models.Promotion, error)
+	DeletePromotion(ctx context.Context, promotionID primitive.ObjectID) error
+	FindPromotion(ctx context.Context, promotionID primitive.ObjectID) (models.Promotion, error)
+	// ListPromotions returns the promotions by descending priority; with
+	// enabledOnly set, only those that aren't disabled.
+	ListPromotions(ctx context.Context, enabledOnly bool) ([]models.Promotion, error)
+}
+
+// IdempotencyStore remembers the responses to requests sent with an
+// Idempotency-Key until their records expire.
+type IdempotencyStore interface {
+	// BeginIdempotentRequest claims the key of record, or returns the
+	// unexpired record already holding it with claimed false.
+	BeginIdempotentRequest(ctx context.Context, record models.IdempotencyRecord) (existing models.IdempotencyRecord, claimed bool, err error)
+	CompleteIdempotentRequest(ctx context.Context, recordID string, code int, contentType string, body []byte) error
+	// AbandonIdempotentRequest frees a claimed key so the request can be
+	// retried.
+	AbandonIdempotentRequest(ctx context.Context, recordID string) error
+}
+
+// PaymentEventStore remembers the payment webhook events already handled.
+type PaymentEventStore interface {
+	// RecordPaymentEvent fails with ErrDuplicatePaymentEvent when an event
+	// with the same ID was recorded before.
+	RecordPaymentEvent(ctx context.Context, event models.PaymentEvent) error
+	// ForgetPaymentEvent removes a recorded event so that a redelivery of it
+	// is processed again.
+	ForgetPaymentEvent(ctx context.Context, eventID string) error
+}
+
+// SessionStore persists the login sessions of users and the refresh token
+// each one currently accepts.
+type SessionStore interface {
+	CreateSession(ctx context.Context, session models.Session) error
+	FindSession(ctx context.Context, sessionID primitive.ObjectID) (models.Session, error)
+	// RotateSession replaces the current refresh token refreshID of a
+	// session with nextID. It fails with ErrSessionRevoked for revoked
+	// sessions and ErrRefreshTokenReused when refreshID isn't current.
+	RotateSession(ctx context.Context, sessionID primitive.ObjectID, refreshID, nextID string, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, sessionID primitive.ObjectID, reason string) error
+	// RevokeUserSessions revokes every live session of a user and returns
+	// them.
+	RevokeUserSessions(ctx context.Context, userID, reason string) ([]models.Session, error)
+}
+
+// RevocationStore persists the list of tokens revoked before they expire.
+type RevocationStore interface {
+	// AddRevocations adds entries to the list; revoking something again
+	// keeps the first entry.
+	AddRevocations(ctx context.Context, revocations []models.Revocation) error
+	// ListRevocations returns the unexpired entries added since the given
+	// time, all of them for the zero time.
+	ListRevocations(ctx context.Context, since time.Time) ([]models.Revocation, error)
+}
+
+// Stores bundles every store the application depends on.
+type Stores struct {
+	Products      ProductStore
+	Users         UserStore
+	Carts         CartStore
+	GuestCarts    GuestCartStore
+	Orders        OrderStore
+	Returns       ReturnStore
+	Shipments     ShipmentStore
+	PaymentEvents PaymentEventStore
+	Inventory     InventoryStore
+	Checkout      CheckoutStore
+	Idempotency   IdempotencyStore
+	Coupons       CouponStore
+	Promotions    PromotionStore
+	Sessions      SessionStore
+	Revocations   RevocationStore
+}
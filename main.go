@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
@@ -19,20 +20,61 @@ func main() {
 		port = "8000"
 	}
 
-	app := controllers.NewApplication(database.ProductData(database.Client, "Products"), database.UserDatabase(database.Client, "Users"))
+	config, err := controllers.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	stores := database.NewStores(os.Getenv("DB_BACKEND"))
+	app := controllers.NewApplication(stores, config)
+	go app.RunReservationSweeper(context.Background())
+
+	// Revoked tokens must be known before the first request is served.
+	if err := app.Revocations().Sync(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	go app.Revocations().Run(context.Background(), config.RevocationSyncInterval)
 
 	router := gin.New()
 	router.Use(gin.Logger())
 
-	routes.UserRoutes(router)
-	routes.AddAddressRoutes(router)
-	router.Use(middleware.Authentication())
+	// Idempotency keys are scoped to who sent them: the guest cart, the
+	// admin or, past authentication, the user. Sign up, login, refresh and
+	// the payment webhook don't take keys; the webhook has event IDs.
+	idempotency := func(scope middleware.IdempotencyScope) gin.HandlerFunc {
+		return middleware.Idempotency(stores.Idempotency, config.IdempotencyWindow, scope)
+	}
+
+	routes.UserRoutes(router, app)
+	routes.GuestCartRoutes(router, app, idempotency(middleware.TokenScope("guest", controllers.GuestCartToken)))
+	routes.AdminRoutes(router, app, idempotency(middleware.AdminScope))
+	routes.PaymentRoutes(router, app)
+	router.Use(middleware.Authentication(app.Revocations()))
+	router.Use(idempotency(middleware.UserScope))
+
+	routes.AddressRoutes(router, app)
+
+	router.POST("/users/logout", app.Logout())
+	router.POST("/users/logout-all", app.LogoutAll())
 
 	router.POST("/addtocart", app.AddToCart())
 	router.DELETE("/removeitem", app.RemoveItem())
 	router.GET("/cart", app.GetItemFromCart())
+	router.PUT("/cart/quantity", app.SetCartQuantity())
+	router.POST("/cart/increment", app.IncrementCartItem())
+	router.POST("/cart/decrement", app.DecrementCartItem())
+	router.POST("/cart/coupon", app.ApplyCartCoupon())
+	router.DELETE("/cart/coupon", app.RemoveCartCoupon())
+	router.GET("/cart/shipping-options", app.CartShippingOptions())
 	router.POST("/cartcheckout", app.BuyFromCart())
 	router.POST("/instantbuy", app.InstantBuy())
+	router.GET("/orders", app.ListOrders())
+	router.GET("/orders/:id", app.GetOrder())
+	router.POST("/orders/:id/cancel", app.CancelOrder())
+	router.GET("/orders/:id/tracking", app.TrackOrder())
+	router.POST("/orders/:id/returns", app.RequestReturn())
+	router.GET("/returns", app.ListReturns())
+	router.GET("/returns/:id", app.GetReturn())
 
 	log.Println("Server starting on localhost:" + port)
 	log.Fatal(router.Run(":" + port))
@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client's key for a mutating request.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed from a stored
+	// record.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotencyScope names who sent a request, so that their idempotency keys
+// are kept apart from everyone else's. Requests it finds no scope for aren't
+// made idempotent.
+type IdempotencyScope func(c *gin.Context) string
+
+// UserScope scopes keys to the signed-in user. It must run after
+// Authentication.
+func UserScope(c *gin.Context) string {
+	return c.GetString("uid")
+}
+
+// AdminScope scopes keys to the admin token. It must run after
+// AdminAuthentication.
+var AdminScope = TokenScope("admin", func(c *gin.Context) string {
+	return c.Request.Header.Get("admin_token")
+})
+
+// TokenScope scopes keys to the secret token returns for a request, e.g. a
+// guest cart token. Records hold a hash of the token under kind rather than
+// the token itself.
+func TokenScope(kind string, token func(c *gin.Context) string) IdempotencyScope {
+	return func(c *gin.Context) string {
+		secret := token(c)
+		if secret == "" {
+			return ""
+		}
+		sum := sha256.Sum256([]byte(secret))
+		return kind + ":" + hex.EncodeToString(sum[:])
+	}
+}
+
+// Idempotency makes mutating requests sent with an Idempotency-Key header safe
+// to retry. The first response for a key is stored for window and replayed to
+// later requests from the same scope with the same key. Reusing a key for a
+// different request, or while the first one is still running, is a conflict.
+// Server errors aren't stored, so the request can be retried.
+func Idempotency(store database.IdempotencyStore, window time.Duration, scope IdempotencyScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.Request.Header.Get(IdempotencyKeyHeader)
+		if key == "" || c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "idempotency key is too long"})
+			c.Abort()
+			return
+		}
+		owner := scope(c)
+		if owner == "" {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.IndentedJSON(http.StatusBadRequest, gin.H{"error": "cannot read the request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		now := time.Now()
+		record := models.IdempotencyRecord{
+			Record_ID:   database.IdempotencyRecordID(owner, key),
+			Key:         key,
+			User_ID:     owner,
+			Fingerprint: requestFingerprint(c.Request, body),
+			Status:      models.IdempotencyInProgress,
+			Created_At:  now,
+			Expires_At:  now.Add(window),
+		}
+		existing, claimed, err := store.BeginIdempotentRequest(c, record)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if !claimed {
+			replay(c, existing, record.Fingerprint)
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		// The client may have gone away; save the outcome regardless.
+		ctx := context.WithoutCancel(c.Request.Context())
+		code := writer.Status()
+		if code >= http.StatusInternalServerError {
+			if err := store.AbandonIdempotentRequest(ctx, record.Record_ID); err != nil {
+				log.Println(err)
+			}
+			return
+		}
+		if err := store.CompleteIdempotentRequest(ctx, record.Record_ID, code, writer.Header().Get("Content-Type"), writer.body.Bytes()); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// replay answers a request whose key is already taken by existing.
+func replay(c *gin.Context, existing models.IdempotencyRecord, fingerprint string) {
+	switch {
+	case existing.Fingerprint != fingerprint:
+		c.IndentedJSON(http.StatusConflict, gin.H{"error": "idempotency key was already used for a different request"})
+	case existing.Status != models.IdempotencyCompleted:
+		c.IndentedJSON(http.StatusConflict, gin.H{"error": "a request with this idempotency key is still in progress"})
+	default:
+		c.Header(IdempotentReplayedHeader, "true")
+		c.Data(existing.Response_Code, existing.Content_Type, existing.Response_Body)
+	}
+	c.Abort()
+}
+
+// requestFingerprint identifies a request by its method, path, query and
+// body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// recordingWriter keeps a copy of the response body as it is written.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
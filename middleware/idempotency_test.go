@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/gin-gonic/gin"
+)
+
+// idempotencyTest is a router whose POST /orders is made idempotent for the
+// user in the uid header and counts how often it runs.
+type idempotencyTest struct {
+	router *gin.Engine
+	runs   atomic.Int32
+	// handle answers the request, 201 with the run number by default.
+	handle func(c *gin.Context, run int32)
+}
+
+func newIdempotencyTest(scope IdempotencyScope) *idempotencyTest {
+	test := &idempotencyTest{}
+	test.handle = func(c *gin.Context, run int32) {
+		c.JSON(http.StatusCreated, gin.H{"run": run})
+	}
+	test.router = gin.New()
+	test.router.Use(func(c *gin.Context) {
+		c.Set("uid", c.Request.Header.Get("uid"))
+	})
+	test.router.Use(Idempotency(database.NewMemoryStore(), time.Hour, scope))
+	test.router.POST("/orders", func(c *gin.Context) {
+		test.handle(c, test.runs.Add(1))
+	})
+	return test
+}
+
+// send posts body to /orders with the headers given as name and value
+// pairs.
+func (test *idempotencyTest) send(body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	test.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyReplays(t *testing.T) {
+	test := newIdempotencyTest(UserScope)
+
+	first := test.send(`{"id":1}`, "uid", "alice", IdempotencyKeyHeader, "k1")
+	retry := test.send(`{"id":1}`, "uid", "alice", IdempotencyKeyHeader, "k1")
+	if first.Code != http.StatusCreated || retry.Code != http.StatusCreated {
+		t.Fatalf("got %d then %d, want 201 twice", first.Code, retry.Code)
+	}
+	if retry.Body.String() != first.Body.String() || retry.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("got %q replayed %q, want %q replayed", retry.Body.String(), retry.Header().Get(IdempotentReplayedHeader), first.Body.String())
+	}
+	if first.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Error("the first response is marked replayed")
+	}
+	if runs := test.runs.Load(); runs != 1 {
+		t.Errorf("the handler ran %d times, want once", runs)
+	}
+
+	// The key belongs to alice: bob's request with it runs.
+	if rec := test.send(`{"id":1}`, "uid", "bob", IdempotencyKeyHeader, "k1"); rec.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Error("another user got alice's response")
+	}
+	// Requests without a key or a scope aren't recorded.
+	test.send(`{"id":1}`, "uid", "alice")
+	test.send(`{"id":1}`, IdempotencyKeyHeader, "k1")
+	if runs := test.runs.Load(); runs != 4 {
+		t.Errorf("the handler ran %d times, want 4", runs)
+	}
+}
+
+func TestIdempotencyRejectsADifferentRequest(t *testing.T) {
+	test := newIdempotencyTest(UserScope)
+
+	test.send(`{"id":1}`, "uid", "alice", IdempotencyKeyHeader, "k1")
+	if rec := test.send(`{"id":2}`, "uid", "alice", IdempotencyKeyHeader, "k1"); rec.Code != http.StatusConflict {
+		t.Errorf("got %d, want 409", rec.Code)
+	}
+	if runs := test.runs.Load(); runs != 1 {
+		t.Errorf("the handler ran %d times, want once", runs)
+	}
+}
+
+func TestIdempotencyRejectsRequestsInProgress(t *testing.T) {
+	test := newIdempotencyTest(UserScope)
+	started, release := make(chan struct{}), make(chan struct{})
+	test.handle = func(c *gin.Context, run int32) {
+		close(started)
+		<-release
+		c.JSON(http.StatusCreated, gin.H{"run": run})
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- test.send(`{"id":1}`, "uid", "alice", IdempotencyKeyHeader, "k1") }()
+	<-started
+	if rec := test.send(`{"id":1}`, "uid", "alice", IdempotencyKeyHeader, "k1"); rec.Code != http.StatusConflict {
+		t.Errorf("retry while running: got %d, want 409", rec.Code)
+	}
+	close(release)
+	if rec := <-done; rec.Code != http.StatusCreated {
+		t.Fatalf("first request: got %d, want 201", rec.Code)
+	}
+
+	if rec := test.send(`{"id":1}`, "uid", "alice", IdempotencyKeyHeader, "k1"); rec.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("retry after it finished: got %d without a replay", rec.Code)
+	}
+}
+
+func TestIdempotencyForgetsServerErrors(t *testing.T) {
+	test := newIdempotencyTest(UserScope)
+	test.handle = func(c *gin.Context, run int32) {
+		if run == 1 {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "try again"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"run": run})
+	}
+
+	if rec := test.send(`{"id":1}`, "uid", "alice", IdempotencyKeyHeader, "k1"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got %d, want 503", rec.Code)
+	}
+	retry := test.send(`{"id":1}`, "uid", "alice", IdempotencyKeyHeader, "k1")
+	if retry.Code != http.StatusCreated || retry.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("retry: got %d replayed %q, want it run again", retry.Code, retry.Header().Get(IdempotentReplayedHeader))
+	}
+	if runs := test.runs.Load(); runs != 2 {
+		t.Errorf("the handler ran %d times, want twice", runs)
+	}
+}
+
+func TestTokenScope(t *testing.T) {
+	test := newIdempotencyTest(TokenScope("guest", func(c *gin.Context) string {
+		return c.Request.Header.Get("cart_token")
+	}))
+
+	test.send(`{"id":1}`, "cart_token", "cart-a", IdempotencyKeyHeader, "k1")
+	if rec := test.send(`{"id":1}`, "cart_token", "cart-a", IdempotencyKeyHeader, "k1"); rec.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Error("the retry from the same cart wasn't replayed")
+	}
+	if rec := test.send(`{"id":1}`, "cart_token", "cart-b", IdempotencyKeyHeader, "k1"); rec.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Error("another cart got the first cart's response")
+	}
+	// A shopper without a cart yet has nothing to scope the key to.
+	test.send(`{"id":1}`, IdempotencyKeyHeader, "k1")
+	test.send(`{"id":1}`, IdempotencyKeyHeader, "k1")
+	if runs := test.runs.Load(); runs != 4 {
+		t.Errorf("the handler ran %d times, want 4", runs)
+	}
+
+	scope := TokenScope("guest", func(c *gin.Context) string { return "cart-a" })(nil)
+	if !strings.HasPrefix(scope, "guest:") || strings.Contains(scope, "cart-a") {
+		t.Errorf("got the scope %q, want a hash of the token", scope)
+	}
+}
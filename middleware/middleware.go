@@ -1,13 +1,17 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"net/http"
+	"os"
 
 	"github.com/ChandanJnv/ecommerce-cart-golang/tokens"
 	"github.com/gin-gonic/gin"
 )
 
-func Authentication() gin.HandlerFunc {
+// Authentication lets through requests with a valid access token in the
+// token header that isn't on the revocation list.
+func Authentication(revocations *tokens.RevocationList) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		clientToken := c.Request.Header.Get("token")
 		if clientToken == "" {
@@ -21,9 +25,43 @@ func Authentication() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		if claims.Type == tokens.RefreshToken {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh tokens can only be used to get a new token"})
+			c.Abort()
+			return
+		}
+		if revocations.IsRevoked(claims) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "the token was revoked"})
+			c.Abort()
+			return
+		}
 
 		c.Set("email", claims.Email)
 		c.Set("uid", claims.Uid)
+		c.Set("session", claims.Session)
+		c.Set("token_id", claims.Id)
+		c.Set("expires_at", claims.ExpiresAt)
+		c.Next()
+	}
+}
+
+// AdminAuthentication guards the admin routes with the shared secret from the
+// ADMIN_TOKEN environment variable, sent by clients in the admin_token header.
+// Admin routes are closed when ADMIN_TOKEN isn't set.
+func AdminAuthentication() gin.HandlerFunc {
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	return func(c *gin.Context) {
+		if adminToken == "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "admin access is not configured"})
+			c.Abort()
+			return
+		}
+		clientToken := c.Request.Header.Get("admin_token")
+		if subtle.ConstantTimeCompare([]byte(clientToken), []byte(adminToken)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid admin token"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"github.com/ChandanJnv/ecommerce-cart-golang/tokens"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+	tokens.SECRET_KEY = "test secret"
+}
+
+func TestAuthentication(t *testing.T) {
+	revocations := tokens.NewRevocationList(database.NewMemoryStore())
+	router := gin.New()
+	router.Use(Authentication(revocations))
+	router.GET("/cart", func(c *gin.Context) { c.String(http.StatusOK, c.GetString("session")) })
+
+	get := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/cart", nil)
+		req.Header.Set("token", token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	token, refreshToken, err := tokens.TokenGenerator("a@b.com", "alpha", "beta", "user", "session", tokens.NewTokenID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := get(token); rec.Code != http.StatusOK || rec.Body.String() != "session" {
+		t.Fatalf("live token: got %d %q, want 200 with the session", rec.Code, rec.Body.String())
+	}
+	if rec := get(refreshToken); rec.Code != http.StatusUnauthorized {
+		t.Errorf("refresh token: got %d, want 401", rec.Code)
+	}
+
+	// Revoking the session rejects its access token on the next request.
+	now := time.Now().UTC()
+	err = revocations.Revoke(context.Background(), models.Revocation{
+		Revocation_ID: "session",
+		Kind:          models.RevokedSession,
+		User_ID:       "user",
+		Revoked_At:    now,
+		Expires_At:    now.Add(tokens.AccessTokenTTL),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := get(token); rec.Code != http.StatusUnauthorized {
+		t.Errorf("token of the revoked session: got %d, want 401", rec.Code)
+	}
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// Coupon is an admin-managed discount code.
+type Coupon struct {
+	Code         string `json:"code" bson:"_id"`
+	Description  string `json:"description,omitempty" bson:"description,omitempty"`
+	DiscountRule `bson:",inline"`
+	// Max_Uses and Max_Uses_Per_User limit the orders placed with the
+	// coupon in total and per user; zero means no limit.
+	Max_Uses          int  `json:"max_uses,omitempty" bson:"max_uses,omitempty"`
+	Max_Uses_Per_User int  `json:"max_uses_per_user,omitempty" bson:"max_uses_per_user,omitempty"`
+	Disabled          bool `json:"disabled" bson:"disabled"`
+	// Uses counts the orders placed with the coupon, and User_Uses the
+	// orders of each user.
+	Uses       int            `json:"uses" bson:"uses"`
+	User_Uses  map[string]int `json:"user_uses,omitempty" bson:"user_uses,omitempty"`
+	Created_At time.Time      `json:"created_at" bson:"created_at"`
+}
+
+// UsesLeft reports whether userID may still place an order with c.
+func (c Coupon) UsesLeft(userID string) bool {
+	if c.Max_Uses > 0 && c.Uses >= c.Max_Uses {
+		return false
+	}
+	return c.Max_Uses_Per_User <= 0 || c.User_Uses[userID] < c.Max_Uses_Per_User
+}
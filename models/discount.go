@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DiscountType says how a coupon or promotion takes money off a cart.
+type DiscountType string
+
+const (
+	// DiscountPercentage takes Value percent off the eligible lines.
+	DiscountPercentage DiscountType = "percentage"
+	// DiscountFixed takes Value off the eligible lines.
+	DiscountFixed DiscountType = "fixed"
+	// DiscountFreeShipping waives the shipping cost.
+	DiscountFreeShipping DiscountType = "free_shipping"
+	// DiscountBuyXGetY makes Get_Quantity units free for every Buy_Quantity
+	// units bought, the cheapest eligible units first.
+	DiscountBuyXGetY DiscountType = "buy_x_get_y"
+)
+
+// Valid reports whether t is a known discount type.
+func (t DiscountType) Valid() bool {
+	switch t {
+	case DiscountPercentage, DiscountFixed, DiscountFreeShipping, DiscountBuyXGetY:
+		return true
+	}
+	return false
+}
+
+// DiscountRule is what coupons and promotions have in common: how much they
+// take off, when, and from which items.
+type DiscountRule struct {
+	Type DiscountType `json:"type" bson:"type"`
+	// Value is the percentage off for percentage discounts and the amount
+	// off for fixed ones.
+	Value        int64 `json:"value,omitempty" bson:"value,omitempty"`
+	Buy_Quantity int   `json:"buy_quantity,omitempty" bson:"buy_quantity,omitempty"`
+	Get_Quantity int   `json:"get_quantity,omitempty" bson:"get_quantity,omitempty"`
+	// Starts_At and Ends_At bound when the rule applies; either may be left
+	// open.
+	Starts_At *time.Time `json:"starts_at,omitempty" bson:"starts_at,omitempty"`
+	Ends_At   *time.Time `json:"ends_at,omitempty" bson:"ends_at,omitempty"`
+	// Min_Cart_Value is the cart subtotal needed for the rule to apply.
+	Min_Cart_Value int64 `json:"min_cart_value,omitempty" bson:"min_cart_value,omitempty"`
+	// Product_IDs and Categories scope the rule to some products; a rule
+	// without either applies to the whole cart.
+	Product_IDs []primitive.ObjectID `json:"product_ids,omitempty" bson:"product_ids,omitempty"`
+	Categories  []string             `json:"categories,omitempty" bson:"categories,omitempty"`
+}
+
+// AppliesTo reports whether line is in the scope of r.
+func (r DiscountRule) AppliesTo(line ProductUser) bool {
+	if len(r.Product_IDs) == 0 && len(r.Categories) == 0 {
+		return true
+	}
+	for _, id := range r.Product_IDs {
+		if id == line.Product_ID {
+			return true
+		}
+	}
+	for _, category := range r.Categories {
+		if line.Category != "" && category == line.Category {
+			return true
+		}
+	}
+	return false
+}
+
+// DiscountSource says where an applied discount comes from.
+type DiscountSource string
+
+const (
+	SourceCoupon    DiscountSource = "coupon"
+	SourcePromotion DiscountSource = "promotion"
+)
+
+// Discount is a coupon or promotion applied to a cart or order. Code is the
+// coupon code or the ID of the promotion.
+type Discount struct {
+	Source        DiscountSource `json:"source" bson:"source"`
+	Code          string         `json:"code" bson:"code"`
+	Description   string         `json:"description,omitempty" bson:"description,omitempty"`
+	Amount        int64          `json:"amount" bson:"amount"`
+	Free_Shipping bool           `json:"free_shipping,omitempty" bson:"free_shipping,omitempty"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// IdempotencyStatus is the state of a request made with an Idempotency-Key.
+type IdempotencyStatus string
+
+const (
+	IdempotencyInProgress IdempotencyStatus = "in_progress"
+	IdempotencyCompleted  IdempotencyStatus = "completed"
+)
+
+// IdempotencyRecord remembers the response to a request sent with an
+// Idempotency-Key so that retries of the same request get the same answer.
+// Keys are scoped to who sent them, named by User_ID: the user, or a hash of
+// the admin or guest cart token.
+type IdempotencyRecord struct {
+	Record_ID string `json:"-" bson:"_id"`
+	Key       string `json:"key" bson:"key"`
+	User_ID   string `json:"user_id" bson:"user_id"`
+	// Fingerprint is a hash of the method, path, query and body; a retry
+	// with the same key must match it.
+	Fingerprint   string            `json:"fingerprint" bson:"fingerprint"`
+	Status        IdempotencyStatus `json:"status" bson:"status"`
+	Response_Code int               `json:"response_code" bson:"response_code"`
+	Response_Body []byte            `json:"response_body" bson:"response_body"`
+	Content_Type  string            `json:"content_type" bson:"content_type"`
+	Created_At    time.Time         `json:"created_at" bson:"created_at"`
+	Expires_At    time.Time         `json:"expires_at" bson:"expires_at"`
+}
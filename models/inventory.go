@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ItemKey identifies a line of a cart or order: a product and, for products
+// with variants, the SKU of the variant. A cart holds one line per key.
+type ItemKey struct {
+	Product_ID primitive.ObjectID
+	Variant    string
+}
+
+// Variant is a version of a product, such as a size or a colour, with its
+// own stock count and optionally its own price, with Prices fixing it in
+// other currencies like for products.
+type Variant struct {
+	SKU    string  `json:"sku" bson:"sku"`
+	Name   string  `json:"name" bson:"name"`
+	Price  *int64  `json:"price,omitempty" bson:"price,omitempty"`
+	Prices []Money `json:"prices,omitempty" bson:"prices,omitempty"`
+	Stock  int64   `json:"stock" bson:"stock"`
+}
+
+// FindVariant returns the variant of p with the given SKU.
+func (p Product) FindVariant(sku string) (Variant, bool) {
+	for _, variant := range p.Variants {
+		if variant.SKU == sku {
+			return variant, true
+		}
+	}
+	return Variant{}, false
+}
+
+// StockReason says why the stock of a product changed.
+type StockReason string
+
+const (
+	// StockAdjusted changes were made by an admin, e.g. a delivery from a
+	// supplier or a stock count correction.
+	StockAdjusted StockReason = "adjustment"
+	// StockReserved changes took units out of stock to hold them for an
+	// order at checkout.
+	StockReserved StockReason = "reservation"
+	// StockReleased changes put the units of an order that was never
+	// fulfilled back into stock.
+	StockReleased StockReason = "release"
+)
+
+// ReservationStatus is the state of a stock reservation.
+type ReservationStatus string
+
+const (
+	ReservationHeld ReservationStatus = "held"
+	// ReservationConverted holds became sales once the order was paid.
+	ReservationConverted ReservationStatus = "converted"
+	// ReservationReleased holds were given back because the order was
+	// cancelled or its payment failed.
+	ReservationReleased ReservationStatus = "released"
+	// ReservationExpired holds were given back because the payment didn't
+	// arrive in time.
+	ReservationExpired ReservationStatus = "expired"
+)
+
+// Reservation holds the stock of an order from the start of checkout until
+// its payment succeeds. Held units are out of stock for other buyers; the
+// hold becomes a sale on payment and is put back when it expires first.
+type Reservation struct {
+	Order_ID   primitive.ObjectID `json:"order_id" bson:"_id"`
+	User_ID    string             `json:"user_id" bson:"user_id"`
+	Items      []ProductUser      `json:"items" bson:"items"`
+	Status     ReservationStatus  `json:"status" bson:"status"`
+	Expires_At time.Time          `json:"expires_at" bson:"expires_at"`
+	Created_At time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// StockAdjustment is an entry of the inventory audit trail: one change to the
+// stock of a product or variant. Stock is the count after the change.
+type StockAdjustment struct {
+	Adjustment_ID primitive.ObjectID  `json:"adjustment_id" bson:"_id"`
+	Product_ID    primitive.ObjectID  `json:"product_id" bson:"product_id"`
+	Variant       string              `json:"variant,omitempty" bson:"variant,omitempty"`
+	Delta         int64               `json:"delta" bson:"delta"`
+	Stock         int64               `json:"stock" bson:"stock"`
+	Reason        StockReason         `json:"reason" bson:"reason"`
+	Note          string              `json:"note,omitempty" bson:"note,omitempty"`
+	Order_ID      *primitive.ObjectID `json:"order_id,omitempty" bson:"order_id,omitempty"`
+	Created_At    time.Time           `json:"created_at" bson:"created_at"`
+}
@@ -7,56 +7,167 @@ import (
 )
 
 type User struct {
-	ID              primitive.ObjectID `json:"_id" bson:"_id"`
-	First_Name      *string            `json:"first_name" validate:"required,min=2,max=30"`
-	Last_Name       *string            `json:"last_name" validate:"required,min=2,max=30"`
-	Password        *string            `json:"password" validate:"required,min=6"`
-	Email           *string            `json:"email" validate:"email,required"`
-	Phone           *string            `json:"phone" validate:"required"`
-	Token           *string            `json:"token" `
-	Refresh_Token   *string            `json:"refresh_token"`
-	Created_At      time.Time          `json:"created_at"`
-	Updated_At      time.Time          `json:"updated_at"`
-	User_ID         string             `json:"user_id"`
-	UserCart        []ProductUser      `json:"usercart" bson:"usercart"`
-	Address_Details []Address          `json:"address" bson:"address"`
-	Order_Status    []Order            `json:"order" bson:"order"`
+	ID            primitive.ObjectID `json:"_id" bson:"_id"`
+	First_Name    *string            `json:"first_name" validate:"required,min=2,max=30"`
+	Last_Name     *string            `json:"last_name" validate:"required,min=2,max=30"`
+	Password      *string            `json:"password" validate:"required,min=6"`
+	Email         *string            `json:"email" validate:"email,required"`
+	Phone         *string            `json:"phone" validate:"required"`
+	Token         *string            `json:"token" `
+	Refresh_Token *string            `json:"refresh_token"`
+	Created_At    time.Time          `json:"created_at"`
+	Updated_At    time.Time          `json:"updated_at"`
+	User_ID       string             `json:"user_id"`
+	UserCart      []ProductUser      `json:"usercart" bson:"usercart"`
+	// Cart_Coupon is the coupon code applied to the cart, if any.
+	Cart_Coupon     string    `json:"cart_coupon,omitempty" bson:"cart_coupon,omitempty"`
+	Address_Details []Address `json:"address" bson:"address"`
 }
 
+// Product is an item of the catalogue. Price is in the minor units of the
+// base currency; Prices optionally fixes the price in other currencies
+// instead of converting it.
 type Product struct {
 	Product_ID   primitive.ObjectID `bson:"_id"`
 	Product_Name *string            `json:"product_name"`
 	Price        *int64             `json:"price"`
+	Prices       []Money            `json:"prices,omitempty" bson:"prices,omitempty"`
 	Rating       *uint              `json:"rating"`
 	Image        *string            `json:"image"`
+	Category     string             `json:"category,omitempty" bson:"category,omitempty"`
+	// Tax_Category picks the tax rate of the product; empty means the
+	// default category of the tax table.
+	Tax_Category string `json:"tax_category,omitempty" bson:"tax_category,omitempty"`
+	// Weight is the shipping weight of one unit in grams.
+	Weight int64 `json:"weight,omitempty" bson:"weight,omitempty"`
+	// Stock counts the units of products without variants; products with
+	// variants keep a count per variant instead.
+	Stock    int64     `json:"stock" bson:"stock"`
+	Variants []Variant `json:"variants,omitempty" bson:"variants,omitempty"`
 }
 
+// ProductUser is a cart or order line: a product, how many of it, and its
+// unit price at the time it was added. Cart lines are priced in the base
+// currency, with the fixed prices in other currencies in Prices; order lines
+// are priced in the currency of the order.
 type ProductUser struct {
 	Product_ID   primitive.ObjectID `bson:"_id"`
 	Product_Name *string            `json:"product_name" bson:"product_name"`
 	Price        *int64             `json:"price" bson:"price"`
+	Prices       []Money            `json:"prices,omitempty" bson:"prices,omitempty"`
 	Rating       *uint              `json:"rating" bson:"rating"`
 	Image        *string            `json:"image" bson:"image"`
+	Category     string             `json:"category,omitempty" bson:"category,omitempty"`
+	Tax_Category string             `json:"tax_category,omitempty" bson:"tax_category,omitempty"`
+	Weight       int64              `json:"weight,omitempty" bson:"weight,omitempty"`
+	// Variant is the SKU of the chosen variant, empty for products without
+	// variants.
+	Variant    string    `json:"variant,omitempty" bson:"variant"`
+	Quantity   int       `json:"quantity" bson:"quantity"`
+	Updated_At time.Time `json:"updated_at" bson:"updated_at"`
+	// Discount is the part of the line total taken off by discounts, and
+	// Tax the tax charged on the rest; both are set on order lines.
+	Discount int64 `json:"discount,omitempty" bson:"discount,omitempty"`
+	Tax      int64 `json:"tax,omitempty" bson:"tax,omitempty"`
 }
 
+// NewProductUser returns a line of quantity units of product at its current price.
+func NewProductUser(product Product, quantity int) ProductUser {
+	return ProductUser{
+		Product_ID:   product.Product_ID,
+		Product_Name: product.Product_Name,
+		Price:        product.Price,
+		Prices:       product.Prices,
+		Rating:       product.Rating,
+		Image:        product.Image,
+		Category:     product.Category,
+		Tax_Category: product.Tax_Category,
+		Weight:       product.Weight,
+		Quantity:     quantity,
+		Updated_At:   time.Now().UTC(),
+	}
+}
+
+// Key identifies the line within its cart or order.
+func (p ProductUser) Key() ItemKey {
+	return ItemKey{Product_ID: p.Product_ID, Variant: p.Variant}
+}
+
+// LineTotal returns the unit price multiplied by the quantity.
+func (p ProductUser) LineTotal() int64 {
+	if p.Price == nil {
+		return 0
+	}
+	return *p.Price * int64(p.Quantity)
+}
+
+// DiscountedTotal returns the line total less its discount, which is what
+// the line is taxed on.
+func (p ProductUser) DiscountedTotal() int64 {
+	return p.LineTotal() - p.Discount
+}
+
+// PaidTotal returns what the customer pays for the line: its discounted
+// total plus its tax.
+func (p ProductUser) PaidTotal() int64 {
+	return p.DiscountedTotal() + p.Tax
+}
+
+// Address is an entry of the address book of a user. Label is a name the
+// user gives it, such as "home" or "office", and Country its ISO 3166-1
+// alpha-2 code; addresses saved without one are in India. At most one
+// address of a user is the default for shipping and one for billing;
+// checkouts use them when no address is picked.
 type Address struct {
-	Address_ID primitive.ObjectID `bson:"_id"`
-	House      *string            `json:"house_name" bson:"house_name"`
-	Street     *string            `json:"street_name" bson:"street_name"`
-	City       *string            `json:"city_name" bson:"city_name"`
-	Pincode    *string            `json:"pin_code" bson:"pin_code"`
+	Address_ID       primitive.ObjectID `json:"address_id" bson:"_id"`
+	Label            string             `json:"label,omitempty" bson:"label,omitempty"`
+	House            *string            `json:"house_name" bson:"house_name"`
+	Street           *string            `json:"street_name" bson:"street_name"`
+	City             *string            `json:"city_name" bson:"city_name"`
+	Pincode          *string            `json:"pin_code" bson:"pin_code"`
+	State            *string            `json:"state,omitempty" bson:"state,omitempty"`
+	Country          string             `json:"country,omitempty" bson:"country,omitempty"`
+	Default_Shipping bool               `json:"default_shipping" bson:"default_shipping"`
+	Default_Billing  bool               `json:"default_billing" bson:"default_billing"`
 }
 
 type Order struct {
-	Order_ID       primitive.ObjectID `bson:"_id"`
-	Order_Cart     []ProductUser      `json:"order_list" bson:"order_list"`
-	Ordered_At     time.Time          `json:"ordered_at" bson:"ordered_at"`
-	Price          *int64             `json:"total_price" bson:"total_price"`
-	Discount       *int               `json:"discount" bson:"discount"`
-	Payment_method Payment            `json:"payment_method" bson:"payment"`
+	Order_ID   primitive.ObjectID `json:"order_id" bson:"_id"`
+	User_ID    string             `json:"user_id" bson:"user_id"`
+	Order_Cart []ProductUser      `json:"order_list" bson:"order_list"`
+	Ordered_At time.Time          `json:"ordered_at" bson:"ordered_at"`
+	// Subtotal is the sum of the line totals before discounts and taxes,
+	// and Price what the customer pays, shipping included.
+	Subtotal int64  `json:"subtotal" bson:"subtotal"`
+	Price    *int64 `json:"total_price" bson:"total_price"`
+	Discount *int64 `json:"discount" bson:"discount"`
+	// Currency is what the order is priced and paid in, and Exchange_Rate
+	// the units of it per unit of the base currency the prices were
+	// converted at. Orders placed before currencies existed have neither
+	// and are in the base currency.
+	Currency      string     `json:"currency,omitempty" bson:"currency,omitempty"`
+	Exchange_Rate string     `json:"exchange_rate,omitempty" bson:"exchange_rate,omitempty"`
+	Coupon_Code   string     `json:"coupon_code,omitempty" bson:"coupon_code,omitempty"`
+	Discounts     []Discount `json:"discounts,omitempty" bson:"discounts,omitempty"`
+	Tax           int64      `json:"tax" bson:"tax"`
+	Tax_Lines     []TaxLine  `json:"tax_lines,omitempty" bson:"tax_lines,omitempty"`
+	// Shipping_Address is where the order ships to, which decides its tax,
+	// and Shipping how and at what cost. Billing_Address is the address the
+	// order is billed to.
+	Shipping_Address *Address        `json:"shipping_address,omitempty" bson:"shipping_address,omitempty"`
+	Billing_Address  *Address        `json:"billing_address,omitempty" bson:"billing_address,omitempty"`
+	Shipping         *ShippingOption `json:"shipping,omitempty" bson:"shipping,omitempty"`
+	Payment_method   Payment         `json:"payment_method" bson:"payment"`
+	Status           OrderStatus     `json:"status" bson:"status"`
+	Status_History   []StatusChange  `json:"status_history" bson:"status_history"`
+	Refunds          []Refund        `json:"refunds,omitempty" bson:"refunds,omitempty"`
+	Refunded         int64           `json:"refunded_amount" bson:"refunded_amount"`
 }
 
-type Payment struct {
-	Digital bool
-	COD     bool
+// GuestCart is the cart of an anonymous shopper, identified by the random
+// token handed out in the cart_token cookie.
+type GuestCart struct {
+	Cart_ID    string        `json:"cart_token" bson:"_id"`
+	UserCart   []ProductUser `json:"usercart" bson:"usercart"`
+	Updated_At time.Time     `json:"updated_at" bson:"updated_at"`
 }
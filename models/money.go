@@ -0,0 +1,18 @@
+package models
+
+// Money is an amount in the minor units of a currency, such as paise or
+// cents, with the ISO 4217 code of the currency.
+type Money struct {
+	Amount   int64  `json:"amount" bson:"amount"`
+	Currency string `json:"currency" bson:"currency"`
+}
+
+// PriceIn returns the price in currency among prices.
+func PriceIn(prices []Money, currency string) (Money, bool) {
+	for _, price := range prices {
+		if price.Currency == currency {
+			return price, true
+		}
+	}
+	return Money{}, false
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OrderStatus is the lifecycle state of an order.
+type OrderStatus string
+
+const (
+	OrderPlaced OrderStatus = "placed"
+	OrderPaid   OrderStatus = "paid"
+	// OrderFailed orders were never paid because the provider reported the
+	// payment as failed.
+	OrderFailed    OrderStatus = "failed"
+	OrderShipped   OrderStatus = "shipped"
+	OrderDelivered OrderStatus = "delivered"
+	OrderCancelled OrderStatus = "cancelled"
+	OrderReturned  OrderStatus = "returned"
+)
+
+// orderTransitions lists, for every status, the statuses an order may move to
+// next. Failed, cancelled and returned orders are final. Cash on delivery
+// orders ship while still placed.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderPlaced:    {OrderPaid, OrderFailed, OrderCancelled, OrderShipped},
+	OrderPaid:      {OrderShipped, OrderCancelled},
+	OrderFailed:    {},
+	OrderShipped:   {OrderDelivered},
+	OrderDelivered: {OrderReturned},
+	OrderCancelled: {},
+	OrderReturned:  {},
+}
+
+// Valid reports whether s is a known status.
+func (s OrderStatus) Valid() bool {
+	_, ok := orderTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether an order in status s may move to next.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Shippable reports whether items of o may ship: it's paid, already
+// shipping, or paid in cash on delivery.
+func (o Order) Shippable() bool {
+	switch o.Status {
+	case OrderPaid, OrderShipped:
+		return true
+	case OrderPlaced:
+		return o.Payment_method.COD
+	}
+	return false
+}
+
+// StatusesBefore returns the statuses an order may move to next from.
+func StatusesBefore(next OrderStatus) []OrderStatus {
+	before := make([]OrderStatus, 0)
+	for status, allowed := range orderTransitions {
+		for _, s := range allowed {
+			if s == next {
+				before = append(before, status)
+			}
+		}
+	}
+	return before
+}
+
+// StatusChange records when an order entered a status.
+type StatusChange struct {
+	Status     OrderStatus `json:"status" bson:"status"`
+	Changed_At time.Time   `json:"changed_at" bson:"changed_at"`
+	Note       string      `json:"note,omitempty" bson:"note,omitempty"`
+}
+
+// RefundStatus is the state of a refund with the payment integration.
+type RefundStatus string
+
+const (
+	RefundPending   RefundStatus = "pending"
+	RefundSucceeded RefundStatus = "succeeded"
+	RefundFailed    RefundStatus = "failed"
+)
+
+// Refund records money paid back for an order.
+type Refund struct {
+	Refund_ID  primitive.ObjectID `json:"refund_id" bson:"_id"`
+	Amount     int64              `json:"amount" bson:"amount"`
+	Reason     string             `json:"reason" bson:"reason"`
+	Status     RefundStatus       `json:"status" bson:"status"`
+	Reference  string             `json:"reference,omitempty" bson:"reference,omitempty"`
+	Created_At time.Time          `json:"created_at" bson:"created_at"`
+}
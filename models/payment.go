@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PaymentMethod is how the customer chose to pay for an order.
+type PaymentMethod string
+
+const (
+	PaymentCOD    PaymentMethod = "cod"
+	PaymentCard   PaymentMethod = "card"
+	PaymentUPI    PaymentMethod = "upi"
+	PaymentWallet PaymentMethod = "wallet"
+)
+
+// Valid reports whether m is a known payment method.
+func (m PaymentMethod) Valid() bool {
+	switch m {
+	case PaymentCOD, PaymentCard, PaymentUPI, PaymentWallet:
+		return true
+	}
+	return false
+}
+
+// PaymentStatus is the state of the payment of an order with the provider.
+type PaymentStatus string
+
+const (
+	// PaymentPending is waiting on the customer or provider, e.g. cash to be
+	// collected on delivery.
+	PaymentPending    PaymentStatus = "pending"
+	PaymentAuthorized PaymentStatus = "authorized"
+	PaymentCaptured   PaymentStatus = "captured"
+	PaymentVoided     PaymentStatus = "voided"
+	PaymentDeclined   PaymentStatus = "declined"
+)
+
+type Payment struct {
+	Digital   bool
+	COD       bool
+	Method    PaymentMethod `json:"method" bson:"method"`
+	Provider  string        `json:"provider" bson:"provider"`
+	Reference string        `json:"reference,omitempty" bson:"reference,omitempty"`
+	Status    PaymentStatus `json:"status" bson:"status"`
+	Amount    int64         `json:"amount" bson:"amount"`
+	Currency  string        `json:"currency,omitempty" bson:"currency,omitempty"`
+}
+
+// PaymentEventType is the kind of notification a payment provider sends.
+type PaymentEventType string
+
+const (
+	PaymentEventSucceeded PaymentEventType = "payment.succeeded"
+	PaymentEventFailed    PaymentEventType = "payment.failed"
+)
+
+// Valid reports whether t is a known event type.
+func (t PaymentEventType) Valid() bool {
+	return t == PaymentEventSucceeded || t == PaymentEventFailed
+}
+
+// PaymentEvent is a webhook notification from the payment provider about the
+// payment of an order. Event_ID is unique per event so redeliveries of the
+// same event can be recognised.
+type PaymentEvent struct {
+	Event_ID    string             `json:"id" bson:"_id"`
+	Type        PaymentEventType   `json:"type" bson:"type"`
+	Order_ID    primitive.ObjectID `json:"order_id" bson:"order_id"`
+	Reference   string             `json:"reference" bson:"reference"`
+	Amount      int64              `json:"amount" bson:"amount"`
+	Created_At  time.Time          `json:"created_at" bson:"created_at"`
+	Received_At time.Time          `json:"-" bson:"received_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Promotion is a discount applied to every cart that qualifies, without a
+// code. Promotions apply by descending Priority, each to what is left to pay
+// after the ones before it, and coupons apply after all of them.
+type Promotion struct {
+	Promotion_ID primitive.ObjectID `json:"promotion_id" bson:"_id"`
+	Name         string             `json:"name" bson:"name"`
+	DiscountRule `bson:",inline"`
+	Priority     int `json:"priority" bson:"priority"`
+	// Stop_Further keeps the promotions after this one from applying once
+	// it did.
+	Stop_Further bool      `json:"stop_further" bson:"stop_further"`
+	Disabled     bool      `json:"disabled" bson:"disabled"`
+	Created_At   time.Time `json:"created_at" bson:"created_at"`
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ReturnStatus is the state of a return request.
+type ReturnStatus string
+
+const (
+	ReturnRequested ReturnStatus = "requested"
+	ReturnApproved  ReturnStatus = "approved"
+	ReturnRejected  ReturnStatus = "rejected"
+	ReturnReceived  ReturnStatus = "received"
+)
+
+// returnTransitions lists, for every status, the statuses a return may move
+// to next. Rejected and received returns are final.
+var returnTransitions = map[ReturnStatus][]ReturnStatus{
+	ReturnRequested: {ReturnApproved, ReturnRejected},
+	ReturnApproved:  {ReturnReceived},
+	ReturnRejected:  {},
+	ReturnReceived:  {},
+}
+
+// CanTransitionTo reports whether a return in status s may move to next.
+func (s ReturnStatus) CanTransitionTo(next ReturnStatus) bool {
+	for _, allowed := range returnTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// ReturnReason is the reason code a customer gives for a return.
+type ReturnReason string
+
+const (
+	ReturnDamaged        ReturnReason = "damaged"
+	ReturnWrongItem      ReturnReason = "wrong_item"
+	ReturnNotAsDescribed ReturnReason = "not_as_described"
+	ReturnNoLongerNeeded ReturnReason = "no_longer_needed"
+	ReturnOtherReason    ReturnReason = "other"
+)
+
+// Valid reports whether r is a known reason code.
+func (r ReturnReason) Valid() bool {
+	switch r {
+	case ReturnDamaged, ReturnWrongItem, ReturnNotAsDescribed, ReturnNoLongerNeeded, ReturnOtherReason:
+		return true
+	}
+	return false
+}
+
+// ReturnItem is a quantity of one order line sent back by the customer,
+// priced at the unit price the customer paid.
+type ReturnItem struct {
+	Product_ID primitive.ObjectID `json:"product_id" bson:"product_id"`
+	Variant    string             `json:"variant,omitempty" bson:"variant,omitempty"`
+	Quantity   int                `json:"quantity" bson:"quantity"`
+	Unit_Price int64              `json:"unit_price" bson:"unit_price"`
+}
+
+// Key identifies the order line the item is returned from.
+func (i ReturnItem) Key() ItemKey {
+	return ItemKey{Product_ID: i.Product_ID, Variant: i.Variant}
+}
+
+// ReturnStatusChange records when a return entered a status.
+type ReturnStatusChange struct {
+	Status     ReturnStatus `json:"status" bson:"status"`
+	Changed_At time.Time    `json:"changed_at" bson:"changed_at"`
+	Note       string       `json:"note,omitempty" bson:"note,omitempty"`
+}
+
+// ReturnRequest (RMA) asks to send back items of a delivered order.
+type ReturnRequest struct {
+	Return_ID      primitive.ObjectID   `json:"return_id" bson:"_id"`
+	Order_ID       primitive.ObjectID   `json:"order_id" bson:"order_id"`
+	User_ID        string               `json:"user_id" bson:"user_id"`
+	Items          []ReturnItem         `json:"items" bson:"items"`
+	Reason         ReturnReason         `json:"reason" bson:"reason"`
+	Comment        string               `json:"comment,omitempty" bson:"comment,omitempty"`
+	Status         ReturnStatus         `json:"status" bson:"status"`
+	Status_History []ReturnStatusChange `json:"status_history" bson:"status_history"`
+	Refund         *Refund              `json:"refund,omitempty" bson:"refund,omitempty"`
+	Created_At     time.Time            `json:"created_at" bson:"created_at"`
+}
+
+// ItemsTotal returns the value of the returned items.
+func (r ReturnRequest) ItemsTotal() int64 {
+	var total int64
+	for _, item := range r.Items {
+		total += item.Unit_Price * int64(item.Quantity)
+	}
+	return total
+}
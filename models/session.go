@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is a login of a user and the family of refresh tokens handed out
+// for it, each one replacing the one before. Refresh_ID is the ID of the
+// only refresh token of the family that can still be used; presenting an
+// older one means it leaked, and revokes the session.
+type Session struct {
+	Session_ID   primitive.ObjectID `json:"session_id" bson:"_id"`
+	User_ID      string             `json:"user_id" bson:"user_id"`
+	Refresh_ID   string             `json:"-" bson:"refresh_id"`
+	Created_At   time.Time          `json:"created_at" bson:"created_at"`
+	Refreshed_At time.Time          `json:"refreshed_at,omitempty" bson:"refreshed_at,omitempty"`
+	// Expires_At is when the current refresh token expires.
+	Expires_At    time.Time  `json:"expires_at" bson:"expires_at"`
+	Revoked_At    *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+	Revoke_Reason string     `json:"revoke_reason,omitempty" bson:"revoke_reason,omitempty"`
+}
+
+// RevocationKind is what a revocation revokes.
+type RevocationKind string
+
+const (
+	// RevokedToken revokes the token whose ID (jti) is the revocation ID.
+	RevokedToken RevocationKind = "token"
+	// RevokedSession revokes every token of the session whose ID is the
+	// revocation ID.
+	RevokedSession RevocationKind = "session"
+)
+
+// Revocation is an entry of the token revocation list: tokens it covers are
+// rejected before they expire. Expires_At is when the last of them expires
+// and the entry can be forgotten.
+type Revocation struct {
+	Revocation_ID string         `json:"revocation_id" bson:"_id"`
+	Kind          RevocationKind `json:"kind" bson:"kind"`
+	User_ID       string         `json:"user_id" bson:"user_id"`
+	Reason        string         `json:"reason,omitempty" bson:"reason,omitempty"`
+	Revoked_At    time.Time      `json:"revoked_at" bson:"revoked_at"`
+	Expires_At    time.Time      `json:"expires_at" bson:"expires_at"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ShipmentStatus is where a shipment is on its way to the customer.
+type ShipmentStatus string
+
+const (
+	ShipmentLabelCreated   ShipmentStatus = "label_created"
+	ShipmentInTransit      ShipmentStatus = "in_transit"
+	ShipmentOutForDelivery ShipmentStatus = "out_for_delivery"
+	ShipmentDelivered      ShipmentStatus = "delivered"
+	// ShipmentException means the carrier hit a problem, such as nobody
+	// being home; later events say how it was resolved.
+	ShipmentException ShipmentStatus = "exception"
+)
+
+// Valid reports whether s is a known status.
+func (s ShipmentStatus) Valid() bool {
+	switch s {
+	case ShipmentLabelCreated, ShipmentInTransit, ShipmentOutForDelivery, ShipmentDelivered, ShipmentException:
+		return true
+	}
+	return false
+}
+
+// ShipmentItem is a quantity of one order line sent in a shipment.
+type ShipmentItem struct {
+	Product_ID primitive.ObjectID `json:"product_id" bson:"product_id"`
+	Variant    string             `json:"variant,omitempty" bson:"variant,omitempty"`
+	Quantity   int                `json:"quantity" bson:"quantity"`
+}
+
+// Key identifies the order line the item ships from.
+func (i ShipmentItem) Key() ItemKey {
+	return ItemKey{Product_ID: i.Product_ID, Variant: i.Variant}
+}
+
+// TrackingEvent is a step of a shipment reported by its carrier or recorded
+// by an admin.
+type TrackingEvent struct {
+	Status      ShipmentStatus `json:"status" bson:"status"`
+	Description string         `json:"description,omitempty" bson:"description,omitempty"`
+	Location    string         `json:"location,omitempty" bson:"location,omitempty"`
+	Occurred_At time.Time      `json:"occurred_at" bson:"occurred_at"`
+}
+
+// Shipment is a parcel sent for an order, holding all or part of its items.
+// Events are the tracking timeline, oldest first, and Status the status of
+// the latest one.
+type Shipment struct {
+	Shipment_ID     primitive.ObjectID `json:"shipment_id" bson:"_id"`
+	Order_ID        primitive.ObjectID `json:"order_id" bson:"order_id"`
+	User_ID         string             `json:"user_id" bson:"user_id"`
+	Carrier         string             `json:"carrier" bson:"carrier"`
+	Tracking_Number string             `json:"tracking_number" bson:"tracking_number"`
+	Items           []ShipmentItem     `json:"items" bson:"items"`
+	Status          ShipmentStatus     `json:"status" bson:"status"`
+	Events          []TrackingEvent    `json:"events" bson:"events"`
+	Created_At      time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// HasEvent reports whether the shipment already has event.
+func (s Shipment) HasEvent(event TrackingEvent) bool {
+	for _, e := range s.Events {
+		if e.Status == event.Status && e.Occurred_At.Equal(event.Occurred_At) && e.Description == event.Description {
+			return true
+		}
+	}
+	return false
+}
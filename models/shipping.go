@@ -0,0 +1,30 @@
+package models
+
+// ShippingMethod is how an order gets to the customer.
+type ShippingMethod string
+
+const (
+	ShippingStandard ShippingMethod = "standard"
+	ShippingExpress  ShippingMethod = "express"
+	ShippingPickup   ShippingMethod = "pickup"
+)
+
+func (m ShippingMethod) Valid() bool {
+	switch m {
+	case ShippingStandard, ShippingExpress, ShippingPickup:
+		return true
+	}
+	return false
+}
+
+// ShippingOption is a shipping method available for a cart and what it
+// costs to ship the cart with it. Free is set when a discount waived the
+// price, which is then zero.
+type ShippingOption struct {
+	Method        ShippingMethod `json:"method" bson:"method"`
+	Name          string         `json:"name" bson:"name"`
+	Delivery_Days string         `json:"delivery_days,omitempty" bson:"delivery_days,omitempty"`
+	Zone          string         `json:"zone,omitempty" bson:"zone,omitempty"`
+	Price         int64          `json:"price" bson:"price"`
+	Free          bool           `json:"free,omitempty" bson:"free,omitempty"`
+}
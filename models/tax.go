@@ -0,0 +1,14 @@
+package models
+
+// TaxLine is tax charged at one rate. Calculators return one per cart line;
+// orders keep them summed per zone, category and rate.
+type TaxLine struct {
+	// Zone is the tax zone the items ship to, and Category the tax
+	// category of the products.
+	Zone     string `json:"zone" bson:"zone"`
+	Category string `json:"category" bson:"category"`
+	// Rate is in basis points: 1800 is 18%.
+	Rate    int64 `json:"rate" bson:"rate"`
+	Taxable int64 `json:"taxable" bson:"taxable"`
+	Amount  int64 `json:"amount" bson:"amount"`
+}
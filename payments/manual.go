@@ -0,0 +1,42 @@
+package payments
+
+import (
+	"context"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Manual is used when no payment gateway is configured. It only accepts cash
+// on delivery and records refunds as pending so they can be paid out by hand.
+type Manual struct{}
+
+func (Manual) Name() string { return "manual" }
+
+func (Manual) Authorize(ctx context.Context, order models.Order, method models.PaymentMethod) (models.Payment, error) {
+	if method != models.PaymentCOD {
+		return models.Payment{}, ErrMethodNotSupported
+	}
+	return newPayment("manual", order, method, models.PaymentPending), nil
+}
+
+func (Manual) Capture(ctx context.Context, payment models.Payment) (models.Payment, error) {
+	payment.Status = models.PaymentCaptured
+	return payment, nil
+}
+
+func (Manual) Void(ctx context.Context, payment models.Payment) (models.Payment, error) {
+	payment.Status = models.PaymentVoided
+	return payment, nil
+}
+
+func (Manual) Refund(ctx context.Context, order models.Order, amount int64, reason string) (models.Refund, error) {
+	return models.Refund{
+		Refund_ID:  primitive.NewObjectID(),
+		Amount:     amount,
+		Reason:     reason,
+		Status:     models.RefundPending,
+		Created_At: time.Now().UTC(),
+	}, nil
+}
@@ -0,0 +1,133 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Behavior tells the mock gateway how to answer.
+type Behavior string
+
+const (
+	BehaviorSucceed Behavior = "succeed"
+	BehaviorDecline Behavior = "decline"
+	BehaviorTimeout Behavior = "timeout"
+	// BehaviorAsync leaves payments pending; the outcome arrives later
+	// through the payment webhook.
+	BehaviorAsync Behavior = "async"
+)
+
+// ParseBehavior parses a behavior name, defaulting to BehaviorSucceed.
+func ParseBehavior(name string) (Behavior, error) {
+	switch behavior := Behavior(name); behavior {
+	case "":
+		return BehaviorSucceed, nil
+	case BehaviorSucceed, BehaviorDecline, BehaviorTimeout, BehaviorAsync:
+		return behavior, nil
+	default:
+		return "", fmt.Errorf("unknown mock payment behavior %q", name)
+	}
+}
+
+// Mock is a local payment gateway for development and testing. Every call
+// succeeds, declines or times out depending on its behavior, which can be
+// changed at runtime with SetBehavior. Cash on delivery always succeeds.
+// With BehaviorAsync, payments stay pending until a signed event from
+// cmd/paymentevent reports their outcome.
+type Mock struct {
+	mu       sync.RWMutex
+	behavior Behavior
+	// Timeout is how long a call waits before failing with ErrTimeout when
+	// the behavior is BehaviorTimeout.
+	Timeout time.Duration
+}
+
+func NewMock(behavior Behavior) *Mock {
+	return &Mock{behavior: behavior, Timeout: 3 * time.Second}
+}
+
+func (m *Mock) SetBehavior(behavior Behavior) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.behavior = behavior
+}
+
+func (m *Mock) Name() string { return "mock" }
+
+func (m *Mock) Authorize(ctx context.Context, order models.Order, method models.PaymentMethod) (models.Payment, error) {
+	if !method.Valid() {
+		return models.Payment{}, ErrMethodNotSupported
+	}
+	if method == models.PaymentCOD {
+		return newPayment(m.Name(), order, method, models.PaymentPending), nil
+	}
+	if err := m.answer(ctx); err != nil {
+		return models.Payment{}, err
+	}
+
+	status := models.PaymentAuthorized
+	if m.currentBehavior() == BehaviorAsync {
+		status = models.PaymentPending
+	}
+	payment := newPayment(m.Name(), order, method, status)
+	payment.Reference = "mock_" + primitive.NewObjectID().Hex()
+	return payment, nil
+}
+
+func (m *Mock) Capture(ctx context.Context, payment models.Payment) (models.Payment, error) {
+	if err := m.answer(ctx); err != nil {
+		return payment, err
+	}
+	payment.Status = models.PaymentCaptured
+	return payment, nil
+}
+
+func (m *Mock) Void(ctx context.Context, payment models.Payment) (models.Payment, error) {
+	if err := m.answer(ctx); err != nil {
+		return payment, err
+	}
+	payment.Status = models.PaymentVoided
+	return payment, nil
+}
+
+func (m *Mock) Refund(ctx context.Context, order models.Order, amount int64, reason string) (models.Refund, error) {
+	if err := m.answer(ctx); err != nil {
+		return models.Refund{}, err
+	}
+	return models.Refund{
+		Refund_ID:  primitive.NewObjectID(),
+		Amount:     amount,
+		Reason:     reason,
+		Status:     models.RefundSucceeded,
+		Reference:  "mock_refund_" + primitive.NewObjectID().Hex(),
+		Created_At: time.Now().UTC(),
+	}, nil
+}
+
+// answer returns the error matching the current behavior, waiting out the
+// timeout first when the behavior is BehaviorTimeout.
+func (m *Mock) answer(ctx context.Context) error {
+	switch m.currentBehavior() {
+	case BehaviorDecline:
+		return ErrDeclined
+	case BehaviorTimeout:
+		select {
+		case <-ctx.Done():
+		case <-time.After(m.Timeout):
+		}
+		return ErrTimeout
+	default:
+		return nil
+	}
+}
+
+func (m *Mock) currentBehavior() Behavior {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.behavior
+}
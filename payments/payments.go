@@ -0,0 +1,66 @@
+// Package payments talks to the payment gateways that take money for orders.
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+)
+
+var (
+	ErrDeclined           = errors.New("the payment was declined")
+	ErrTimeout            = errors.New("the payment provider did not respond in time")
+	ErrMethodNotSupported = errors.New("this payment method is not supported")
+)
+
+// Refunder pays money for an order back to the customer.
+type Refunder interface {
+	Refund(ctx context.Context, order models.Order, amount int64, reason string) (models.Refund, error)
+}
+
+// Provider is a payment gateway. Checkout authorizes the order total and
+// captures it once the order is stored; an authorization whose order could
+// not be stored is voided.
+type Provider interface {
+	Refunder
+	Name() string
+	Authorize(ctx context.Context, order models.Order, method models.PaymentMethod) (models.Payment, error)
+	Capture(ctx context.Context, payment models.Payment) (models.Payment, error)
+	Void(ctx context.Context, payment models.Payment) (models.Payment, error)
+}
+
+// NewProvider returns the provider with the given name: "mock" for the local
+// mock gateway, or the manual cash-on-delivery provider when empty.
+func NewProvider(name string, mockBehavior string) (Provider, error) {
+	switch name {
+	case "", "manual":
+		return Manual{}, nil
+	case "mock":
+		behavior, err := ParseBehavior(mockBehavior)
+		if err != nil {
+			return nil, err
+		}
+		return NewMock(behavior), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", name)
+	}
+}
+
+// newPayment fills in the fields every provider sets on a new payment.
+func newPayment(provider string, order models.Order, method models.PaymentMethod, status models.PaymentStatus) models.Payment {
+	var amount int64
+	if order.Price != nil {
+		amount = *order.Price
+	}
+	return models.Payment{
+		Digital:  method != models.PaymentCOD,
+		COD:      method == models.PaymentCOD,
+		Method:   method,
+		Provider: provider,
+		Status:   status,
+		Amount:   amount,
+		Currency: order.Currency,
+	}
+}
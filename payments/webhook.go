@@ -0,0 +1,79 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+)
+
+// SignatureHeader carries the signature of a webhook request, in the form
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">".
+const SignatureHeader = "X-Payment-Signature"
+
+// SignatureTolerance is how old a signed event may be before it is refused,
+// which stops captured requests from being replayed later.
+const SignatureTolerance = 5 * time.Minute
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Sign returns the signature header value of a webhook body sent at t.
+func Sign(secret []byte, body []byte, t time.Time) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac(secret, timestamp, body))
+}
+
+// VerifySignature checks the signature header of a webhook body received at
+// now.
+func VerifySignature(secret []byte, body []byte, header string, now time.Time) error {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || signature == "" {
+		return ErrInvalidSignature
+	}
+	sent := time.Unix(seconds, 0)
+	if now.Sub(sent) > SignatureTolerance || sent.Sub(now) > SignatureTolerance {
+		return fmt.Errorf("%w: the event is too old", ErrInvalidSignature)
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, mac(secret, timestamp, body)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func mac(secret []byte, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// SignEvent encodes a webhook event and signs it the way the provider does,
+// for sending events to a local server.
+func SignEvent(secret []byte, event models.PaymentEvent) (body []byte, signature string, err error) {
+	body, err = json.Marshal(event)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, Sign(secret, body, time.Now()), nil
+}
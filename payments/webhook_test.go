@@ -0,0 +1,43 @@
+package payments
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("whsec")
+	body := []byte(`{"id":"evt_1"}`)
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		body   []byte
+		header string
+		ok     bool
+	}{
+		{"valid", body, Sign(secret, body, now), true},
+		{"a little old", body, Sign(secret, body, now.Add(-4*time.Minute)), true},
+		{"clock a little ahead", body, Sign(secret, body, now.Add(4*time.Minute)), true},
+		{"stale", body, Sign(secret, body, now.Add(-SignatureTolerance-time.Second)), false},
+		{"too far ahead", body, Sign(secret, body, now.Add(SignatureTolerance+time.Second)), false},
+		{"changed body", []byte(`{"id":"evt_2"}`), Sign(secret, body, now), false},
+		{"other secret", body, Sign([]byte("other"), body, now), false},
+		{"missing", body, "", false},
+		{"no signature", body, "t=" + strconv.FormatInt(now.Unix(), 10), false},
+		{"not hex", body, "t=" + strconv.FormatInt(now.Unix(), 10) + ",v1=zz", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySignature(secret, tt.body, tt.header, now)
+			if tt.ok && err != nil {
+				t.Errorf("got %v, want the signature accepted", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("got %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
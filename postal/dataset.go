@@ -0,0 +1,92 @@
+package postal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Place is the city and state a postal code of a country belongs to.
+// Aliases are other names the city goes by, such as former ones; addresses
+// using them get the city name.
+type Place struct {
+	Country  string   `json:"country,omitempty"`
+	Pin_Code string   `json:"pin_code"`
+	City     string   `json:"city"`
+	State    string   `json:"state,omitempty"`
+	Aliases  []string `json:"aliases,omitempty"`
+}
+
+// Dataset is a list of postal codes and their places, loaded from a JSON
+// file. Places without a country are in DefaultCountry.
+type Dataset struct {
+	Places []Place `json:"places"`
+}
+
+// LoadDataset reads a postal dataset from a JSON file.
+func LoadDataset(path string) (*Dataset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading postal data: %w", err)
+	}
+	var dataset Dataset
+	if err := json.Unmarshal(data, &dataset); err != nil {
+		return nil, fmt.Errorf("parsing postal data %s: %w", path, err)
+	}
+	if err := dataset.normalize(); err != nil {
+		return nil, fmt.Errorf("postal data %s: %w", path, err)
+	}
+	return &dataset, nil
+}
+
+// normalize puts the places in the form addresses are normalized to,
+// rejecting places that wouldn't pass validation themselves.
+func (d *Dataset) normalize() error {
+	seen := make(map[string]bool, len(d.Places))
+	for i := range d.Places {
+		place := &d.Places[i]
+		place.Country = strings.ToUpper(strings.TrimSpace(place.Country))
+		if place.Country == "" {
+			place.Country = DefaultCountry
+		}
+		f, ok := formats[place.Country]
+		if !ok {
+			return fmt.Errorf("country %q isn't supported", place.Country)
+		}
+		place.Pin_Code = f.normalize(place.Pin_Code)
+		if !f.pattern.MatchString(place.Pin_Code) {
+			return fmt.Errorf("%q isn't a valid %s postal code", place.Pin_Code, place.Country)
+		}
+		if place.City = titleCase(place.City); place.City == "" {
+			return errors.New("every place needs a city")
+		}
+		place.State = stateCase(place.Country, place.State)
+
+		key := placeKey(place.Country, place.Pin_Code)
+		if seen[key] {
+			return fmt.Errorf("%s %s is listed twice", place.Country, place.Pin_Code)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// has reports whether city is the name, or one of the aliases, of the city
+// of the place.
+func (p Place) has(city string) bool {
+	if strings.EqualFold(p.City, city) {
+		return true
+	}
+	for _, alias := range p.Aliases {
+		if strings.EqualFold(strings.Join(strings.Fields(alias), " "), city) {
+			return true
+		}
+	}
+	return false
+}
+
+func placeKey(country, code string) string {
+	return country + " " + code
+}
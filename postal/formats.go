@@ -0,0 +1,68 @@
+package postal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DefaultCountry is the country of addresses that don't name one.
+const DefaultCountry = "IN"
+
+// format is how postal codes of a country are written. normalize puts a code
+// in the canonical form pattern is checked against. states are the names of
+// its states and regions by the codes addresses may use instead.
+type format struct {
+	pattern       *regexp.Regexp
+	example       string
+	normalize     func(code string) string
+	stateRequired bool
+	states        map[string]string
+}
+
+// formats are the countries addresses can be in, by ISO 3166-1 alpha-2 code.
+var formats = map[string]format{
+	"IN": {
+		pattern: regexp.MustCompile(`^[1-9][0-9]{5}$`), example: "560001", normalize: withoutSpaces,
+		states: indianStates,
+	},
+	"US": {
+		pattern: regexp.MustCompile(`^[0-9]{5}(-[0-9]{4})?$`), example: "10001", normalize: withoutSpaces, stateRequired: true,
+		states: usStates,
+	},
+	"CA": {
+		pattern: regexp.MustCompile(`^[A-Z][0-9][A-Z] [0-9][A-Z][0-9]$`), example: "K1A 0B1", normalize: splitInward, stateRequired: true,
+		states: canadianProvinces,
+	},
+	"GB": {pattern: regexp.MustCompile(`^[A-Z]{1,2}[0-9][A-Z0-9]? [0-9][A-Z]{2}$`), example: "SW1A 1AA", normalize: splitInward},
+	"AU": {
+		pattern: regexp.MustCompile(`^[0-9]{4}$`), example: "2000", normalize: withoutSpaces, stateRequired: true,
+		states: australianStates,
+	},
+	"DE": {pattern: regexp.MustCompile(`^[0-9]{5}$`), example: "10115", normalize: withoutSpaces},
+	"FR": {pattern: regexp.MustCompile(`^[0-9]{5}$`), example: "75001", normalize: withoutSpaces},
+	"JP": {pattern: regexp.MustCompile(`^[0-9]{3}-[0-9]{4}$`), example: "100-0001", normalize: withHyphen},
+	"SG": {pattern: regexp.MustCompile(`^[0-9]{6}$`), example: "018956", normalize: withoutSpaces},
+}
+
+func withoutSpaces(code string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(code), ""))
+}
+
+// splitInward writes the last three characters of a code, its inward part,
+// apart from the rest, as in "SW1A 1AA".
+func splitInward(code string) string {
+	code = withoutSpaces(code)
+	if len(code) <= 3 {
+		return code
+	}
+	return code[:len(code)-3] + " " + code[len(code)-3:]
+}
+
+// withHyphen writes seven digit codes as "100-0001".
+func withHyphen(code string) string {
+	code = strings.ReplaceAll(withoutSpaces(code), "-", "")
+	if len(code) != 7 {
+		return code
+	}
+	return code[:3] + "-" + code[3:]
+}
@@ -0,0 +1,212 @@
+// Package postal validates and normalizes the addresses customers save:
+// required fields, the postal code format of their country and, for postal
+// codes in a local dataset, that the city and state match the code.
+package postal
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+)
+
+var ErrInvalidAddress = errors.New("address is not valid")
+
+// FieldErrors says what is wrong with an address, by the JSON name of the
+// field at fault.
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for i, field := range fields {
+		fields[i] = field + ": " + e[field]
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidAddress, strings.Join(fields, ", "))
+}
+
+func (e FieldErrors) Unwrap() error {
+	return ErrInvalidAddress
+}
+
+// Validator validates addresses against the postal code formats of their
+// country and, when it has one, a postal dataset.
+type Validator struct {
+	places map[string]Place
+}
+
+// NewValidator returns a validator checking postal codes against the dataset
+// at path, or only against their country's format when path is empty.
+func NewValidator(path string) (*Validator, error) {
+	if path == "" {
+		return &Validator{}, nil
+	}
+	dataset, err := LoadDataset(path)
+	if err != nil {
+		return nil, err
+	}
+	validator := &Validator{places: make(map[string]Place, len(dataset.Places))}
+	for _, place := range dataset.Places {
+		validator.places[placeKey(place.Country, place.Pin_Code)] = place
+	}
+	return validator, nil
+}
+
+// Normalize returns address with its whitespace collapsed, names cased and
+// postal code written the way its country does, or FieldErrors when it isn't
+// valid. Addresses without a country are in DefaultCountry. For postal codes
+// in the dataset, the city must be the one of the code and is spelled as the
+// dataset does, and a missing state is filled in.
+func (v *Validator) Normalize(address models.Address) (models.Address, error) {
+	invalid := FieldErrors{}
+
+	address.Label = collapse(address.Label)
+	address.Country = strings.ToUpper(collapse(address.Country))
+	if address.Country == "" {
+		address.Country = DefaultCountry
+	}
+	address.House = normalizeField(address.House, capitalize)
+	address.Street = normalizeField(address.Street, capitalize)
+	address.City = normalizeField(address.City, titleCase)
+	address.State = normalizeField(address.State, func(s string) string { return stateCase(address.Country, s) })
+
+	required := []struct {
+		field string
+		value *string
+	}{
+		{"house_name", address.House},
+		{"street_name", address.Street},
+		{"city_name", address.City},
+		{"pin_code", address.Pincode},
+	}
+	for _, r := range required {
+		if r.value == nil || strings.TrimSpace(*r.value) == "" {
+			invalid[r.field] = "is required"
+		}
+	}
+
+	f, ok := formats[address.Country]
+	if !ok {
+		invalid["country"] = fmt.Sprintf("%q isn't a supported country", address.Country)
+		return address, invalid
+	}
+	if f.stateRequired && address.State == nil {
+		invalid["state"] = "is required in " + address.Country
+	}
+	if _, missing := invalid["pin_code"]; !missing {
+		code := f.normalize(*address.Pincode)
+		address.Pincode = &code
+		if !f.pattern.MatchString(code) {
+			invalid["pin_code"] = fmt.Sprintf("isn't a valid %s postal code, such as %s", address.Country, f.example)
+		} else if place, known := v.place(address.Country, code); known {
+			checkPlace(&address, place, invalid)
+		}
+	}
+
+	if len(invalid) > 0 {
+		return address, invalid
+	}
+	return address, nil
+}
+
+// place returns the place of a postal code from the dataset.
+func (v *Validator) place(country, code string) (Place, bool) {
+	if v == nil {
+		return Place{}, false
+	}
+	place, ok := v.places[placeKey(country, code)]
+	return place, ok
+}
+
+// checkPlace checks the city and state of address against the place of its
+// postal code. States match by name or by code, and are spelled as the place
+// spells them.
+func checkPlace(address *models.Address, place Place, invalid FieldErrors) {
+	if address.City != nil {
+		if place.has(*address.City) {
+			city := place.City
+			address.City = &city
+		} else {
+			invalid["city_name"] = fmt.Sprintf("pin code %s is in %s", place.Pin_Code, place.City)
+		}
+	}
+	if place.State == "" {
+		return
+	}
+	if address.State == nil || sameState(address.Country, *address.State, place.State) {
+		state := place.State
+		address.State = &state
+		delete(invalid, "state")
+	} else {
+		invalid["state"] = fmt.Sprintf("pin code %s is in %s", place.Pin_Code, place.State)
+	}
+}
+
+// normalizeField collapses the whitespace of value and cases it with
+// casing, leaving nil for values that are missing or blank.
+func normalizeField(value *string, casing func(string) string) *string {
+	if value == nil {
+		return nil
+	}
+	normalized := casing(collapse(*value))
+	if normalized == "" {
+		return nil
+	}
+	return &normalized
+}
+
+// collapse trims s and turns every run of whitespace in it into one space.
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// capitalize upper-cases the first letter of every word of s, leaving the
+// rest alone so "MG road" becomes "MG Road".
+func capitalize(s string) string {
+	words := strings.Fields(s)
+	for i, word := range words {
+		words[i] = upperFirst(word)
+	}
+	return strings.Join(words, " ")
+}
+
+// lowerWords stay lower case inside names, as in "Jammu and Kashmir".
+var lowerWords = map[string]bool{"and": true, "of": true, "the": true}
+
+// titleCase cases s as a place name: "NEW  delhi" becomes "New Delhi".
+func titleCase(s string) string {
+	words := strings.Fields(strings.ToLower(s))
+	for i, word := range words {
+		if i > 0 && lowerWords[word] {
+			continue
+		}
+		words[i] = upperFirst(word)
+	}
+	return strings.Join(words, " ")
+}
+
+// stateCase cases s as a state name of country, or upper-cases it when it is
+// one of the state codes of the country, such as "KA" or "NY". Short names
+// like "Goa" stay names.
+func stateCase(country, s string) string {
+	code := strings.ToUpper(s)
+	if _, ok := formats[country].states[code]; ok {
+		return code
+	}
+	return titleCase(s)
+}
+
+func upperFirst(word string) string {
+	r, size := utf8.DecodeRuneInString(word)
+	if r == utf8.RuneError {
+		return word
+	}
+	return string(unicode.ToUpper(r)) + word[size:]
+}
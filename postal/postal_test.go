@@ -0,0 +1,195 @@
+package postal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+)
+
+const testDataset = `{
+    "places": [
+        {"pin_code": "560001", "city": "bengaluru", "state": "Karnataka", "aliases": ["Bangalore"]},
+        {"pin_code": "403001", "city": "Panaji", "state": "goa", "aliases": ["Panjim"]},
+        {"country": "US", "pin_code": "10001", "city": "New York", "state": "NY"},
+        {"country": "CA", "pin_code": "k1a0b1", "city": "Ottawa", "state": "Ontario"},
+        {"country": "AU", "pin_code": "2000", "city": "Sydney", "state": "NSW"},
+        {"country": "GB", "pin_code": "SW1A 1AA", "city": "London"}
+    ]
+}`
+
+func newTestValidator(t *testing.T) *Validator {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "postal.json")
+	if err := os.WriteFile(path, []byte(testDataset), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewValidator(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// address returns an address in country, leaving state out when it is
+// empty.
+func address(country, city, pin, state string) models.Address {
+	house, street := "  flat 4 ", "MG   road"
+	a := models.Address{House: &house, Street: &street, City: &city, Pincode: &pin, Country: country}
+	if state != "" {
+		a.State = &state
+	}
+	return a
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name      string
+		address   models.Address
+		city      string
+		pin       string
+		state     string
+		invalid   []string
+		noDataset bool
+	}{
+		// States by name, by code and left out.
+		{name: "state name", address: address("", "Bengaluru", "560001", "karnataka"), city: "Bengaluru", pin: "560001", state: "Karnataka"},
+		{name: "state code", address: address("IN", "Bengaluru", "560001", "ka"), city: "Bengaluru", pin: "560001", state: "Karnataka"},
+		{name: "state left out", address: address("IN", "Bengaluru", "560001", ""), city: "Bengaluru", pin: "560001", state: "Karnataka"},
+		{name: "wrong state name", address: address("IN", "Bengaluru", "560001", "Kerala"), invalid: []string{"state"}},
+		{name: "wrong state code", address: address("IN", "Bengaluru", "560001", "KL"), invalid: []string{"state"}},
+		{name: "short name is not a code", address: address("IN", "Panaji", "403001", "GOA"), city: "Panaji", pin: "403001", state: "Goa"},
+		{name: "dataset uses the code", address: address("US", "new york", "10001", "New York"), city: "New York", pin: "10001", state: "NY"},
+		{name: "code of another country", address: address("US", "New York", "10001", "KA"), invalid: []string{"state"}},
+		{name: "australian code", address: address("AU", "Sydney", "2000", "new south wales"), city: "Sydney", pin: "2000", state: "NSW"},
+		{name: "canadian code", address: address("CA", "Ottawa", "K1A 0B1", "on"), city: "Ottawa", pin: "K1A 0B1", state: "Ontario"},
+
+		// Cities and their aliases.
+		{name: "alias", address: address("IN", "bangalore", "560001", ""), city: "Bengaluru", pin: "560001", state: "Karnataka"},
+		{name: "wrong city", address: address("IN", "Mysuru", "560001", ""), invalid: []string{"city_name"}},
+		{name: "place without a state", address: address("GB", "london", "sw1a1aa", ""), city: "London", pin: "SW1A 1AA"},
+
+		// Postal code formats of each country.
+		{name: "IN spaces", address: address("in", "Bengaluru", " 560 001 ", ""), city: "Bengaluru", pin: "560001", state: "Karnataka"},
+		{name: "IN leading zero", address: address("IN", "Pune", "011001", ""), invalid: []string{"pin_code"}},
+		{name: "US zip+4", address: address("US", "Albany", "12207-1234", "ny"), city: "Albany", pin: "12207-1234", state: "NY"},
+		{name: "US bad zip", address: address("US", "Albany", "1220", "NY"), invalid: []string{"pin_code"}},
+		{name: "CA inward part", address: address("CA", "Toronto", "m5v3l9", "ON"), city: "Toronto", pin: "M5V 3L9", state: "ON"},
+		{name: "GB inward part", address: address("GB", "Leeds", "ls11ba", ""), city: "Leeds", pin: "LS1 1BA"},
+		{name: "JP hyphen", address: address("JP", "Tokyo", "1000001", ""), city: "Tokyo", pin: "100-0001"},
+		{name: "AU four digits", address: address("AU", "Perth", "60000", "WA"), invalid: []string{"pin_code"}},
+		{name: "SG six digits", address: address("SG", "Singapore", "018956", ""), city: "Singapore", pin: "018956"},
+		{name: "DE five digits", address: address("DE", "Berlin", "1011", ""), invalid: []string{"pin_code"}},
+		{name: "FR five digits", address: address("FR", "paris", "75001", ""), city: "Paris", pin: "75001"},
+
+		// Required fields and unsupported countries.
+		{name: "state required", address: address("US", "Albany", "12207", ""), invalid: []string{"state"}},
+		{name: "state filled in by the dataset", address: address("US", "New York", "10001", ""), city: "New York", pin: "10001", state: "NY"},
+		{name: "blank city", address: address("IN", "  ", "560001", ""), invalid: []string{"city_name"}},
+		{name: "missing pin", address: address("IN", "Bengaluru", "", ""), invalid: []string{"pin_code"}},
+		{name: "unsupported country", address: address("XX", "Nowhere", "1", ""), invalid: []string{"country"}},
+
+		// Without a dataset only the format is checked.
+		{name: "no dataset", address: address("IN", "mysuru", "560001", "kerala"), city: "Mysuru", pin: "560001", state: "Kerala", noDataset: true},
+		{name: "no dataset code", address: address("IN", "mysuru", "560001", "kl"), city: "Mysuru", pin: "560001", state: "KL", noDataset: true},
+	}
+
+	withDataset := newTestValidator(t)
+	withoutDataset, err := NewValidator("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := withDataset
+			if test.noDataset {
+				v = withoutDataset
+			}
+			got, err := v.Normalize(test.address)
+
+			if len(test.invalid) > 0 {
+				var fields FieldErrors
+				if !errors.As(err, &fields) || !errors.Is(err, ErrInvalidAddress) {
+					t.Fatalf("Normalize = %v, want FieldErrors", err)
+				}
+				var names []string
+				for name := range fields {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				if strings.Join(names, ",") != strings.Join(test.invalid, ",") {
+					t.Errorf("invalid fields %v, want %v", names, test.invalid)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Normalize: %v", err)
+			}
+			if *got.City != test.city || *got.Pincode != test.pin {
+				t.Errorf("city %q pin %q, want %q %q", *got.City, *got.Pincode, test.city, test.pin)
+			}
+			if state := stringOf(got.State); state != test.state {
+				t.Errorf("state %q, want %q", state, test.state)
+			}
+			if *got.House != "Flat 4" || *got.Street != "MG Road" {
+				t.Errorf("house %q street %q, want them collapsed and capitalized", *got.House, *got.Street)
+			}
+		})
+	}
+}
+
+func TestStateNames(t *testing.T) {
+	tests := []struct {
+		country, a, b string
+		same          bool
+	}{
+		{"IN", "KA", "Karnataka", true},
+		{"IN", "ka", "KARNATAKA", true},
+		{"IN", "OD", "OR", true},
+		{"IN", "TS", "Telangana", true},
+		{"IN", "KA", "Kerala", false},
+		{"US", "NY", "new york", true},
+		{"US", "DC", "District of Columbia", true},
+		{"US", "KA", "Karnataka", false},
+		{"CA", "QC", "Quebec", true},
+		{"AU", "WA", "Western Australia", true},
+		{"AU", "WA", "Washington", false},
+		{"GB", "London", "london", true},
+	}
+	for _, test := range tests {
+		if got := sameState(test.country, test.a, test.b); got != test.same {
+			t.Errorf("sameState(%s, %q, %q) = %t, want %t", test.country, test.a, test.b, got, test.same)
+		}
+	}
+}
+
+func TestLoadDatasetRejectsBadPlaces(t *testing.T) {
+	tests := map[string]string{
+		"unknown country": `{"places": [{"country": "XX", "pin_code": "1", "city": "Nowhere"}]}`,
+		"bad code":        `{"places": [{"pin_code": "56001", "city": "Bengaluru"}]}`,
+		"no city":         `{"places": [{"pin_code": "560001", "city": " "}]}`,
+		"listed twice":    `{"places": [{"pin_code": "560001", "city": "Bengaluru"}, {"pin_code": "560 001", "city": "Bangalore"}]}`,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "postal.json")
+			if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadDataset(path); err == nil {
+				t.Error("LoadDataset accepted the dataset")
+			}
+		})
+	}
+}
+
+func stringOf(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
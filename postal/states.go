@@ -0,0 +1,59 @@
+package postal
+
+import "strings"
+
+// The names of the states and regions of countries, by the codes addresses
+// may use instead. Some Indian states have two codes in use.
+var (
+	indianStates = map[string]string{
+		"AN": "Andaman and Nicobar Islands", "AP": "Andhra Pradesh", "AR": "Arunachal Pradesh",
+		"AS": "Assam", "BR": "Bihar", "CH": "Chandigarh", "CG": "Chhattisgarh", "CT": "Chhattisgarh",
+		"DH": "Dadra and Nagar Haveli and Daman and Diu", "DL": "Delhi", "DN": "Dadra and Nagar Haveli",
+		"DD": "Daman and Diu", "GA": "Goa", "GJ": "Gujarat", "HP": "Himachal Pradesh", "HR": "Haryana",
+		"JH": "Jharkhand", "JK": "Jammu and Kashmir", "KA": "Karnataka", "KL": "Kerala", "LA": "Ladakh",
+		"LD": "Lakshadweep", "MH": "Maharashtra", "ML": "Meghalaya", "MN": "Manipur", "MP": "Madhya Pradesh",
+		"MZ": "Mizoram", "NL": "Nagaland", "OD": "Odisha", "OR": "Odisha", "PB": "Punjab", "PY": "Puducherry",
+		"RJ": "Rajasthan", "SK": "Sikkim", "TG": "Telangana", "TN": "Tamil Nadu", "TR": "Tripura",
+		"TS": "Telangana", "UK": "Uttarakhand", "UP": "Uttar Pradesh", "UT": "Uttarakhand", "WB": "West Bengal",
+	}
+	usStates = map[string]string{
+		"AL": "Alabama", "AK": "Alaska", "AZ": "Arizona", "AR": "Arkansas", "CA": "California",
+		"CO": "Colorado", "CT": "Connecticut", "DE": "Delaware", "DC": "District of Columbia",
+		"FL": "Florida", "GA": "Georgia", "HI": "Hawaii", "ID": "Idaho", "IL": "Illinois", "IN": "Indiana",
+		"IA": "Iowa", "KS": "Kansas", "KY": "Kentucky", "LA": "Louisiana", "ME": "Maine", "MD": "Maryland",
+		"MA": "Massachusetts", "MI": "Michigan", "MN": "Minnesota", "MS": "Mississippi", "MO": "Missouri",
+		"MT": "Montana", "NE": "Nebraska", "NV": "Nevada", "NH": "New Hampshire", "NJ": "New Jersey",
+		"NM": "New Mexico", "NY": "New York", "NC": "North Carolina", "ND": "North Dakota", "OH": "Ohio",
+		"OK": "Oklahoma", "OR": "Oregon", "PA": "Pennsylvania", "RI": "Rhode Island", "SC": "South Carolina",
+		"SD": "South Dakota", "TN": "Tennessee", "TX": "Texas", "UT": "Utah", "VT": "Vermont",
+		"VA": "Virginia", "WA": "Washington", "WV": "West Virginia", "WI": "Wisconsin", "WY": "Wyoming",
+		"AS": "American Samoa", "GU": "Guam", "MP": "Northern Mariana Islands", "PR": "Puerto Rico",
+		"VI": "U.S. Virgin Islands",
+	}
+	canadianProvinces = map[string]string{
+		"AB": "Alberta", "BC": "British Columbia", "MB": "Manitoba", "NB": "New Brunswick",
+		"NL": "Newfoundland and Labrador", "NS": "Nova Scotia", "NT": "Northwest Territories",
+		"NU": "Nunavut", "ON": "Ontario", "PE": "Prince Edward Island", "QC": "Quebec",
+		"SK": "Saskatchewan", "YT": "Yukon",
+	}
+	australianStates = map[string]string{
+		"ACT": "Australian Capital Territory", "NSW": "New South Wales", "NT": "Northern Territory",
+		"QLD": "Queensland", "SA": "South Australia", "TAS": "Tasmania", "VIC": "Victoria",
+		"WA": "Western Australia",
+	}
+)
+
+// stateName returns the name of the state of country that state is the code
+// of, or state itself when it isn't a code.
+func stateName(country, state string) string {
+	if name, ok := formats[country].states[strings.ToUpper(state)]; ok {
+		return name
+	}
+	return state
+}
+
+// sameState reports whether a and b are the same state of country, each
+// given by name or by code.
+func sameState(country, a, b string) bool {
+	return strings.EqualFold(stateName(country, a), stateName(country, b))
+}
@@ -0,0 +1,48 @@
+package pricing
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+)
+
+var (
+	ErrCouponDisabled      = errors.New("this coupon is no longer available")
+	ErrCouponNotStarted    = errors.New("this coupon can't be used yet")
+	ErrCouponExpired       = errors.New("this coupon has expired")
+	ErrCouponMinimumNotMet = errors.New("the cart is below the minimum value for this coupon")
+	ErrCouponNotApplicable = errors.New("this coupon doesn't apply to any item in the cart")
+)
+
+// CheckCoupon reports why coupon can't be used on a cart with the given
+// subtotal at now, if it can't. Usage limits are checked by the store.
+func CheckCoupon(coupon models.Coupon, subtotal int64, now time.Time) error {
+	if coupon.Disabled {
+		return ErrCouponDisabled
+	}
+	return checkRule(coupon.DiscountRule, subtotal, now)
+}
+
+// ApplyCoupon takes the discount of coupon off the quote, after any
+// promotions.
+func (q *Quote) ApplyCoupon(coupon models.Coupon, now time.Time) error {
+	coupon.DiscountRule = q.localRule(coupon.DiscountRule)
+	if err := CheckCoupon(coupon, q.Subtotal, now); err != nil {
+		return err
+	}
+	discount := models.Discount{Source: models.SourceCoupon, Code: coupon.Code, Description: coupon.Description}
+	_, err := q.applyRule(coupon.DiscountRule, discount)
+	return err
+}
+
+// ValidateCoupon checks the definition of a coupon created by an admin.
+func ValidateCoupon(coupon models.Coupon) error {
+	switch {
+	case coupon.Code == "":
+		return errors.New("coupon code is required")
+	case coupon.Max_Uses < 0 || coupon.Max_Uses_Per_User < 0:
+		return errors.New("usage limits can't be negative")
+	}
+	return validateRule(coupon.DiscountRule)
+}
@@ -0,0 +1,284 @@
+// Package pricing works out what a cart costs: its subtotal, the promotions
+// and coupon that take money off it, and the tax on the rest.
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+)
+
+// Quote is the price breakdown of a cart. The discount and tax of every line
+// are set on the line itself so that refunds can pay back what the customer
+// paid for it. Discounts apply first and tax last, on the discounted prices;
+// shipping is added on top. Amounts are in minor units of Currency.
+type Quote struct {
+	Currency      string                 `json:"currency,omitempty"`
+	Exchange_Rate string                 `json:"exchange_rate,omitempty"`
+	Items         []models.ProductUser   `json:"items"`
+	Subtotal      int64                  `json:"subtotal"`
+	Discounts     []models.Discount      `json:"discounts,omitempty"`
+	Discount      int64                  `json:"discount"`
+	Free_Shipping bool                   `json:"free_shipping,omitempty"`
+	Tax           int64                  `json:"tax"`
+	Tax_Lines     []models.TaxLine       `json:"tax_lines,omitempty"`
+	Shipping      *models.ShippingOption `json:"shipping,omitempty"`
+	Total         int64                  `json:"total"`
+
+	// exchange converts the fixed amounts of discount rules, which are in
+	// the base currency, to Currency.
+	exchange func(int64) int64
+}
+
+// NewQuote prices lines, already priced in currency, before any discount.
+// exchange converts amounts from the base currency to currency; nil means
+// currency is the base currency.
+func NewQuote(lines []models.ProductUser, currency string, exchange func(int64) int64) Quote {
+	if exchange == nil {
+		exchange = func(amount int64) int64 { return amount }
+	}
+	quote := Quote{Currency: currency, Items: make([]models.ProductUser, len(lines)), exchange: exchange}
+	for i, line := range lines {
+		line.Discount = 0
+		line.Tax = 0
+		quote.Items[i] = line
+		quote.Subtotal += line.LineTotal()
+	}
+	quote.Total = quote.Subtotal
+	return quote
+}
+
+// add records a discount whose amount was already taken off the lines.
+func (q *Quote) add(discount models.Discount) {
+	q.Discounts = append(q.Discounts, discount)
+	q.Discount += discount.Amount
+	if discount.Free_Shipping {
+		q.Free_Shipping = true
+	}
+	q.total()
+}
+
+// total works out the total from the rest of the quote.
+func (q *Quote) total() {
+	q.Total = q.Subtotal - q.Discount + q.Tax
+	if q.Shipping != nil {
+		q.Total += q.Shipping.Price
+	}
+}
+
+// PriceShipping returns option, priced in the base currency, priced in the
+// currency of the quote and free when a discount waived shipping.
+func (q *Quote) PriceShipping(option models.ShippingOption) models.ShippingOption {
+	option.Price = q.exchange(option.Price)
+	if q.Free_Shipping && option.Price > 0 {
+		option.Price = 0
+		option.Free = true
+	}
+	return option
+}
+
+// ApplyShipping adds the price of shipping the cart with option to the
+// quote, after discounts.
+func (q *Quote) ApplyShipping(option models.ShippingOption) {
+	option = q.PriceShipping(option)
+	q.Shipping = &option
+	q.total()
+}
+
+// ApplyTax charges the tax worked out for every line, one tax line per line
+// of the quote, and sums it by zone, category and rate.
+func (q *Quote) ApplyTax(taxes []models.TaxLine) error {
+	if len(taxes) != len(q.Items) {
+		return fmt.Errorf("got %d tax lines for %d items", len(taxes), len(q.Items))
+	}
+
+	q.Tax, q.Tax_Lines = 0, nil
+	for i, line := range taxes {
+		q.Items[i].Tax = line.Amount
+		q.Tax += line.Amount
+		if line.Rate == 0 {
+			continue
+		}
+		if j := taxLineIndex(q.Tax_Lines, line); j >= 0 {
+			q.Tax_Lines[j].Taxable += line.Taxable
+			q.Tax_Lines[j].Amount += line.Amount
+		} else {
+			q.Tax_Lines = append(q.Tax_Lines, line)
+		}
+	}
+	q.total()
+	return nil
+}
+
+func taxLineIndex(lines []models.TaxLine, line models.TaxLine) int {
+	for i, l := range lines {
+		if l.Zone == line.Zone && l.Category == line.Category && l.Rate == line.Rate {
+			return i
+		}
+	}
+	return -1
+}
+
+// remaining returns what is left to pay for the lines at indexes.
+func (q *Quote) remaining(indexes []int) int64 {
+	var total int64
+	for _, i := range indexes {
+		total += q.Items[i].DiscountedTotal()
+	}
+	return total
+}
+
+// spread takes amount off the lines at indexes in proportion to what is left
+// to pay for each, never taking a line below zero, and returns the amount
+// taken off.
+func (q *Quote) spread(indexes []int, amount int64) int64 {
+	remaining := q.remaining(indexes)
+	if amount > remaining {
+		amount = remaining
+	}
+	if amount <= 0 {
+		return 0
+	}
+
+	var taken int64
+	for _, i := range indexes {
+		share := amount * q.Items[i].DiscountedTotal() / remaining
+		q.Items[i].Discount += share
+		taken += share
+	}
+	// Rounding leaves a few units over; they go to the first lines with
+	// something left to pay.
+	for _, i := range indexes {
+		if taken == amount {
+			break
+		}
+		if q.Items[i].DiscountedTotal() > 0 {
+			q.Items[i].Discount++
+			taken++
+		}
+	}
+	return amount
+}
+
+// localRule returns rule with its fixed amounts in the currency of the quote.
+func (q *Quote) localRule(rule models.DiscountRule) models.DiscountRule {
+	if rule.Type == models.DiscountFixed {
+		rule.Value = q.exchange(rule.Value)
+	}
+	rule.Min_Cart_Value = q.exchange(rule.Min_Cart_Value)
+	return rule
+}
+
+// applyRule takes the discount of rule off the quote and returns it with the
+// amount taken off, failing with ErrCouponNotApplicable when no line is in its
+// scope or, for buy X get Y rules, too few are.
+func (q *Quote) applyRule(rule models.DiscountRule, discount models.Discount) (models.Discount, error) {
+	var eligible []int
+	for i, line := range q.Items {
+		if rule.AppliesTo(line) {
+			eligible = append(eligible, i)
+		}
+	}
+	if len(eligible) == 0 {
+		return discount, ErrCouponNotApplicable
+	}
+
+	switch rule.Type {
+	case models.DiscountPercentage:
+		discount.Amount = q.spread(eligible, q.remaining(eligible)*rule.Value/100)
+	case models.DiscountFixed:
+		discount.Amount = q.spread(eligible, rule.Value)
+	case models.DiscountFreeShipping:
+		discount.Free_Shipping = true
+	case models.DiscountBuyXGetY:
+		discount.Amount = q.freeUnits(eligible, rule.Buy_Quantity, rule.Get_Quantity)
+		if discount.Amount == 0 {
+			return discount, fmt.Errorf("%w: buy %d to get %d free", ErrCouponNotApplicable, rule.Buy_Quantity+rule.Get_Quantity, rule.Get_Quantity)
+		}
+	}
+	if discount.Amount > 0 || discount.Free_Shipping {
+		q.add(discount)
+	}
+	return discount, nil
+}
+
+// checkRule reports why rule doesn't apply to a cart with the given subtotal
+// at now, if it doesn't.
+func checkRule(rule models.DiscountRule, subtotal int64, now time.Time) error {
+	switch {
+	case rule.Starts_At != nil && now.Before(*rule.Starts_At):
+		return ErrCouponNotStarted
+	case rule.Ends_At != nil && !now.Before(*rule.Ends_At):
+		return ErrCouponExpired
+	case subtotal < rule.Min_Cart_Value:
+		return fmt.Errorf("%w: spend at least %d", ErrCouponMinimumNotMet, rule.Min_Cart_Value)
+	}
+	return nil
+}
+
+// validateRule checks a discount rule defined by an admin.
+func validateRule(rule models.DiscountRule) error {
+	switch {
+	case !rule.Type.Valid():
+		return errors.New("unknown discount type")
+	case rule.Type == models.DiscountPercentage && (rule.Value <= 0 || rule.Value > 100):
+		return errors.New("percentage discounts need a value between 1 and 100")
+	case rule.Type == models.DiscountFixed && rule.Value <= 0:
+		return errors.New("fixed discounts need a positive value")
+	case rule.Type == models.DiscountBuyXGetY && (rule.Buy_Quantity <= 0 || rule.Get_Quantity <= 0):
+		return errors.New("buy_x_get_y discounts need a positive buy_quantity and get_quantity")
+	case rule.Starts_At != nil && rule.Ends_At != nil && !rule.Ends_At.After(*rule.Starts_At):
+		return errors.New("ends_at must be after starts_at")
+	case rule.Min_Cart_Value < 0:
+		return errors.New("min_cart_value can't be negative")
+	}
+	return nil
+}
+
+// unitLine is a cart line and the price of one of its units.
+type unitLine struct {
+	line     int
+	quantity int64
+	price    int64
+}
+
+// freeUnits takes get units off for every buy units bought among the lines at
+// indexes, the cheapest units first, and returns the amount taken off. It
+// works on the count of each line, so it costs the same for any quantity.
+func (q *Quote) freeUnits(indexes []int, buy, get int) int64 {
+	if buy <= 0 || get <= 0 {
+		return 0
+	}
+
+	lines := make([]unitLine, 0, len(indexes))
+	var units int64
+	for _, i := range indexes {
+		line := q.Items[i]
+		if line.Quantity <= 0 {
+			continue
+		}
+		quantity := int64(line.Quantity)
+		lines = append(lines, unitLine{line: i, quantity: quantity, price: line.DiscountedTotal() / quantity})
+		units += quantity
+	}
+	sort.SliceStable(lines, func(a, b int) bool { return lines[a].price < lines[b].price })
+
+	free := units / int64(buy+get) * int64(get)
+	var taken int64
+	for _, l := range lines {
+		if free == 0 {
+			break
+		}
+		n := l.quantity
+		if n > free {
+			n = free
+		}
+		q.Items[l.line].Discount += n * l.price
+		taken += n * l.price
+		free -= n
+	}
+	return taken
+}
@@ -0,0 +1,309 @@
+package pricing
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// line returns a cart line of quantity units of a new product at price.
+func line(price int64, quantity int, category string) models.ProductUser {
+	return models.ProductUser{Product_ID: primitive.NewObjectID(), Price: &price, Quantity: quantity, Category: category}
+}
+
+// discounts returns the discount taken off each item of the quote.
+func discounts(q Quote) []int64 {
+	amounts := make([]int64, len(q.Items))
+	for i, item := range q.Items {
+		amounts[i] = item.Discount
+	}
+	return amounts
+}
+
+func equal(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestApplyCoupon(t *testing.T) {
+	now := time.Now().UTC()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	double := func(amount int64) int64 { return 2 * amount }
+	books := line(500, 1, "books")
+
+	tests := []struct {
+		name     string
+		lines    []models.ProductUser
+		exchange func(int64) int64
+		rule     models.DiscountRule
+		disabled bool
+		want     []int64
+		err      error
+	}{
+		{
+			name:  "percentage spread by share",
+			lines: []models.ProductUser{line(100, 1, ""), line(300, 1, "")},
+			rule:  models.DiscountRule{Type: models.DiscountPercentage, Value: 10},
+			want:  []int64{10, 30},
+		},
+		{
+			name:  "percentage rounding goes to the first lines",
+			lines: []models.ProductUser{line(33, 1, ""), line(33, 1, ""), line(34, 1, "")},
+			rule:  models.DiscountRule{Type: models.DiscountPercentage, Value: 10},
+			want:  []int64{4, 3, 3},
+		},
+		{
+			name:  "fixed in proportion",
+			lines: []models.ProductUser{line(100, 1, ""), line(300, 1, "")},
+			rule:  models.DiscountRule{Type: models.DiscountFixed, Value: 50},
+			want:  []int64{13, 37},
+		},
+		{
+			name:  "fixed above the cart stops at zero",
+			lines: []models.ProductUser{line(100, 1, ""), line(300, 1, "")},
+			rule:  models.DiscountRule{Type: models.DiscountFixed, Value: 1000},
+			want:  []int64{100, 300},
+		},
+		{
+			name:     "fixed in the quote's currency",
+			lines:    []models.ProductUser{line(1000, 1, "")},
+			exchange: double,
+			rule:     models.DiscountRule{Type: models.DiscountFixed, Value: 50},
+			want:     []int64{100},
+		},
+		{
+			name:  "scoped to a category",
+			lines: []models.ProductUser{line(1000, 1, "toys"), books},
+			rule:  models.DiscountRule{Type: models.DiscountPercentage, Value: 10, Categories: []string{"books"}},
+			want:  []int64{0, 50},
+		},
+		{
+			name:  "scoped to a product",
+			lines: []models.ProductUser{line(1000, 1, ""), books},
+			rule:  models.DiscountRule{Type: models.DiscountFixed, Value: 20, Product_IDs: []primitive.ObjectID{books.Product_ID}},
+			want:  []int64{0, 20},
+		},
+		{
+			name:  "out of scope",
+			lines: []models.ProductUser{line(1000, 1, "toys")},
+			rule:  models.DiscountRule{Type: models.DiscountPercentage, Value: 10, Categories: []string{"books"}},
+			err:   ErrCouponNotApplicable,
+		},
+		{
+			name:  "below the minimum",
+			lines: []models.ProductUser{line(100, 1, "")},
+			rule:  models.DiscountRule{Type: models.DiscountPercentage, Value: 10, Min_Cart_Value: 200},
+			err:   ErrCouponMinimumNotMet,
+		},
+		{
+			name:     "minimum in the quote's currency",
+			lines:    []models.ProductUser{line(300, 1, "")},
+			exchange: double,
+			rule:     models.DiscountRule{Type: models.DiscountPercentage, Value: 10, Min_Cart_Value: 200},
+			err:      ErrCouponMinimumNotMet,
+		},
+		{
+			name:  "not started",
+			lines: []models.ProductUser{line(100, 1, "")},
+			rule:  models.DiscountRule{Type: models.DiscountPercentage, Value: 10, Starts_At: &future},
+			err:   ErrCouponNotStarted,
+		},
+		{
+			name:  "expired",
+			lines: []models.ProductUser{line(100, 1, "")},
+			rule:  models.DiscountRule{Type: models.DiscountPercentage, Value: 10, Ends_At: &past},
+			err:   ErrCouponExpired,
+		},
+		{
+			name:     "disabled",
+			lines:    []models.ProductUser{line(100, 1, "")},
+			rule:     models.DiscountRule{Type: models.DiscountPercentage, Value: 10},
+			disabled: true,
+			err:      ErrCouponDisabled,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			quote := NewQuote(test.lines, "INR", test.exchange)
+			err := quote.ApplyCoupon(models.Coupon{Code: "C", DiscountRule: test.rule, Disabled: test.disabled}, now)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("ApplyCoupon = %v, want %v", err, test.err)
+			}
+			if test.err != nil {
+				if quote.Discount != 0 || quote.Total != quote.Subtotal {
+					t.Errorf("refused coupon took %d off", quote.Discount)
+				}
+				return
+			}
+			if got := discounts(quote); !equal(got, test.want) {
+				t.Errorf("discounts %v, want %v", got, test.want)
+			}
+			var sum int64
+			for _, amount := range test.want {
+				sum += amount
+			}
+			if quote.Discount != sum || quote.Total != quote.Subtotal-sum {
+				t.Errorf("discount %d total %d, want %d off %d", quote.Discount, quote.Total, sum, quote.Subtotal)
+			}
+		})
+	}
+}
+
+func TestBuyXGetY(t *testing.T) {
+	tests := []struct {
+		name       string
+		lines      []models.ProductUser
+		buy, get   int
+		want       []int64
+		applicable bool
+	}{
+		{"cheapest unit free", []models.ProductUser{line(100, 2, ""), line(50, 1, "")}, 2, 1, []int64{0, 50}, true},
+		{"free units span lines", []models.ProductUser{line(10, 1, ""), line(20, 5, "")}, 1, 1, []int64{10, 40}, true},
+		{"one line", []models.ProductUser{line(30, 7, "")}, 2, 1, []int64{60}, true},
+		{"too few units", []models.ProductUser{line(100, 1, ""), line(50, 1, "")}, 2, 1, nil, false},
+		// The free units are counted per line, so huge quantities cost no
+		// more to work out than small ones.
+		{"huge quantity", []models.ProductUser{line(3, 1_000_000_000, "")}, 1, 1, []int64{1_500_000_000}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			quote := NewQuote(test.lines, "INR", nil)
+			rule := models.DiscountRule{Type: models.DiscountBuyXGetY, Buy_Quantity: test.buy, Get_Quantity: test.get}
+			err := quote.ApplyCoupon(models.Coupon{Code: "BOGO", DiscountRule: rule}, time.Now())
+			if !test.applicable {
+				if !errors.Is(err, ErrCouponNotApplicable) {
+					t.Fatalf("ApplyCoupon = %v, want %v", err, ErrCouponNotApplicable)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := discounts(quote); !equal(got, test.want) {
+				t.Errorf("discounts %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestApplyPromotions(t *testing.T) {
+	now := time.Now().UTC()
+	promotion := func(name string, priority int, rule models.DiscountRule) models.Promotion {
+		return models.Promotion{Promotion_ID: primitive.NewObjectID(), Name: name, DiscountRule: rule, Priority: priority, Created_At: now}
+	}
+	tenPercent := models.DiscountRule{Type: models.DiscountPercentage, Value: 10}
+	fiftyOff := models.DiscountRule{Type: models.DiscountFixed, Value: 50}
+
+	stop := promotion("stop", 5, tenPercent)
+	stop.Stop_Further = true
+	disabled := promotion("disabled", 9, fiftyOff)
+	disabled.Disabled = true
+	older := promotion("older", 1, fiftyOff)
+	older.Created_At = now.Add(-time.Hour)
+
+	tests := []struct {
+		name       string
+		promotions []models.Promotion
+		want       []string
+		discount   int64
+	}{
+		// 10% of 1000, then 50 off the 900 left.
+		{"by priority", []models.Promotion{promotion("fixed", 1, fiftyOff), promotion("percent", 2, tenPercent)}, []string{"percent", "fixed"}, 150},
+		// 50 off 1000, then 10% of the 950 left.
+		{"each on what is left", []models.Promotion{promotion("fixed", 2, fiftyOff), promotion("percent", 1, tenPercent)}, []string{"fixed", "percent"}, 145},
+		{"older first on a tie", []models.Promotion{promotion("newer", 1, tenPercent), older}, []string{"older", "newer"}, 145},
+		{"stop further", []models.Promotion{promotion("after", 1, fiftyOff), stop}, []string{"stop"}, 100},
+		{"disabled skipped", []models.Promotion{disabled, promotion("fixed", 1, fiftyOff)}, []string{"fixed"}, 50},
+		{
+			"not qualifying skipped",
+			[]models.Promotion{promotion("big carts", 2, models.DiscountRule{Type: models.DiscountPercentage, Value: 50, Min_Cart_Value: 5000}), stop},
+			[]string{"stop"}, 100,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			quote := NewQuote([]models.ProductUser{line(1000, 1, "")}, "INR", nil)
+			quote.ApplyPromotions(test.promotions, now)
+
+			var applied []string
+			for _, discount := range quote.Discounts {
+				applied = append(applied, discount.Description)
+			}
+			if len(applied) != len(test.want) {
+				t.Fatalf("applied %v, want %v", applied, test.want)
+			}
+			for i := range applied {
+				if applied[i] != test.want[i] {
+					t.Fatalf("applied %v, want %v", applied, test.want)
+				}
+			}
+			if quote.Discount != test.discount {
+				t.Errorf("discount %d, want %d", quote.Discount, test.discount)
+			}
+		})
+	}
+}
+
+func TestCouponAppliesAfterPromotions(t *testing.T) {
+	now := time.Now().UTC()
+	quote := NewQuote([]models.ProductUser{line(600, 1, ""), line(400, 1, "")}, "INR", nil)
+	quote.ApplyPromotions([]models.Promotion{{
+		Promotion_ID: primitive.NewObjectID(), Name: "sale",
+		DiscountRule: models.DiscountRule{Type: models.DiscountPercentage, Value: 10},
+	}}, now)
+
+	// The coupon takes 10% of the 900 left, while its minimum is checked
+	// against the subtotal before discounts.
+	coupon := models.Coupon{Code: "SAVE10", DiscountRule: models.DiscountRule{Type: models.DiscountPercentage, Value: 10, Min_Cart_Value: 1000}}
+	if err := quote.ApplyCoupon(coupon, now); err != nil {
+		t.Fatal(err)
+	}
+	if len(quote.Discounts) != 2 || quote.Discounts[1].Source != models.SourceCoupon || quote.Discounts[1].Amount != 90 {
+		t.Fatalf("discounts %+v, want the promotion then 90 off with the coupon", quote.Discounts)
+	}
+	if got := discounts(quote); !equal(got, []int64{114, 76}) {
+		t.Errorf("line discounts %v, want [114 76]", got)
+	}
+	if quote.Total != 810 {
+		t.Errorf("total %d, want 810", quote.Total)
+	}
+}
+
+func TestQuoteTotals(t *testing.T) {
+	quote := NewQuote([]models.ProductUser{line(1000, 2, ""), line(500, 1, "")}, "INR", nil)
+	if err := quote.ApplyCoupon(models.Coupon{Code: "SHIP", DiscountRule: models.DiscountRule{Type: models.DiscountFreeShipping}}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	taxes := []models.TaxLine{
+		{Zone: "ka", Category: "standard", Rate: 1800, Taxable: 2000, Amount: 360},
+		{Zone: "ka", Category: "standard", Rate: 1800, Taxable: 500, Amount: 90},
+	}
+	if err := quote.ApplyTax(taxes); err != nil {
+		t.Fatal(err)
+	}
+	shipping := quote.PriceShipping(models.ShippingOption{Price: 100})
+	quote.ApplyShipping(models.ShippingOption{Price: 100})
+
+	if !shipping.Free || quote.Shipping.Price != 0 {
+		t.Errorf("shipping %+v, want it free", quote.Shipping)
+	}
+	if len(quote.Tax_Lines) != 1 || quote.Tax_Lines[0].Amount != 450 || quote.Tax_Lines[0].Taxable != 2500 {
+		t.Errorf("tax lines %+v, want one of 450 on 2500", quote.Tax_Lines)
+	}
+	if quote.Subtotal != 2500 || quote.Tax != 450 || quote.Total != 2950 {
+		t.Errorf("subtotal %d tax %d total %d, want 2500, 450 and 2950", quote.Subtotal, quote.Tax, quote.Total)
+	}
+	if err := quote.ApplyTax(taxes[:1]); err == nil {
+		t.Error("ApplyTax accepted fewer tax lines than items")
+	}
+}
@@ -0,0 +1,51 @@
+package pricing
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+)
+
+// ApplyPromotions takes the discounts of the promotions that the cart
+// qualifies for at now off the quote. Promotions apply by descending
+// priority, older ones first on a tie, each to what is left to pay after the
+// ones before it; a promotion marked stop_further ends the run once it
+// applied.
+func (q *Quote) ApplyPromotions(promotions []models.Promotion, now time.Time) {
+	ordered := append([]models.Promotion(nil), promotions...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Priority != ordered[j].Priority {
+			return ordered[i].Priority > ordered[j].Priority
+		}
+		return ordered[i].Created_At.Before(ordered[j].Created_At)
+	})
+
+	for _, promotion := range ordered {
+		promotion.DiscountRule = q.localRule(promotion.DiscountRule)
+		if promotion.Disabled || checkRule(promotion.DiscountRule, q.Subtotal, now) != nil {
+			continue
+		}
+		discount := models.Discount{
+			Source:      models.SourcePromotion,
+			Code:        promotion.Promotion_ID.Hex(),
+			Description: promotion.Name,
+		}
+		applied, err := q.applyRule(promotion.DiscountRule, discount)
+		if err != nil || (applied.Amount == 0 && !applied.Free_Shipping) {
+			continue
+		}
+		if promotion.Stop_Further {
+			return
+		}
+	}
+}
+
+// ValidatePromotion checks the definition of a promotion created by an admin.
+func ValidatePromotion(promotion models.Promotion) error {
+	if promotion.Name == "" {
+		return errors.New("promotion name is required")
+	}
+	return validateRule(promotion.DiscountRule)
+}
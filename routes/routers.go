@@ -2,20 +2,68 @@ package routes
 
 import (
 	"github.com/ChandanJnv/ecommerce-cart-golang/controllers"
+	"github.com/ChandanJnv/ecommerce-cart-golang/middleware"
 	"github.com/gin-gonic/gin"
 )
 
-func UserRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.POST("/users/signup", controllers.SignUp())
-	incomingRoutes.POST("/users/login", controllers.Login())
-	incomingRoutes.POST("/admin/addproduct", controllers.ProductViewerAdmin())
-	incomingRoutes.GET("/users/productview", controllers.SearchProduct())
-	incomingRoutes.GET("/users/search", controllers.SearchProductByQuery())
+func UserRoutes(incomingRoutes *gin.Engine, app *controllers.Application) {
+	incomingRoutes.POST("/users/signup", app.SignUp())
+	incomingRoutes.POST("/users/login", app.Login())
+	incomingRoutes.POST("/users/refresh", app.RefreshToken())
+	incomingRoutes.GET("/users/productview", app.SearchProduct())
+	incomingRoutes.GET("/users/search", app.SearchProductByQuery())
 }
 
-func AddAddressRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.POST("/address/addaddress", controllers.AddAddress())
-	incomingRoutes.POST("/address/edithomeaddress", controllers.EditHomeAddress())
-	incomingRoutes.POST("/address/editworkaddress", controllers.EditWorkAddress())
-	incomingRoutes.DELETE("/address/deleteaddress", controllers.DeleteAddress())
+// AddressRoutes registers the address book of the signed-in user, so they
+// must come after the authentication middleware.
+func AddressRoutes(incomingRoutes *gin.Engine, app *controllers.Application) {
+	incomingRoutes.GET("/addresses", app.ListAddresses())
+	incomingRoutes.POST("/addresses", app.CreateAddress())
+	incomingRoutes.GET("/addresses/:id", app.GetAddress())
+	incomingRoutes.PUT("/addresses/:id", app.UpdateAddress())
+	incomingRoutes.DELETE("/addresses/:id", app.DeleteAddress())
+}
+
+// GuestCartRoutes registers the cart of anonymous shoppers behind
+// middlewares, which run after the request is routed.
+func GuestCartRoutes(incomingRoutes *gin.Engine, app *controllers.Application, middlewares ...gin.HandlerFunc) {
+	guest := incomingRoutes.Group("/guest", middlewares...)
+	guest.POST("/addtocart", app.GuestAddToCart())
+	guest.DELETE("/removeitem", app.GuestRemoveItem())
+	guest.GET("/cart", app.GuestGetCart())
+	guest.PUT("/cart/quantity", app.GuestSetCartQuantity())
+	guest.POST("/cart/decrement", app.GuestDecrementCartItem())
+}
+
+// AdminRoutes registers the admin routes behind AdminAuthentication and then
+// middlewares.
+func AdminRoutes(incomingRoutes *gin.Engine, app *controllers.Application, middlewares ...gin.HandlerFunc) {
+	admin := incomingRoutes.Group("/admin", middleware.AdminAuthentication())
+	admin.Use(middlewares...)
+	admin.POST("/addproduct", app.ProductViewerAdmin())
+	admin.GET("/orders/:id", app.AdminGetOrder())
+	admin.POST("/orders/:id/status", app.UpdateOrderStatus())
+	admin.GET("/orders/:id/shipments", app.AdminListShipments())
+	admin.POST("/orders/:id/shipments", app.AdminCreateShipment())
+	admin.POST("/shipments/:id/events", app.AdminAddShipmentEvent())
+	admin.GET("/returns", app.AdminListReturns())
+	admin.POST("/returns/:id/approve", app.ApproveReturn())
+	admin.POST("/returns/:id/reject", app.RejectReturn())
+	admin.POST("/returns/:id/receive", app.ReceiveReturn())
+	admin.GET("/products/:id/stock", app.AdminGetStock())
+	admin.POST("/products/:id/stock", app.AdminAdjustStock())
+	admin.PUT("/products/:id/price", app.AdminUpdatePrice())
+	admin.GET("/coupons", app.AdminListCoupons())
+	admin.POST("/coupons", app.AdminCreateCoupon())
+	admin.GET("/coupons/:code", app.AdminGetCoupon())
+	admin.PUT("/coupons/:code", app.AdminUpdateCoupon())
+	admin.GET("/promotions", app.AdminListPromotions())
+	admin.POST("/promotions", app.AdminCreatePromotion())
+	admin.GET("/promotions/:id", app.AdminGetPromotion())
+	admin.PUT("/promotions/:id", app.AdminUpdatePromotion())
+	admin.DELETE("/promotions/:id", app.AdminDeletePromotion())
+}
+
+func PaymentRoutes(incomingRoutes *gin.Engine, app *controllers.Application) {
+	incomingRoutes.POST("/payments/webhook", app.PaymentWebhook())
 }
@@ -0,0 +1,43 @@
+// Package shipping works out how a cart can be shipped to an address and
+// what each way costs.
+package shipping
+
+import (
+	"context"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+)
+
+// Rater lists the shipping options for the lines of a cart shipped to
+// address, with their prices in minor units of the base currency. Methods
+// that can't deliver the cart there are left out.
+type Rater interface {
+	Options(ctx context.Context, address models.Address, lines []models.ProductUser) ([]models.ShippingOption, error)
+}
+
+// NewRater returns the table-driven rater loaded from the rate table at path,
+// or one offering free standard shipping everywhere when path is empty.
+func NewRater(path string) (Rater, error) {
+	if path == "" {
+		return Free(), nil
+	}
+	return LoadTable(path)
+}
+
+// Free returns a rate table with free standard shipping to any address.
+func Free() *Table {
+	return &Table{Methods: []Method{{
+		Method: models.ShippingStandard,
+		Name:   "Standard delivery",
+		Zones:  []Zone{{Name: "everywhere", Rates: []Rate{{Price: 0}}}},
+	}}}
+}
+
+// Weight returns the shipping weight of lines in grams.
+func Weight(lines []models.ProductUser) int64 {
+	var weight int64
+	for _, line := range lines {
+		weight += line.Weight * int64(line.Quantity)
+	}
+	return weight
+}
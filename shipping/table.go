@@ -0,0 +1,149 @@
+package shipping
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+)
+
+// Rate is the price of shipping carts weighing up to Max_Weight grams; a
+// Max_Weight of zero has no limit.
+type Rate struct {
+	Max_Weight int64 `json:"max_weight,omitempty"`
+	Price      int64 `json:"price"`
+}
+
+// Zone is an area a method delivers to, with its own rates. Zones match
+// addresses like tax zones: by state, or else by the longest matching pincode
+// prefix, with a zone listing neither catching every other address.
+type Zone struct {
+	Name             string   `json:"name"`
+	States           []string `json:"states,omitempty"`
+	Pincode_Prefixes []string `json:"pincode_prefixes,omitempty"`
+	// Rates are tried in order; the first one the cart is light enough
+	// for applies.
+	Rates []Rate `json:"rates"`
+}
+
+// Method is a shipping method and the zones it delivers to. Addresses in
+// none of its zones can't use it, e.g. pickup where there is no store.
+type Method struct {
+	Method        models.ShippingMethod `json:"method"`
+	Name          string                `json:"name"`
+	Delivery_Days string                `json:"delivery_days,omitempty"`
+	Zones         []Zone                `json:"zones"`
+}
+
+// Table is a shipping rater driven by a table of methods, zones and weight
+// rates, loaded from a JSON file. Options are listed in the order of its
+// methods.
+type Table struct {
+	Methods []Method `json:"methods"`
+}
+
+// LoadTable reads a shipping rate table from a JSON file.
+func LoadTable(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading shipping rates: %w", err)
+	}
+	var table Table
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("parsing shipping rates %s: %w", path, err)
+	}
+	if err := table.validate(); err != nil {
+		return nil, fmt.Errorf("shipping rates %s: %w", path, err)
+	}
+	return &table, nil
+}
+
+func (t *Table) validate() error {
+	if len(t.Methods) == 0 {
+		return errors.New("at least one method is required")
+	}
+	seen := make(map[models.ShippingMethod]bool, len(t.Methods))
+	for _, method := range t.Methods {
+		if !method.Method.Valid() {
+			return fmt.Errorf("unknown shipping method %q", method.Method)
+		}
+		if seen[method.Method] {
+			return fmt.Errorf("method %s is listed twice", method.Method)
+		}
+		seen[method.Method] = true
+		for _, zone := range method.Zones {
+			if zone.Name == "" {
+				return fmt.Errorf("method %s: every zone needs a name", method.Method)
+			}
+			if len(zone.Rates) == 0 {
+				return fmt.Errorf("method %s, zone %s: at least one rate is required", method.Method, zone.Name)
+			}
+			for _, rate := range zone.Rates {
+				if rate.Price < 0 || rate.Max_Weight < 0 {
+					return fmt.Errorf("method %s, zone %s: prices and weights can't be negative", method.Method, zone.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (t *Table) Options(ctx context.Context, address models.Address, lines []models.ProductUser) ([]models.ShippingOption, error) {
+	weight := Weight(lines)
+
+	options := make([]models.ShippingOption, 0, len(t.Methods))
+	for _, method := range t.Methods {
+		zone, ok := zoneOf(method.Zones, address)
+		if !ok {
+			continue
+		}
+		for _, rate := range zone.Rates {
+			if rate.Max_Weight == 0 || weight <= rate.Max_Weight {
+				options = append(options, models.ShippingOption{
+					Method:        method.Method,
+					Name:          method.Name,
+					Delivery_Days: method.Delivery_Days,
+					Zone:          zone.Name,
+					Price:         rate.Price,
+				})
+				break
+			}
+		}
+	}
+	return options, nil
+}
+
+// zoneOf returns the zone among zones that address belongs to.
+func zoneOf(zones []Zone, address models.Address) (Zone, bool) {
+	if address.State != nil {
+		state := strings.TrimSpace(*address.State)
+		for _, zone := range zones {
+			for _, s := range zone.States {
+				if strings.EqualFold(s, state) {
+					return zone, true
+				}
+			}
+		}
+	}
+
+	var pincode string
+	if address.Pincode != nil {
+		pincode = strings.ReplaceAll(*address.Pincode, " ", "")
+	}
+	best, bestLength, found := Zone{}, -1, false
+	for _, zone := range zones {
+		if len(zone.States) == 0 && len(zone.Pincode_Prefixes) == 0 && bestLength < 0 {
+			best, bestLength, found = zone, 0, true
+		}
+		for _, prefix := range zone.Pincode_Prefixes {
+			if pincode != "" && strings.HasPrefix(pincode, prefix) && len(prefix) > bestLength {
+				best, bestLength, found = zone, len(prefix), true
+			}
+		}
+	}
+	return best, found
+}
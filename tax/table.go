@@ -0,0 +1,124 @@
+package tax
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+)
+
+// basisPoints is the denominator of tax rates: 1800 is 18%.
+const basisPoints = 10000
+
+// Zone is an area with its own tax rates. Addresses belong to the zone that
+// lists their state, or else the one with the longest matching pincode
+// prefix; a zone listing neither catches every other address.
+type Zone struct {
+	Name             string   `json:"name"`
+	States           []string `json:"states,omitempty"`
+	Pincode_Prefixes []string `json:"pincode_prefixes,omitempty"`
+	// Rates maps tax categories to rates in basis points.
+	Rates map[string]int64 `json:"rates"`
+}
+
+// Table is a tax calculator driven by a table of zones and rates, loaded
+// from a JSON file. Products without a tax category, or with one the zone
+// doesn't list, are taxed at the rate of Default_Category.
+type Table struct {
+	Default_Category string `json:"default_category"`
+	Zones            []Zone `json:"zones"`
+}
+
+// LoadTable reads a tax table from a JSON file.
+func LoadTable(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading tax table: %w", err)
+	}
+	var table Table
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("parsing tax table %s: %w", path, err)
+	}
+	if err := table.validate(); err != nil {
+		return nil, fmt.Errorf("tax table %s: %w", path, err)
+	}
+	return &table, nil
+}
+
+func (t *Table) validate() error {
+	if t.Default_Category == "" {
+		return errors.New("default_category is required")
+	}
+	for _, zone := range t.Zones {
+		if zone.Name == "" {
+			return errors.New("every zone needs a name")
+		}
+		for category, rate := range zone.Rates {
+			if rate < 0 || rate > basisPoints {
+				return fmt.Errorf("zone %s: rate of %s must be between 0 and %d", zone.Name, category, basisPoints)
+			}
+		}
+	}
+	return nil
+}
+
+func (t *Table) Tax(ctx context.Context, address models.Address, lines []models.ProductUser) ([]models.TaxLine, error) {
+	zone, ok := t.zoneOf(address)
+
+	taxes := make([]models.TaxLine, len(lines))
+	for i, line := range lines {
+		category := line.Tax_Category
+		if category == "" {
+			category = t.Default_Category
+		}
+		taxes[i] = models.TaxLine{Category: category, Taxable: line.DiscountedTotal()}
+		if !ok {
+			continue
+		}
+
+		rate, listed := zone.Rates[category]
+		if !listed {
+			rate = zone.Rates[t.Default_Category]
+		}
+		taxes[i].Zone = zone.Name
+		taxes[i].Rate = rate
+		// Half units round up.
+		taxes[i].Amount = (taxes[i].Taxable*rate + basisPoints/2) / basisPoints
+	}
+	return taxes, nil
+}
+
+// zoneOf returns the zone address belongs to.
+func (t *Table) zoneOf(address models.Address) (Zone, bool) {
+	if address.State != nil {
+		state := strings.TrimSpace(*address.State)
+		for _, zone := range t.Zones {
+			for _, s := range zone.States {
+				if strings.EqualFold(s, state) {
+					return zone, true
+				}
+			}
+		}
+	}
+
+	var pincode string
+	if address.Pincode != nil {
+		pincode = strings.ReplaceAll(*address.Pincode, " ", "")
+	}
+	best, bestLength, found := Zone{}, -1, false
+	for _, zone := range t.Zones {
+		if len(zone.States) == 0 && len(zone.Pincode_Prefixes) == 0 && bestLength < 0 {
+			best, bestLength, found = zone, 0, true
+		}
+		for _, prefix := range zone.Pincode_Prefixes {
+			if pincode != "" && strings.HasPrefix(pincode, prefix) && len(prefix) > bestLength {
+				best, bestLength, found = zone, len(prefix), true
+			}
+		}
+	}
+	return best, found
+}
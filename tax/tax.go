@@ -0,0 +1,36 @@
+// Package tax works out the taxes due on the lines of a cart or order from
+// where it ships to and what the products are.
+package tax
+
+import (
+	"context"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+)
+
+// Calculator works out the tax of the lines of a cart shipped to address.
+// Lines are taxed on their discounted total. It returns one tax line per
+// cart line, in the same order.
+type Calculator interface {
+	Tax(ctx context.Context, address models.Address, lines []models.ProductUser) ([]models.TaxLine, error)
+}
+
+// NewCalculator returns the table-driven calculator loaded from the tax table
+// at path, or one that charges no tax when path is empty.
+func NewCalculator(path string) (Calculator, error) {
+	if path == "" {
+		return None{}, nil
+	}
+	return LoadTable(path)
+}
+
+// None charges no tax, for prices that already include it.
+type None struct{}
+
+func (None) Tax(ctx context.Context, address models.Address, lines []models.ProductUser) ([]models.TaxLine, error) {
+	taxes := make([]models.TaxLine, len(lines))
+	for i, line := range lines {
+		taxes[i] = models.TaxLine{Category: line.Tax_Category, Taxable: line.DiscountedTotal()}
+	}
+	return taxes, nil
+}
@@ -0,0 +1,114 @@
+package tokens
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+)
+
+// syncOverlap is how far back each sync looks before the previous one, so
+// that entries written by other instances with a slightly late clock aren't
+// missed.
+const syncOverlap = time.Minute
+
+// RevocationList is the list of tokens revoked before they expire, cached in
+// process so checking a token costs no database round trip. Revocations made
+// through the list take effect at once; those made by other instances once
+// the list syncs with the store.
+type RevocationList struct {
+	store database.RevocationStore
+
+	mu sync.RWMutex
+	// revoked maps the revoked token and session IDs to when the entry
+	// expires.
+	revoked map[string]time.Time
+	synced  time.Time
+}
+
+func NewRevocationList(store database.RevocationStore) *RevocationList {
+	return &RevocationList{store: store, revoked: make(map[string]time.Time)}
+}
+
+// Revoke adds revocations to the store and the cache.
+func (l *RevocationList) Revoke(ctx context.Context, revocations ...models.Revocation) error {
+	if err := l.store.AddRevocations(ctx, revocations); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, revocation := range revocations {
+		l.revoked[revocation.Revocation_ID] = revocation.Expires_At
+	}
+	return nil
+}
+
+// IsRevoked reports whether the token with claims, or its session, was
+// revoked.
+func (l *RevocationList) IsRevoked(claims *SignedDetails) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	now := time.Now().UTC()
+	for _, id := range []string{claims.Id, claims.Session} {
+		if id == "" {
+			continue
+		}
+		if expires, ok := l.revoked[id]; ok && expires.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sync adds the entries added to the store since the last sync, by any
+// instance, to the cache and forgets the expired ones.
+func (l *RevocationList) Sync(ctx context.Context) error {
+	l.mu.RLock()
+	since := l.synced
+	l.mu.RUnlock()
+	if !since.IsZero() {
+		since = since.Add(-syncOverlap)
+	}
+
+	started := time.Now().UTC()
+	revocations, err := l.store.ListRevocations(ctx, since)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, revocation := range revocations {
+		l.revoked[revocation.Revocation_ID] = revocation.Expires_At
+	}
+	for id, expires := range l.revoked {
+		if !expires.After(started) {
+			delete(l.revoked, id)
+		}
+	}
+	l.synced = started
+	return nil
+}
+
+// Run syncs the list every interval until ctx is done.
+func (l *RevocationList) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			syncCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			if err := l.Sync(syncCtx); err != nil {
+				log.Println("failed to sync the token revocation list:", err)
+			}
+			cancel()
+		}
+	}
+}
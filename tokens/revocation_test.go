@@ -0,0 +1,127 @@
+package tokens
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ChandanJnv/ecommerce-cart-golang/database"
+	"github.com/ChandanJnv/ecommerce-cart-golang/models"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// revocation returns an entry revoking id until ttl from now.
+func revocation(id string, kind models.RevocationKind, ttl time.Duration) models.Revocation {
+	now := time.Now().UTC()
+	return models.Revocation{
+		Revocation_ID: id,
+		Kind:          kind,
+		User_ID:       "user",
+		Revoked_At:    now,
+		Expires_At:    now.Add(ttl),
+	}
+}
+
+// tokenOf returns the claims of a token with the ID tokenID of session.
+func tokenOf(session, tokenID string) *SignedDetails {
+	return &SignedDetails{Uid: "user", Session: session, Type: AccessToken, StandardClaims: jwt.StandardClaims{Id: tokenID}}
+}
+
+func TestIsRevoked(t *testing.T) {
+	ctx := context.Background()
+	list := NewRevocationList(database.NewMemoryStore())
+	err := list.Revoke(ctx,
+		revocation("revoked-token", models.RevokedToken, time.Hour),
+		revocation("revoked-session", models.RevokedSession, time.Hour),
+		revocation("expired-token", models.RevokedToken, -time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		claims *SignedDetails
+		want   bool
+	}{
+		{"live token", tokenOf("session", "token"), false},
+		{"revoked token", tokenOf("session", "revoked-token"), true},
+		{"token of a revoked session", tokenOf("revoked-session", "token"), true},
+		{"revoked token without a session", tokenOf("", "revoked-token"), true},
+		{"token without a session or ID", tokenOf("", ""), false},
+		{"expired revocation", tokenOf("session", "expired-token"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := list.IsRevoked(tt.claims); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncPicksUpOtherInstances(t *testing.T) {
+	ctx := context.Background()
+	store := database.NewMemoryStore()
+	here, there := NewRevocationList(store), NewRevocationList(store)
+	if err := here.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := there.Revoke(ctx, revocation("session", models.RevokedSession, time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	claims := tokenOf("session", "token")
+	// The instance that revoked the session rejects its tokens at once, the
+	// others once they sync.
+	if !there.IsRevoked(claims) {
+		t.Error("the revoking instance accepts the token")
+	}
+	if here.IsRevoked(claims) {
+		t.Error("the other instance knows the revocation before syncing")
+	}
+	if err := here.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !here.IsRevoked(claims) {
+		t.Error("the other instance accepts the token after syncing")
+	}
+}
+
+func TestSyncDropsExpiredEntries(t *testing.T) {
+	ctx := context.Background()
+	list := NewRevocationList(database.NewMemoryStore())
+	err := list.Revoke(ctx,
+		revocation("short", models.RevokedToken, 50*time.Millisecond),
+		revocation("long", models.RevokedToken, time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !list.IsRevoked(tokenOf("", "short")) {
+		t.Fatal("the token isn't revoked")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if list.IsRevoked(tokenOf("", "short")) {
+		t.Error("the token is still revoked after its entry expired")
+	}
+	if err := list.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := list.revoked["short"]; ok {
+		t.Error("the expired entry is still cached after syncing")
+	}
+	if !list.IsRevoked(tokenOf("", "long")) {
+		t.Error("the live entry was dropped")
+	}
+
+	// A fresh instance doesn't load the expired entry either.
+	fresh := NewRevocationList(list.store)
+	if err := fresh.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fresh.revoked["short"]; ok || len(fresh.revoked) != 1 {
+		t.Errorf("a fresh list loaded %d entries, want only the live one", len(fresh.revoked))
+	}
+}
@@ -2,43 +2,80 @@ package tokens
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"log"
 	"os"
 	"time"
 
 	"github.com/ChandanJnv/ecommerce-cart-golang/database"
 	jwt "github.com/dgrijalva/jwt-go"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// TokenType tells access tokens, sent with every request, from the refresh
+// tokens that only renew them.
+type TokenType string
+
+const (
+	AccessToken  TokenType = "access"
+	RefreshToken TokenType = "refresh"
+)
+
+const (
+	AccessTokenTTL  = 24 * time.Hour
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+// SignedDetails are the claims of the tokens. Session is the login session
+// the token belongs to and the standard Id claim (jti) identifies the token,
+// for revoking it and for telling the refresh tokens of a session apart.
+// Tokens issued before sessions existed have neither and no Type.
 type SignedDetails struct {
 	Email      string
 	First_Name string
 	Last_Name  string
 	Uid        string
+	Session    string    `json:"Session,omitempty"`
+	Type       TokenType `json:"Type,omitempty"`
 	jwt.StandardClaims
 }
 
-var UserData *mongo.Collection = database.UserDatabase(database.Client, "Users")
 var SECRET_KEY = os.Getenv("SECRET_KEY")
 
-func TokenGenerator(email, firstName, lastName, uid string) (signedToken, signedRefreshToken string, err error) {
+// NewTokenID returns a random ID for a token.
+func NewTokenID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		log.Panic("failed to generate token id.", err)
+	}
+	return hex.EncodeToString(id)
+}
+
+// TokenGenerator signs an access token and a refresh token with the ID
+// refreshID for a session of the user.
+func TokenGenerator(email, firstName, lastName, uid, session, refreshID string) (signedToken, signedRefreshToken string, err error) {
 	claims := &SignedDetails{
 		Email:      email,
 		First_Name: firstName,
 		Last_Name:  lastName,
 		Uid:        uid,
+		Session:    session,
+		Type:       AccessToken,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(24)).Unix(),
+			Id:        NewTokenID(),
+			IssuedAt:  time.Now().Local().Unix(),
+			ExpiresAt: time.Now().Local().Add(AccessTokenTTL).Unix(),
 		},
 	}
 
 	refreshClaims := &SignedDetails{
+		Uid:     uid,
+		Session: session,
+		Type:    RefreshToken,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(168)).Unix(),
+			Id:        refreshID,
+			IssuedAt:  time.Now().Local().Unix(),
+			ExpiresAt: time.Now().Local().Add(RefreshTokenTTL).Unix(),
 		},
 	}
 
@@ -79,30 +116,22 @@ func ValidateToken(signedToken string) (claims *SignedDetails, msg string) {
 
 }
 
-func UpdateAllTokens(signedToken, signedRefreshToken, userId string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	defer cancel()
-
-	var updateObj primitive.D
-
-	updateObj = append(updateObj, bson.E{Key: "token", Value: signedToken})
-	updateObj = append(updateObj, bson.E{Key: "refresh_token", Value: signedRefreshToken})
-	updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	updateObj = append(updateObj, bson.E{Key: "updatedat", Value: updated_at})
-
-	upsert := true
-
-	filter := bson.M{"user_id": userId}
-	opt := options.UpdateOptions{
-		Upsert: &upsert,
+// ValidateRefreshToken validates a refresh token that belongs to a session.
+func ValidateRefreshToken(signedToken string) (claims *SignedDetails, msg string) {
+	claims, msg = ValidateToken(signedToken)
+	if msg != "" {
+		return nil, msg
 	}
+	if claims.Type != RefreshToken || claims.Session == "" || claims.Id == "" {
+		return nil, "the refresh token is invalid"
+	}
+	return claims, ""
+}
 
-	_, err := UserData.UpdateOne(ctx, filter, bson.D{
-		{Key: "$set", Value: updateObj},
-	},
-		&opt)
-	if err != nil {
-		log.Panic(err)
-		return
+func UpdateAllTokens(ctx context.Context, users database.UserStore, signedToken, signedRefreshToken, userId string) error {
+	if err := users.UpdateTokens(ctx, userId, signedToken, signedRefreshToken); err != nil {
+		log.Println(err)
+		return err
 	}
+	return nil
 }